@@ -0,0 +1,1320 @@
+// Code generated by internal/openapi/clientgen from internal/openapi/openapi.json. DO NOT EDIT.
+
+// Package client is a typed client of the gomine API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the gomine API
+type Client struct {
+	// BaseURL is where gomine is served, like http://localhost:8080
+	BaseURL string
+	// Token is sent as a Bearer credential. It is either an access token or an API key.
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client of the gomine API served at baseURL
+func NewClient(baseURL string, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token, HTTPClient: http.DefaultClient}
+}
+
+// Error is returned when the API responds with an error
+type Error struct {
+	StatusCode int
+	ErrorBody
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("gomine: %v %v: %v", err.StatusCode, err.Code, err.Message)
+}
+
+// do sends a request and decodes the response into result, unless result is nil
+func (client *Client) do(ctx context.Context, method string, path string, query url.Values, body any, result any) error {
+	var reader io.Reader
+
+	if body != nil {
+		encoded, err := json.Marshal(body)
+
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(encoded)
+	}
+
+	target := client.BaseURL + path
+
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, reader)
+
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	if client.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+client.Token)
+	}
+
+	response, err := client.HTTPClient.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+	contents, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= 400 {
+		var envelope ErrorResponse
+
+		if err := json.Unmarshal(contents, &envelope); err != nil || envelope.Error.Code == "" {
+			return &Error{StatusCode: response.StatusCode, ErrorBody: ErrorBody{Message: string(contents)}}
+		}
+
+		return &Error{StatusCode: response.StatusCode, ErrorBody: envelope.Error}
+	}
+
+	if text, ok := result.(*string); ok {
+		*text = string(contents)
+		return nil
+	}
+
+	if result == nil || len(contents) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(contents, result)
+}
+
+// APIKey: A long-lived credential restricted to a set of scopes
+type APIKey struct {
+	CreatedAt  time.Time  `json:"createdAt"`
+	ID         string     `json:"id"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ServerIDs  []string   `json:"serverIds"`
+	UserID     string     `json:"userId"`
+}
+
+// APIKeyOptions: An API key to create. It applies to every server when serverIds is empty.
+type APIKeyOptions struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ServerIDs []string `json:"serverIds,omitempty"`
+}
+
+// ActivatedMFA: Recovery codes are only shown once
+type ActivatedMFA struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// AdminUpdateUserOptions: Account settings only admins can change
+type AdminUpdateUserOptions struct {
+	Disabled *bool   `json:"disabled,omitempty"`
+	Role     *string `json:"role,omitempty"`
+}
+
+type AuthenticationOptions struct {
+	Password string `json:"password"`
+	Username string `json:"username"`
+}
+
+type CgroupsConfig struct {
+	// `CGROUPS_ENABLED`
+	Enabled bool `json:"enabled"`
+	// `CGROUPS_ROOT`
+	Root string `json:"root"`
+}
+
+// ChangePasswordOptions: Users with a linked identity may confirm with a two-factor code, or by having logged in within the last 5 minutes, instead of their current password.
+type ChangePasswordOptions struct {
+	// A two-factor code, for users with a linked identity
+	Code            *string `json:"code,omitempty"`
+	CurrentPassword *string `json:"currentPassword,omitempty"`
+	NewPassword     string  `json:"newPassword"`
+}
+
+// Config: The configuration gomine runs with. Secrets are redacted.
+type Config struct {
+	Cgroups       CgroupsConfig       `json:"cgroups"`
+	Database      DatabaseConfig      `json:"database"`
+	Java          JavaConfig          `json:"java"`
+	Login         LoginConfig         `json:"login"`
+	MFA           MFAConfig           `json:"mfa"`
+	OIDC          OIDCConfig          `json:"oidc"`
+	PasswordReset PasswordResetConfig `json:"passwordReset"`
+	Passwords     PasswordConfig      `json:"passwords"`
+	Ports         PortsConfig         `json:"ports"`
+	Registration  RegistrationConfig  `json:"registration"`
+	Server        ServerConfig        `json:"server"`
+	SMTP          SMTPConfig          `json:"smtp"`
+	Tokens        TokenConfig         `json:"tokens"`
+}
+
+// ConsoleCommand: A command to run on the console of a server
+type ConsoleCommand struct {
+	Command string `json:"command"`
+}
+
+// ConsoleCommandOptions: A command to run on the console of the server identified in the body
+type ConsoleCommandOptions struct {
+	ConsoleCommand
+	ServerID string `json:"serverId"`
+}
+
+// CreatedAPIKey: A created API key. The raw key is only shown once.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// CreatedInvite: A created invite. The raw code is only shown once.
+type CreatedInvite struct {
+	Invite
+	Code string `json:"code"`
+}
+
+// CreatedUser: An account that signed up
+type CreatedUser struct {
+	ID              string `json:"id"`
+	PendingApproval bool   `json:"pendingApproval"`
+	Username        string `json:"username"`
+}
+
+type DatabaseConfig struct {
+	// `DATABASE_URL`
+	URL string `json:"url"`
+}
+
+// DeleteAccountOptions: What happens to the servers of a deleted account. The password, or a two-factor code or a recent login for users with a linked identity, is only required when deleting your own account.
+type DeleteAccountOptions struct {
+	// A two-factor code, for users with a linked identity
+	Code     *string `json:"code,omitempty"`
+	Password *string `json:"password,omitempty"`
+	Servers  string  `json:"servers"`
+	// The user servers are transferred to
+	TransferTo *string `json:"transferTo,omitempty"`
+}
+
+// DisableMFAOptions: Confirms turning two-factor authentication off like ChangePasswordOptions does
+type DisableMFAOptions struct {
+	// A two-factor code, for users with a linked identity
+	Code     *string `json:"code,omitempty"`
+	Password *string `json:"password,omitempty"`
+}
+
+// EULA: Whether the Minecraft EULA has been accepted for a server
+type EULA struct {
+	Accepted bool `json:"accepted"`
+}
+
+// Enrollment: What is needed to add an account to an authenticator app
+type Enrollment struct {
+	ProvisioningURI string `json:"provisioningUri"`
+	Secret          string `json:"secret"`
+}
+
+// ErrorBody: What went wrong
+type ErrorBody struct {
+	Code string `json:"code"`
+	// More about the error, like the fields that failed validation
+	Details any    `json:"details,omitempty"`
+	Message string `json:"message"`
+	// Also sent in the X-Request-ID header
+	RequestID *string `json:"requestId,omitempty"`
+}
+
+// ErrorResponse: The envelope every error is sent in
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// GrantOptions: A role given to a user on the server identified in the path
+type GrantOptions struct {
+	Role   string `json:"role"`
+	UserID string `json:"userId"`
+}
+
+// Invite: A code that lets people sign up while registration is invite-only
+type Invite struct {
+	CreatedAt time.Time  `json:"createdAt"`
+	CreatedBy string     `json:"createdBy"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	ID        string     `json:"id"`
+	MaxUses   int        `json:"maxUses"`
+	Uses      int        `json:"uses"`
+}
+
+// InviteOptions: An invite to create. Invites are single-use and never expire unless set otherwise.
+type InviteOptions struct {
+	ExpiresInHours *int `json:"expiresInHours,omitempty"`
+	MaxUses        *int `json:"maxUses,omitempty"`
+}
+
+type JavaConfig struct {
+	// `JAVA_DEFAULT_MAX_HEAP_MB`
+	DefaultMaxHeapMb int `json:"defaultMaxHeapMb"`
+	// `JAVA_HOMES`
+	Homes string `json:"homes"`
+}
+
+// JavaInstallation: A Java runtime found on the host
+type JavaInstallation struct {
+	Home string `json:"home"`
+	// Major version, like 17 or 8
+	Major int `json:"major"`
+	// Full version, like `17.0.2` or `1.8.0_292`
+	Version string `json:"version"`
+}
+
+// LaunchSettings: How the JVM of a server is started. Heap sizes of 0 are left to the JVM, and an empty javaHome selects the installation closest to the Java version the server needs. Limits confine the server process when the host supports cgroup v2.
+type LaunchSettings struct {
+	// Home of the Java installation to use, one of those listed by listJavaInstallations
+	JavaHome *string `json:"javaHome,omitempty"`
+	// Options passed to the JVM before -jar
+	JvmArgs []string        `json:"jvmArgs,omitempty"`
+	Limits  *ResourceLimits `json:"limits,omitempty"`
+	// Maximum heap size in MB, passed as -Xmx
+	MaxHeapMb *int `json:"maxHeapMb,omitempty"`
+	// Initial heap size in MB, passed as -Xms
+	MinHeapMb *int `json:"minHeapMb,omitempty"`
+	// Args passed to the server after nogui
+	ServerArgs []string `json:"serverArgs,omitempty"`
+}
+
+type LoginConfig struct {
+	// `LOGIN_LOCKOUT_MINUTES`
+	LockoutMinutes int `json:"lockoutMinutes"`
+	// `LOGIN_MAX_FAILURES`
+	MaxFailures int `json:"maxFailures"`
+}
+
+// LoginResponse: Either the tokens of a new session or a two-factor authentication challenge
+type LoginResponse struct {
+	*TokenPair
+	*MFAChallengeResponse
+}
+
+// MCServer: A server with its properties
+type MCServer struct {
+	CreatedAt      time.Time `json:"CreatedAt"`
+	ID             string    `json:"ID"`
+	IsEulaAccepted bool      `json:"IsEulaAccepted"`
+	// Major Java version the server needs, or 0 when it is not known
+	JavaVersion int            `json:"JavaVersion"`
+	Launch      LaunchSettings `json:"Launch"`
+	Name        string         `json:"Name"`
+	PID         int            `json:"PID"`
+	Path        string         `json:"Path"`
+	// Whether property changes wait for a restart to take effect
+	PendingRestart bool             `json:"PendingRestart"`
+	Properties     ServerProperties `json:"Properties"`
+	Runtime        string           `json:"Runtime"`
+	Status         bool             `json:"Status"`
+	UpdatedAt      time.Time        `json:"UpdatedAt"`
+	// Resources the server uses, null when it is not running or not confined
+	Usage  *ResourceUsage `json:"Usage"`
+	UserID string         `json:"UserID"`
+}
+
+// MCServerLite: A server as listed
+type MCServerLite struct {
+	CreatedAt time.Time `json:"CreatedAt"`
+	ID        string    `json:"ID"`
+	Name      string    `json:"Name"`
+	// Process id, -1 when the server is stopped
+	PID     int    `json:"PID"`
+	Path    string `json:"Path"`
+	Runtime string `json:"Runtime"`
+	// Whether the server is running
+	Status bool   `json:"Status"`
+	UserID string `json:"UserID"`
+}
+
+// MFAChallengeResponse: Returned instead of tokens when the account has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+}
+
+type MFAConfig struct {
+	// `TOTP_ISSUER`
+	TOTPIssuer string `json:"totpIssuer"`
+}
+
+// MFAOptions: Completes a login challenged for a second factor. Either code or recoveryCode is required.
+type MFAOptions struct {
+	Code         *string `json:"code,omitempty"`
+	MFAToken     string  `json:"mfaToken"`
+	RecoveryCode *string `json:"recoveryCode,omitempty"`
+}
+
+type MFAVerificationOptions struct {
+	Code string `json:"code"`
+}
+
+type OIDCConfig struct {
+	// `OIDC_ADMIN_GROUPS`
+	AdminGroups string `json:"adminGroups"`
+	// `OIDC_AUTO_PROVISION`
+	AutoProvision bool `json:"autoProvision"`
+	// `OIDC_CLIENT_ID`
+	ClientID string `json:"clientId"`
+	// `OIDC_CLIENT_SECRET`
+	ClientSecret string `json:"clientSecret"`
+	// `OIDC_GROUPS_CLAIM`
+	GroupsClaim string `json:"groupsClaim"`
+	// `OIDC_ISSUER`
+	Issuer string `json:"issuer"`
+	// `OIDC_LINK_BY_EMAIL`
+	LinkByEmail bool `json:"linkByEmail"`
+	// `OIDC_REDIRECT_URL`
+	RedirectURL string `json:"redirectUrl"`
+	// `OIDC_SCOPES`
+	Scopes string `json:"scopes"`
+}
+
+type PasswordConfig struct {
+	// `BREACHED_PASSWORDS_FILE`
+	BreachedPasswordsFile string `json:"breachedPasswordsFile"`
+	// `PASSWORD_MIN_LENGTH`
+	MinLength int `json:"minLength"`
+}
+
+type PasswordResetConfig struct {
+	// `PASSWORD_RESET_LIFESPAN_MINUTES`
+	LifespanMinutes int `json:"lifespanMinutes"`
+	// `PASSWORD_RESET_URL`
+	URL string `json:"url"`
+}
+
+type PasswordResetOptions struct {
+	NewPassword string `json:"newPassword"`
+	Token       string `json:"token"`
+}
+
+type PasswordResetRequestOptions struct {
+	Username string `json:"username"`
+}
+
+type PortsConfig struct {
+	// `SERVER_PORTS_FIRST`
+	First int `json:"first"`
+	// `SERVER_PORTS_LAST`
+	Last int `json:"last"`
+}
+
+// PropertiesPatch: Properties to change on a server. A null value removes the property.
+type PropertiesPatch map[string]any
+
+// PropertiesRevert: The change the properties of a server are reverted to the state before
+type PropertiesRevert struct {
+	ChangeID string `json:"changeId"`
+}
+
+// PropertiesUpdateResult: The outcome of changing the properties of a server
+type PropertiesUpdateResult struct {
+	// Changed properties applied to the running server
+	AppliedLive    []string         `json:"appliedLive"`
+	PendingRestart bool             `json:"pendingRestart"`
+	Properties     ServerProperties `json:"properties"`
+	// Changed properties that take effect on the next start
+	RestartRequired []string `json:"restartRequired"`
+}
+
+// PropertyChange: A property before and after a change. Null means the property was not set.
+type PropertyChange struct {
+	New *string `json:"new"`
+	Old *string `json:"old"`
+}
+
+// PropertyChangeRecord: An audited change of the properties of a server
+type PropertyChangeRecord struct {
+	Changes   map[string]PropertyChange `json:"changes"`
+	CreatedAt time.Time                 `json:"createdAt"`
+	ID        string                    `json:"id"`
+	ServerID  string                    `json:"serverId"`
+	UserID    string                    `json:"userId"`
+}
+
+type RefreshOptions struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RegistrationConfig struct {
+	// `ADMIN_USERNAME`
+	AdminUsername string `json:"adminUsername"`
+	// `REGISTRATION_MODE`
+	Mode string `json:"mode"`
+}
+
+// ResourceLimits: Resources a server process may use, enforced with cgroup v2. Limits of 0 are not enforced.
+type ResourceLimits struct {
+	// Most CPU time, in percent of one CPU
+	CpuQuotaPercent *int `json:"cpuQuotaPercent,omitempty"`
+	// Share of CPU time when CPUs are busy, from 1 to 10000. Servers get 100 by default.
+	CpuWeight *int `json:"cpuWeight,omitempty"`
+	// Share of disk time when disks are busy, from 1 to 10000
+	IoWeight *int `json:"ioWeight,omitempty"`
+	// Most memory in MB, above maxHeapMb since the JVM needs memory besides its heap
+	MemoryMaxMb *int `json:"memoryMaxMb,omitempty"`
+	// Most processes and threads
+	PidsMax *int `json:"pidsMax,omitempty"`
+}
+
+// ResourceUsage: Resources the processes of a running server use, read from its cgroup
+type ResourceUsage struct {
+	// Time throttled by cpuQuotaPercent in microseconds
+	CpuThrottledUsec int `json:"cpuThrottledUsec"`
+	// CPU time used in microseconds
+	CpuUsec     int `json:"cpuUsec"`
+	MemoryBytes int `json:"memoryBytes"`
+	// 0 on kernels before 5.19
+	MemoryPeakBytes int `json:"memoryPeakBytes"`
+	// Times a process was killed for reaching memoryMaxMb
+	OomKills int `json:"oomKills"`
+	Pids     int `json:"pids"`
+}
+
+// RevertServerPropertiesOptions: A server and the change its properties are reverted to the state before
+type RevertServerPropertiesOptions struct {
+	PropertiesRevert
+	ServerID string `json:"serverId"`
+}
+
+type SMTPConfig struct {
+	// `SMTP_FROM`
+	From string `json:"from"`
+	// `SMTP_HOST`
+	Host string `json:"host"`
+	// `SMTP_PASSWORD`
+	Password string `json:"password"`
+	// `SMTP_PORT`
+	Port string `json:"port"`
+	// `SMTP_USERNAME`
+	Username string `json:"username"`
+}
+
+// ServerActionOptions: The server a lifecycle action is performed on
+type ServerActionOptions struct {
+	ServerID string `json:"serverId"`
+}
+
+// ServerActionResult: The server a lifecycle action was performed on
+type ServerActionResult struct {
+	ServerID string `json:"serverId"`
+}
+
+type ServerConfig struct {
+	// `DATA_PATH`
+	DataPath string `json:"dataPath"`
+	// `LISTEN_ADDRESS`
+	ListenAddress string `json:"listenAddress"`
+}
+
+// ServerGrant: A role given to a user on a server they do not own
+type ServerGrant struct {
+	Role     string `json:"role"`
+	ServerID string `json:"serverId"`
+	UserID   string `json:"userId"`
+}
+
+// ServerList: A page of servers. nextCursor is left out on the last page.
+type ServerList struct {
+	NextCursor *string        `json:"nextCursor,omitempty"`
+	Servers    []MCServerLite `json:"servers"`
+}
+
+// ServerOptions: A server to create. Config overrides the default server properties, and launch the default launch settings.
+type ServerOptions struct {
+	Config         map[string]any  `json:"config,omitempty"`
+	IsEulaAccepted bool            `json:"isEulaAccepted"`
+	Launch         *LaunchSettings `json:"launch,omitempty"`
+	Name           string          `json:"name"`
+	// Minecraft version the server runs, like `1.20.1`
+	Runtime string `json:"runtime"`
+}
+
+// ServerProperties: The server.properties of a server
+type ServerProperties struct {
+	AllowFlight                    bool    `json:"allow-flight"`
+	AllowNether                    bool    `json:"allow-nether"`
+	BroadcastConsoleToOps          bool    `json:"broadcast-console-to-ops"`
+	BroadcastRconToOps             bool    `json:"broadcast-rcon-to-ops"`
+	Difficulty                     string  `json:"difficulty"`
+	EnableCommandBlock             bool    `json:"enable-command-block"`
+	EnableJmxMonitoring            bool    `json:"enable-jmx-monitoring"`
+	EnableQuery                    bool    `json:"enable-query"`
+	EnableRcon                     bool    `json:"enable-rcon"`
+	EnableStatus                   bool    `json:"enable-status"`
+	EnforceWhitelist               bool    `json:"enforce-whitelist"`
+	EntityBroadcastRangePercentage int     `json:"entity-broadcast-range-percentage"`
+	ForceGamemode                  bool    `json:"force-gamemode"`
+	FunctionPermissionLevel        int     `json:"function-permission-level"`
+	Gamemode                       string  `json:"gamemode"`
+	GenerateStructures             bool    `json:"generate-structures"`
+	GeneratorSettings              *string `json:"generator-settings,omitempty"`
+	Hardcore                       bool    `json:"hardcore"`
+	LevelName                      string  `json:"level-name"`
+	LevelSeed                      string  `json:"level-seed"`
+	LevelType                      string  `json:"level-type"`
+	MaxBuildHeight                 int     `json:"max-build-height"`
+	MaxPlayers                     int     `json:"max-players"`
+	MaxTickTime                    int     `json:"max-tick-time"`
+	MaxWorldSize                   int     `json:"max-world-size"`
+	Motd                           string  `json:"motd"`
+	NetworkCompressionThreshold    int     `json:"network-compression-threshold"`
+	OnlineMode                     bool    `json:"online-mode"`
+	OpPermissionLevel              int     `json:"op-permission-level"`
+	PlayerIdleTimeout              int     `json:"player-idle-timeout"`
+	PreventProxyConnections        bool    `json:"prevent-proxy-connections"`
+	Pvp                            bool    `json:"pvp"`
+	QueryPort                      int     `json:"query.port"`
+	RateLimit                      int     `json:"rate-limit"`
+	RconPassword                   *string `json:"rcon.password,omitempty"`
+	RconPort                       int     `json:"rcon.port"`
+	ResourcePack                   *string `json:"resource-pack,omitempty"`
+	ResourcePackSha1               *string `json:"resource-pack-sha1,omitempty"`
+	ServerIp                       *string `json:"server-ip,omitempty"`
+	ServerPort                     int     `json:"server-port"`
+	SnooperEnabled                 bool    `json:"snooper-enabled"`
+	SpawnAnimals                   bool    `json:"spawn-animals"`
+	SpawnMonsters                  bool    `json:"spawn-monsters"`
+	SpawnNpcs                      bool    `json:"spawn-npcs"`
+	SpawnProtection                int     `json:"spawn-protection"`
+	SyncChunkWrites                bool    `json:"sync-chunk-writes"`
+	UseNativeTransport             bool    `json:"use-native-transport"`
+	ViewDistance                   int     `json:"view-distance"`
+	WhiteList                      bool    `json:"white-list"`
+}
+
+type TokenConfig struct {
+	// `JWT_ACCEPT_LEGACY_TOKENS`
+	AcceptLegacyTokens bool `json:"acceptLegacyTokens"`
+	// `JWT_AUTH_LIFESPAN_MINUTES`
+	AccessTokenLifespanMinutes int `json:"accessTokenLifespanMinutes"`
+	// `JWT_CLOCK_SKEW_SECONDS`
+	ClockSkewSeconds int `json:"clockSkewSeconds"`
+	// `JWT_ISSUER`
+	Issuer string `json:"issuer"`
+	// `JWT_KEY_ID`
+	KeyID string `json:"keyId"`
+	// `JWT_PREVIOUS_SECRETS`
+	PreviousSecrets string `json:"previousSecrets"`
+	// `JWT_PRIVATE_KEY_FILE`
+	PrivateKeyFile string `json:"privateKeyFile"`
+	// `JWT_PUBLIC_KEY_FILES`
+	PublicKeyFiles string `json:"publicKeyFiles"`
+	// `JWT_REFRESH_LIFESPAN_HOURS`
+	RefreshTokenLifespanHours int `json:"refreshTokenLifespanHours"`
+	// `API_SECRET`
+	Secret string `json:"secret"`
+}
+
+// TokenPair: The tokens of a session
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// UpdateProfileOptions: Profile fields to change. An empty email removes the address. Changing the email must be confirmed with the password, or a two-factor code or a recent login for users with a linked identity.
+type UpdateProfileOptions struct {
+	// A two-factor code, for users with a linked identity
+	Code     *string `json:"code,omitempty"`
+	Email    *string `json:"email,omitempty"`
+	Password *string `json:"password,omitempty"`
+	Username *string `json:"username,omitempty"`
+}
+
+// UpdatedServerProperties: Properties to change on a server. A null value removes the property.
+type UpdatedServerProperties struct {
+	ServerID         string         `json:"serverId"`
+	ServerProperties map[string]any `json:"serverProperties"`
+}
+
+// UserOptions: An account to sign up
+type UserOptions struct {
+	// Required when registration is invite-only
+	InviteCode *string `json:"inviteCode,omitempty"`
+	Password   string  `json:"password"`
+	Username   string  `json:"username"`
+}
+
+// UserProfile: The public view of an account
+type UserProfile struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Disabled  bool      `json:"disabled"`
+	Email     string    `json:"email"`
+	// Whether gomine confirmed that the user receives mail at the address
+	EmailVerified   bool   `json:"emailVerified"`
+	ID              string `json:"id"`
+	PendingApproval bool   `json:"pendingApproval"`
+	Role            string `json:"role"`
+	Username        string `json:"username"`
+}
+
+// GetConfig calls GET /api/config: Get the configuration gomine runs with
+func (client *Client) GetConfig(ctx context.Context) (*Config, error) {
+	path := "/api/config"
+	query := url.Values{}
+	var result *Config
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// Login calls POST /api/login: Log in with a username and password
+func (client *Client) Login(ctx context.Context, body *AuthenticationOptions) (*LoginResponse, error) {
+	path := "/api/login"
+	query := url.Values{}
+	var result *LoginResponse
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// CompleteMFALogin calls POST /api/login/mfa: Complete a login challenged for a second factor
+func (client *Client) CompleteMFALogin(ctx context.Context, body *MFAOptions) (*TokenPair, error) {
+	path := "/api/login/mfa"
+	query := url.Values{}
+	var result *TokenPair
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// Logout calls POST /api/logout: Log out of the session
+func (client *Client) Logout(ctx context.Context) error {
+	path := "/api/logout"
+	query := url.Values{}
+	return client.do(ctx, "POST", path, query, nil, nil)
+}
+
+// LegacyListServers calls GET /api/mcsrv/: List the servers of a user
+//
+// Deprecated: Use GET /api/v1/servers instead.
+func (client *Client) LegacyListServers(ctx context.Context, u string) ([]MCServerLite, error) {
+	path := "/api/mcsrv/"
+	query := url.Values{}
+	if u != "" {
+		query.Set("u", u)
+	}
+	var result []MCServerLite
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// LegacyCreateServer calls POST /api/mcsrv/: Create a server
+//
+// Deprecated: Use POST /api/v1/servers instead.
+func (client *Client) LegacyCreateServer(ctx context.Context, body *ServerOptions) (*MCServer, error) {
+	path := "/api/mcsrv/"
+	query := url.Values{}
+	var result *MCServer
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// LegacySendConsoleCommand calls POST /api/mcsrv/console: Run a command on the console of a server
+//
+// Deprecated: Use POST /api/v1/servers/{id}/console instead.
+func (client *Client) LegacySendConsoleCommand(ctx context.Context, body *ConsoleCommandOptions) error {
+	path := "/api/mcsrv/console"
+	query := url.Values{}
+	return client.do(ctx, "POST", path, query, body, nil)
+}
+
+// LegacyGetServerDefaults calls GET /api/mcsrv/defaults: Get the default server properties
+//
+// Deprecated: Use GET /api/v1/servers/defaults instead.
+func (client *Client) LegacyGetServerDefaults(ctx context.Context) (*ServerProperties, error) {
+	path := "/api/mcsrv/defaults"
+	query := url.Values{}
+	var result *ServerProperties
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// LegacyGetServerDetails calls GET /api/mcsrv/detail: Get a server with its properties
+//
+// Deprecated: Use GET /api/v1/servers/{id} instead.
+func (client *Client) LegacyGetServerDetails(ctx context.Context, s string) (*MCServer, error) {
+	path := "/api/mcsrv/detail"
+	query := url.Values{}
+	query.Set("s", s)
+	var result *MCServer
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// LegacyListServerGrants calls GET /api/mcsrv/grants: List the users given access to a server
+//
+// Deprecated: Use GET /api/v1/servers/{id}/grants instead.
+func (client *Client) LegacyListServerGrants(ctx context.Context, s string) ([]ServerGrant, error) {
+	path := "/api/mcsrv/grants"
+	query := url.Values{}
+	query.Set("s", s)
+	var result []ServerGrant
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// LegacyCreateServerGrant calls POST /api/mcsrv/grants: Give a user access to a server
+//
+// Deprecated: Use POST /api/v1/servers/{id}/grants instead.
+func (client *Client) LegacyCreateServerGrant(ctx context.Context, body *ServerGrant) (*ServerGrant, error) {
+	path := "/api/mcsrv/grants"
+	query := url.Values{}
+	var result *ServerGrant
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// LegacyDeleteServerGrant calls DELETE /api/mcsrv/grants: Revoke the access of a user to a server
+//
+// Deprecated: Use DELETE /api/v1/servers/{id}/grants/{userId} instead.
+func (client *Client) LegacyDeleteServerGrant(ctx context.Context, s string, u string) error {
+	path := "/api/mcsrv/grants"
+	query := url.Values{}
+	query.Set("s", s)
+	query.Set("u", u)
+	return client.do(ctx, "DELETE", path, query, nil, nil)
+}
+
+// LegacyUpdateServerProperties calls PUT /api/mcsrv/properties: Change the properties of a server
+//
+// Deprecated: Use PATCH /api/v1/servers/{id}/properties instead.
+func (client *Client) LegacyUpdateServerProperties(ctx context.Context, body *UpdatedServerProperties) (*PropertiesUpdateResult, error) {
+	path := "/api/mcsrv/properties"
+	query := url.Values{}
+	var result *PropertiesUpdateResult
+	err := client.do(ctx, "PUT", path, query, body, &result)
+	return result, err
+}
+
+// LegacyGetServerPropertiesHistory calls GET /api/mcsrv/properties/history: List the property changes of a server
+//
+// Deprecated: Use GET /api/v1/servers/{id}/properties/history instead.
+func (client *Client) LegacyGetServerPropertiesHistory(ctx context.Context, s string) ([]PropertyChangeRecord, error) {
+	path := "/api/mcsrv/properties/history"
+	query := url.Values{}
+	query.Set("s", s)
+	var result []PropertyChangeRecord
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// LegacyRevertServerProperties calls POST /api/mcsrv/properties/revert: Revert the properties of a server to before a change
+//
+// Deprecated: Use POST /api/v1/servers/{id}/properties/revert instead.
+func (client *Client) LegacyRevertServerProperties(ctx context.Context, body *RevertServerPropertiesOptions) (*PropertiesUpdateResult, error) {
+	path := "/api/mcsrv/properties/revert"
+	query := url.Values{}
+	var result *PropertiesUpdateResult
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// LegacyStartServer calls POST /api/mcsrv/start: Start a server
+//
+// Deprecated: Use POST /api/v1/servers/{id}/start instead.
+func (client *Client) LegacyStartServer(ctx context.Context, body *ServerActionOptions) (*ServerActionResult, error) {
+	path := "/api/mcsrv/start"
+	query := url.Values{}
+	var result *ServerActionResult
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// LegacyStopServer calls POST /api/mcsrv/stop: Stop a server
+//
+// Deprecated: Use POST /api/v1/servers/{id}/stop instead.
+func (client *Client) LegacyStopServer(ctx context.Context, body *ServerActionOptions) (*ServerActionResult, error) {
+	path := "/api/mcsrv/stop"
+	query := url.Values{}
+	var result *ServerActionResult
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// CreateUser calls POST /api/mcusr: Sign up
+func (client *Client) CreateUser(ctx context.Context, body *UserOptions) (*CreatedUser, error) {
+	path := "/api/mcusr"
+	query := url.Values{}
+	var result *CreatedUser
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// ListAPIKeys calls GET /api/mcusr/apikeys/: List your API keys
+func (client *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	path := "/api/mcusr/apikeys/"
+	query := url.Values{}
+	var result []APIKey
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// CreateAPIKey calls POST /api/mcusr/apikeys/: Create an API key
+func (client *Client) CreateAPIKey(ctx context.Context, body *APIKeyOptions) (*CreatedAPIKey, error) {
+	path := "/api/mcusr/apikeys/"
+	query := url.Values{}
+	var result *CreatedAPIKey
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// DeleteAPIKey calls DELETE /api/mcusr/apikeys/: Revoke an API key
+func (client *Client) DeleteAPIKey(ctx context.Context, k string) error {
+	path := "/api/mcusr/apikeys/"
+	query := url.Values{}
+	query.Set("k", k)
+	return client.do(ctx, "DELETE", path, query, nil, nil)
+}
+
+// ListInvites calls GET /api/mcusr/invites/: List invites
+func (client *Client) ListInvites(ctx context.Context) ([]Invite, error) {
+	path := "/api/mcusr/invites/"
+	query := url.Values{}
+	var result []Invite
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// CreateInvite calls POST /api/mcusr/invites/: Create an invite
+func (client *Client) CreateInvite(ctx context.Context, body *InviteOptions) (*CreatedInvite, error) {
+	path := "/api/mcusr/invites/"
+	query := url.Values{}
+	var result *CreatedInvite
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// DeleteInvite calls DELETE /api/mcusr/invites/: Revoke an invite
+func (client *Client) DeleteInvite(ctx context.Context, i string) error {
+	path := "/api/mcusr/invites/"
+	query := url.Values{}
+	query.Set("i", i)
+	return client.do(ctx, "DELETE", path, query, nil, nil)
+}
+
+// GetMe calls GET /api/mcusr/me: Get your profile
+func (client *Client) GetMe(ctx context.Context) (*UserProfile, error) {
+	path := "/api/mcusr/me"
+	query := url.Values{}
+	var result *UserProfile
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// UpdateMe calls PATCH /api/mcusr/me: Change your profile
+func (client *Client) UpdateMe(ctx context.Context, body *UpdateProfileOptions) (*UserProfile, error) {
+	path := "/api/mcusr/me"
+	query := url.Values{}
+	var result *UserProfile
+	err := client.do(ctx, "PATCH", path, query, body, &result)
+	return result, err
+}
+
+// DeleteMe calls DELETE /api/mcusr/me: Delete your account
+func (client *Client) DeleteMe(ctx context.Context, body *DeleteAccountOptions) error {
+	path := "/api/mcusr/me"
+	query := url.Values{}
+	return client.do(ctx, "DELETE", path, query, body, nil)
+}
+
+// BeginMFAEnrollment calls POST /api/mcusr/me/mfa: Start enrolling in two-factor authentication
+func (client *Client) BeginMFAEnrollment(ctx context.Context) (*Enrollment, error) {
+	path := "/api/mcusr/me/mfa"
+	query := url.Values{}
+	var result *Enrollment
+	err := client.do(ctx, "POST", path, query, nil, &result)
+	return result, err
+}
+
+// DisableMFA calls DELETE /api/mcusr/me/mfa: Disable two-factor authentication
+func (client *Client) DisableMFA(ctx context.Context, body *DisableMFAOptions) error {
+	path := "/api/mcusr/me/mfa"
+	query := url.Values{}
+	return client.do(ctx, "DELETE", path, query, body, nil)
+}
+
+// VerifyMFAEnrollment calls POST /api/mcusr/me/mfa/verify: Activate two-factor authentication with a code from the authenticator app
+func (client *Client) VerifyMFAEnrollment(ctx context.Context, body *MFAVerificationOptions) (*ActivatedMFA, error) {
+	path := "/api/mcusr/me/mfa/verify"
+	query := url.Values{}
+	var result *ActivatedMFA
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// ChangePassword calls PUT /api/mcusr/me/password: Change your password. Every other session is logged out.
+func (client *Client) ChangePassword(ctx context.Context, body *ChangePasswordOptions) (*TokenPair, error) {
+	path := "/api/mcusr/me/password"
+	query := url.Values{}
+	var result *TokenPair
+	err := client.do(ctx, "PUT", path, query, body, &result)
+	return result, err
+}
+
+// ListUsers calls GET /api/mcusr/users/: List every account
+func (client *Client) ListUsers(ctx context.Context) ([]UserProfile, error) {
+	path := "/api/mcusr/users/"
+	query := url.Values{}
+	var result []UserProfile
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// UpdateUser calls PATCH /api/mcusr/users/: Change the role of an account or disable it
+func (client *Client) UpdateUser(ctx context.Context, u string, body *AdminUpdateUserOptions) (*UserProfile, error) {
+	path := "/api/mcusr/users/"
+	query := url.Values{}
+	query.Set("u", u)
+	var result *UserProfile
+	err := client.do(ctx, "PATCH", path, query, body, &result)
+	return result, err
+}
+
+// DeleteUser calls DELETE /api/mcusr/users/: Delete an account
+func (client *Client) DeleteUser(ctx context.Context, u string, body *DeleteAccountOptions) error {
+	path := "/api/mcusr/users/"
+	query := url.Values{}
+	query.Set("u", u)
+	return client.do(ctx, "DELETE", path, query, body, nil)
+}
+
+// ApproveUser calls POST /api/mcusr/users/approve: Approve an account awaiting approval
+func (client *Client) ApproveUser(ctx context.Context, u string) (*UserProfile, error) {
+	path := "/api/mcusr/users/approve"
+	query := url.Values{}
+	query.Set("u", u)
+	var result *UserProfile
+	err := client.do(ctx, "POST", path, query, nil, &result)
+	return result, err
+}
+
+// CompleteOIDCLogin calls GET /api/oidc/callback: Complete a login with the identity provider
+func (client *Client) CompleteOIDCLogin(ctx context.Context, state string, code string, errorParam string, errorDescription string) (*LoginResponse, error) {
+	path := "/api/oidc/callback"
+	query := url.Values{}
+	query.Set("state", state)
+	if code != "" {
+		query.Set("code", code)
+	}
+	if errorParam != "" {
+		query.Set("error", errorParam)
+	}
+	if errorDescription != "" {
+		query.Set("error_description", errorDescription)
+	}
+	var result *LoginResponse
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// GetOpenAPISpec calls GET /api/openapi.json: Get this document
+func (client *Client) GetOpenAPISpec(ctx context.Context) (map[string]any, error) {
+	path := "/api/openapi.json"
+	query := url.Values{}
+	var result map[string]any
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// RequestPasswordReset calls POST /api/password-reset: Send a password reset token to the owner of an account
+func (client *Client) RequestPasswordReset(ctx context.Context, body *PasswordResetRequestOptions) error {
+	path := "/api/password-reset"
+	query := url.Values{}
+	return client.do(ctx, "POST", path, query, body, nil)
+}
+
+// ResetPassword calls POST /api/password-reset/confirm: Set a new password with a reset token
+func (client *Client) ResetPassword(ctx context.Context, body *PasswordResetOptions) error {
+	path := "/api/password-reset/confirm"
+	query := url.Values{}
+	return client.do(ctx, "POST", path, query, body, nil)
+}
+
+// RefreshTokens calls POST /api/refresh: Exchange a refresh token for new tokens
+func (client *Client) RefreshTokens(ctx context.Context, body *RefreshOptions) (*TokenPair, error) {
+	path := "/api/refresh"
+	query := url.Values{}
+	var result *TokenPair
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// ListAllServers calls GET /api/v1/admin/servers: List a page of the servers of every user
+func (client *Client) ListAllServers(ctx context.Context, status string, runtime string, name string, sort string, limit string, cursor string) (*ServerList, error) {
+	path := "/api/v1/admin/servers"
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	if runtime != "" {
+		query.Set("runtime", runtime)
+	}
+	if name != "" {
+		query.Set("name", name)
+	}
+	if sort != "" {
+		query.Set("sort", sort)
+	}
+	if limit != "" {
+		query.Set("limit", limit)
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	var result *ServerList
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// ListJavaInstallations calls GET /api/v1/java: List the Java installations servers can be started with
+func (client *Client) ListJavaInstallations(ctx context.Context) ([]JavaInstallation, error) {
+	path := "/api/v1/java"
+	query := url.Values{}
+	var result []JavaInstallation
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// ListServers calls GET /api/v1/servers: List a page of the servers of a user
+func (client *Client) ListServers(ctx context.Context, userID string, status string, runtime string, name string, sort string, limit string, cursor string) (*ServerList, error) {
+	path := "/api/v1/servers"
+	query := url.Values{}
+	if userID != "" {
+		query.Set("userId", userID)
+	}
+	if status != "" {
+		query.Set("status", status)
+	}
+	if runtime != "" {
+		query.Set("runtime", runtime)
+	}
+	if name != "" {
+		query.Set("name", name)
+	}
+	if sort != "" {
+		query.Set("sort", sort)
+	}
+	if limit != "" {
+		query.Set("limit", limit)
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	var result *ServerList
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// CreateServer calls POST /api/v1/servers: Create a server
+func (client *Client) CreateServer(ctx context.Context, body *ServerOptions) (*MCServer, error) {
+	path := "/api/v1/servers"
+	query := url.Values{}
+	var result *MCServer
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// GetServerDefaults calls GET /api/v1/servers/defaults: Get the default server properties
+func (client *Client) GetServerDefaults(ctx context.Context) (*ServerProperties, error) {
+	path := "/api/v1/servers/defaults"
+	query := url.Values{}
+	var result *ServerProperties
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// GetServer calls GET /api/v1/servers/{id}: Get a server with its properties
+func (client *Client) GetServer(ctx context.Context, id string) (*MCServer, error) {
+	path := "/api/v1/servers/{id}"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *MCServer
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// SendConsoleCommand calls POST /api/v1/servers/{id}/console: Run a command on the console of a server
+func (client *Client) SendConsoleCommand(ctx context.Context, id string, body *ConsoleCommand) error {
+	path := "/api/v1/servers/{id}/console"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	return client.do(ctx, "POST", path, query, body, nil)
+}
+
+// GetServerEULA calls GET /api/v1/servers/{id}/eula: Get whether the Minecraft EULA has been accepted for a server
+func (client *Client) GetServerEULA(ctx context.Context, id string) (*EULA, error) {
+	path := "/api/v1/servers/{id}/eula"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *EULA
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// UpdateServerEULA calls PUT /api/v1/servers/{id}/eula: Accept or decline the Minecraft EULA for a server
+func (client *Client) UpdateServerEULA(ctx context.Context, id string, body *EULA) (*EULA, error) {
+	path := "/api/v1/servers/{id}/eula"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *EULA
+	err := client.do(ctx, "PUT", path, query, body, &result)
+	return result, err
+}
+
+// ListServerGrants calls GET /api/v1/servers/{id}/grants: List the users given access to a server
+func (client *Client) ListServerGrants(ctx context.Context, id string) ([]ServerGrant, error) {
+	path := "/api/v1/servers/{id}/grants"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result []ServerGrant
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// CreateServerGrant calls POST /api/v1/servers/{id}/grants: Give a user access to a server
+func (client *Client) CreateServerGrant(ctx context.Context, id string, body *GrantOptions) (*ServerGrant, error) {
+	path := "/api/v1/servers/{id}/grants"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *ServerGrant
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// DeleteServerGrant calls DELETE /api/v1/servers/{id}/grants/{userId}: Revoke the access of a user to a server
+func (client *Client) DeleteServerGrant(ctx context.Context, id string, userID string) error {
+	path := "/api/v1/servers/{id}/grants/{userId}"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	path = strings.Replace(path, "{userId}", url.PathEscape(userID), 1)
+	return client.do(ctx, "DELETE", path, query, nil, nil)
+}
+
+// GetServerLaunch calls GET /api/v1/servers/{id}/launch: Get how the JVM of a server is started
+func (client *Client) GetServerLaunch(ctx context.Context, id string) (*LaunchSettings, error) {
+	path := "/api/v1/servers/{id}/launch"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *LaunchSettings
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// UpdateServerLaunch calls PUT /api/v1/servers/{id}/launch: Change how the JVM of a server is started
+func (client *Client) UpdateServerLaunch(ctx context.Context, id string, body *LaunchSettings) (*LaunchSettings, error) {
+	path := "/api/v1/servers/{id}/launch"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *LaunchSettings
+	err := client.do(ctx, "PUT", path, query, body, &result)
+	return result, err
+}
+
+// GetServerLogs calls GET /api/v1/servers/{id}/logs: Get the last lines of the log of a server
+func (client *Client) GetServerLogs(ctx context.Context, id string, lines string) (string, error) {
+	path := "/api/v1/servers/{id}/logs"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	if lines != "" {
+		query.Set("lines", lines)
+	}
+	var result string
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// GetServerProperties calls GET /api/v1/servers/{id}/properties: Get the properties of a server
+func (client *Client) GetServerProperties(ctx context.Context, id string) (*ServerProperties, error) {
+	path := "/api/v1/servers/{id}/properties"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *ServerProperties
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// UpdateServerProperties calls PATCH /api/v1/servers/{id}/properties: Change the properties of a server
+func (client *Client) UpdateServerProperties(ctx context.Context, id string, body *PropertiesPatch) (*PropertiesUpdateResult, error) {
+	path := "/api/v1/servers/{id}/properties"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *PropertiesUpdateResult
+	err := client.do(ctx, "PATCH", path, query, body, &result)
+	return result, err
+}
+
+// GetServerPropertiesHistory calls GET /api/v1/servers/{id}/properties/history: List the property changes of a server
+func (client *Client) GetServerPropertiesHistory(ctx context.Context, id string) ([]PropertyChangeRecord, error) {
+	path := "/api/v1/servers/{id}/properties/history"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result []PropertyChangeRecord
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
+
+// RevertServerProperties calls POST /api/v1/servers/{id}/properties/revert: Revert the properties of a server to before a change
+func (client *Client) RevertServerProperties(ctx context.Context, id string, body *PropertiesRevert) (*PropertiesUpdateResult, error) {
+	path := "/api/v1/servers/{id}/properties/revert"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *PropertiesUpdateResult
+	err := client.do(ctx, "POST", path, query, body, &result)
+	return result, err
+}
+
+// StartServer calls POST /api/v1/servers/{id}/start: Start a server
+func (client *Client) StartServer(ctx context.Context, id string) (*ServerActionResult, error) {
+	path := "/api/v1/servers/{id}/start"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *ServerActionResult
+	err := client.do(ctx, "POST", path, query, nil, &result)
+	return result, err
+}
+
+// StopServer calls POST /api/v1/servers/{id}/stop: Stop a server
+func (client *Client) StopServer(ctx context.Context, id string) (*ServerActionResult, error) {
+	path := "/api/v1/servers/{id}/stop"
+	query := url.Values{}
+	path = strings.Replace(path, "{id}", url.PathEscape(id), 1)
+	var result *ServerActionResult
+	err := client.do(ctx, "POST", path, query, nil, &result)
+	return result, err
+}
+
+// Ping calls GET /ping: Check that gomine is up
+func (client *Client) Ping(ctx context.Context) (string, error) {
+	path := "/ping"
+	query := url.Values{}
+	var result string
+	err := client.do(ctx, "GET", path, query, nil, &result)
+	return result, err
+}
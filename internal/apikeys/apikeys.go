@@ -0,0 +1,313 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/google/uuid"
+)
+
+// KEY_PREFIX starts every API key so they can be told apart from JWTs and spotted by secret scanners
+const KEY_PREFIX = "gmn_"
+
+// LAST_USED_PRECISION is how stale the recorded last use of a key may get. It keeps busy
+// automation from writing to the database on every request.
+const LAST_USED_PRECISION = time.Minute
+
+// Scope is a group of actions an API key may perform on servers
+type Scope string
+
+const (
+	ScopeRead       Scope = "read"
+	ScopeStartStop  Scope = "start_stop"
+	ScopeConsole    Scope = "console"
+	ScopeProperties Scope = "properties"
+)
+
+var scopesByAction = map[permissions.Action]Scope{
+	permissions.ActionView:      ScopeRead,
+	permissions.ActionOperate:   ScopeStartStop,
+	permissions.ActionConsole:   ScopeConsole,
+	permissions.ActionConfigure: ScopeProperties,
+}
+
+// ErrInvalidAPIKey is returned for API keys that are unknown or revoked
+var ErrInvalidAPIKey = errors.New("Invalid API key")
+
+// ErrInvalidScopes is returned when creating an API key without scopes or with an unknown scope
+var ErrInvalidScopes = errors.New("Invalid API key scopes")
+
+// APIKey is a long-lived credential of a user restricted to a set of scopes. When ServerIDs is
+// empty the key applies to every server the user has access to.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []Scope    `json:"scopes"`
+	ServerIDs  []string   `json:"serverIds"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// APIKeyOptions describes an API key to create
+type APIKeyOptions struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []Scope  `json:"scopes" binding:"required"`
+	ServerIDs []string `json:"serverIds"`
+}
+
+// IsValidScope reports whether a scope is known
+func IsValidScope(scope Scope) bool {
+	for _, validScope := range scopesByAction {
+		if scope == validScope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Permits reports whether the key allows performing an action on a server. Managing access to
+// servers is never allowed through API keys.
+func (key *APIKey) Permits(serverId string, action permissions.Action) bool {
+	scope, ok := scopesByAction[action]
+
+	if !ok || !key.HasScope(scope) {
+		return false
+	}
+
+	return key.AppliesTo(serverId)
+}
+
+// HasScope reports whether the key was granted a scope
+func (key *APIKey) HasScope(scope Scope) bool {
+	for _, granted := range key.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AppliesTo reports whether the key is restricted to a set of servers that includes serverId
+func (key *APIKey) AppliesTo(serverId string) bool {
+	if len(key.ServerIDs) == 0 {
+		return true
+	}
+
+	for _, id := range key.ServerIDs {
+		if id == serverId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hashKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether a credential looks like an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, KEY_PREFIX)
+}
+
+func generateKey() (string, error) {
+	bytes := make([]byte, 32)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return KEY_PREFIX + base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CreateAPIKey stores a new API key for a user. The raw key is returned once and only its hash is
+// kept.
+func CreateAPIKey(db *sql.DB, userId string, options *APIKeyOptions) (*APIKey, string, error) {
+	if len(options.Scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: an API key needs at least one scope", ErrInvalidScopes)
+	}
+
+	for _, scope := range options.Scopes {
+		if !IsValidScope(scope) {
+			return nil, "", fmt.Errorf("%w: unknown scope `%v`", ErrInvalidScopes, scope)
+		}
+	}
+
+	rawKey, err := generateKey()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	serverIds := options.ServerIDs
+
+	if serverIds == nil {
+		serverIds = []string{}
+	}
+
+	key := APIKey{
+		ID:        id.String(),
+		UserID:    userId,
+		Name:      options.Name,
+		Prefix:    rawKey[:len(KEY_PREFIX)+6],
+		Scopes:    options.Scopes,
+		ServerIDs: serverIds,
+		CreatedAt: time.Unix(time.Now().Unix(), 0),
+	}
+
+	if err := insertAPIKey(db, &key, hashKey(rawKey)); err != nil {
+		return nil, "", err
+	}
+
+	return &key, rawKey, nil
+}
+
+func insertAPIKey(db *sql.DB, key *APIKey, hash string) error {
+	scopes, err := json.Marshal(key.Scopes)
+
+	if err != nil {
+		return err
+	}
+
+	serverIds, err := json.Marshal(key.ServerIDs)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"insert into api_keys(id, user_id, name, prefix, hash, scopes, server_ids, created_at) values(?, ?, ?, ?, ?, ?, ?, ?)",
+		key.ID, key.UserID, key.Name, key.Prefix, hash, string(scopes), string(serverIds), key.CreatedAt.Unix(),
+	)
+
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	key := APIKey{}
+	var scopes, serverIds string
+	var createdAt int64
+	var lastUsedAt sql.NullInt64
+
+	if err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &scopes, &serverIds, &createdAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(serverIds), &key.ServerIDs); err != nil {
+		return nil, err
+	}
+
+	key.CreatedAt = time.Unix(createdAt, 0)
+
+	if lastUsedAt.Valid {
+		lastUsed := time.Unix(lastUsedAt.Int64, 0)
+		key.LastUsedAt = &lastUsed
+	}
+
+	return &key, nil
+}
+
+// ListAPIKeys returns the API keys of a user that have not been revoked
+func ListAPIKeys(db *sql.DB, userId string) ([]APIKey, error) {
+	rows, err := db.Query(
+		"select id, user_id, name, prefix, scopes, server_ids, created_at, last_used_at from api_keys where user_id=? and revoked_at is null order by created_at",
+		userId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	keys := []APIKey{}
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes one of a user's API keys
+func RevokeAPIKey(db *sql.DB, userId string, keyId string) error {
+	result, err := db.Exec("update api_keys set revoked_at=? where id=? and user_id=? and revoked_at is null", time.Now().Unix(), keyId, userId)
+
+	if err != nil {
+		return err
+	}
+
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return fmt.Errorf("Could not find API key with id `%v`: %w", keyId, sql.ErrNoRows)
+	}
+
+	return err
+}
+
+// Authenticate looks up the API key matching a raw key and records that it was used
+func Authenticate(db *sql.DB, rawKey string) (*APIKey, error) {
+	// Keys of disabled users stop working without being revoked so re-enabling restores them
+	row := db.QueryRow(
+		`select api_keys.id, api_keys.user_id, api_keys.name, api_keys.prefix, api_keys.scopes, api_keys.server_ids,
+			api_keys.created_at, api_keys.last_used_at from api_keys
+			join users on users.id=api_keys.user_id
+			where api_keys.hash=? and api_keys.revoked_at is null and not users.disabled`,
+		hashKey(rawKey),
+	)
+	key, err := scanAPIKey(row)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= LAST_USED_PRECISION {
+		if _, err := db.Exec("update api_keys set last_used_at=? where id=?", now.Unix(), key.ID); err != nil {
+			return nil, err
+		}
+
+		key.LastUsedAt = &now
+	}
+
+	return key, nil
+}
@@ -0,0 +1,24 @@
+package apikeys
+
+import (
+	"testing"
+
+	"github.com/ecuyle/gomine/internal/permissions"
+	"gotest.tools/assert"
+)
+
+// Test Permits and assert that API keys are limited to their scopes and servers
+func TestPermits(t *testing.T) {
+	key := APIKey{Scopes: []Scope{ScopeRead, ScopeStartStop}, ServerIDs: []string{"a"}}
+
+	assert.Assert(t, key.Permits("a", permissions.ActionView))
+	assert.Assert(t, key.Permits("a", permissions.ActionOperate))
+	assert.Assert(t, !key.Permits("a", permissions.ActionConfigure))
+	assert.Assert(t, !key.Permits("a", permissions.ActionManage))
+	assert.Assert(t, !key.Permits("b", permissions.ActionView))
+
+	unrestricted := APIKey{Scopes: []Scope{ScopeConsole}}
+
+	assert.Assert(t, unrestricted.Permits("b", permissions.ActionConsole))
+	assert.Assert(t, !unrestricted.Permits("b", permissions.ActionView))
+}
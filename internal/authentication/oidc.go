@@ -0,0 +1,122 @@
+package authentication
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/oidc"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/user"
+	"github.com/gin-gonic/gin"
+)
+
+// getOIDCProvider returns the configured identity provider. The response has been written when
+// nil is returned.
+func getOIDCProvider(c *gin.Context) *oidc.Provider {
+	provider := oidc.FromContext(c)
+
+	if provider == nil {
+		httputils.RespondWithError(c, httputils.NotFound("single sign-on is not configured."))
+		return nil
+	}
+
+	return provider
+}
+
+// StartOIDCLogin sends the user to the identity provider to log in
+func StartOIDCLogin(c *gin.Context) {
+	provider := getOIDCProvider(c)
+
+	if provider == nil {
+		return
+	}
+
+	state, err := oidc.CreateLoginState(store.FromContext(c).DB)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(c, err)
+		return
+	}
+
+	authorizationUrl, err := provider.AuthorizationURL(c.Request.Context(), state.State, state.Nonce, state.CodeVerifier)
+
+	if err != nil {
+		log.Printf("Could not reach the identity provider: %v", err)
+		httputils.RespondWithError(c, httputils.BadGateway("could not reach the identity provider."))
+		return
+	}
+
+	c.Redirect(http.StatusFound, authorizationUrl)
+}
+
+// CompleteOIDCLogin handles the identity provider redirecting the user back. The authorization
+// code is exchanged for an ID token, whose identity is mapped to a gomine account that gets the
+// usual gomine tokens.
+func CompleteOIDCLogin(c *gin.Context) {
+	provider := getOIDCProvider(c)
+
+	if provider == nil {
+		return
+	}
+
+	dataStore := store.FromContext(c)
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		httputils.RespondWithError(c, httputils.Unauthorized(fmt.Sprintf("identity provider refused the login: %v %v", errorCode, c.Query("error_description"))))
+		return
+	}
+
+	state, err := oidc.ConsumeLoginState(dataStore.DB, c.Query("state"))
+
+	if errors.Is(err, oidc.ErrInvalidState) {
+		httputils.RespondWithError(c, httputils.BadRequest(err.Error()))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(c, err)
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), state.CodeVerifier, state.Nonce)
+
+	if err != nil {
+		log.Printf("Could not verify a login with the identity provider: %v", err)
+		httputils.RespondWithError(c, httputils.Unauthorized("could not verify the login with the identity provider."))
+		return
+	}
+
+	options := user.IdentityLinkOptions{AutoProvision: provider.Config.AutoProvision, LinkByEmail: provider.Config.LinkByEmail}
+
+	if provider.MapsRoles() {
+		options.Role = permissions.RoleUser
+
+		if provider.IsAdmin(identity) {
+			options.Role = permissions.RoleAdmin
+		}
+	}
+
+	ip := c.ClientIP()
+	account, err := user.ResolveExternalIdentity(dataStore, identity, &options)
+
+	if errors.Is(err, user.ErrNoLinkedAccount) {
+		logLoginAttempt(dataStore.DB, identity.Username, "", ip, LoginFailed)
+		httputils.RespondWithError(c, httputils.Forbidden("no gomine account is linked to this identity."))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(c, err)
+		return
+	}
+
+	if !checkAccountCanLogIn(c, account, account.Username, ip) {
+		return
+	}
+
+	completeLogin(c, account, account.Username, ip)
+}
@@ -2,10 +2,18 @@ package authentication
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/ecuyle/gomine/internal/config"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/mfa"
 	"github.com/ecuyle/gomine/internal/passwords"
+	"github.com/ecuyle/gomine/internal/store"
 	"github.com/ecuyle/gomine/internal/token"
 	"github.com/ecuyle/gomine/internal/user"
 	"github.com/gin-gonic/gin"
@@ -16,67 +24,284 @@ type AuthenticationOptions struct {
 	Password string `json:"password" binding:"required"`
 }
 
-func retrieveAccessTokenIfCredentialsValid(username, password string) (string, error) {
-	db, err := sql.Open("sqlite3", "./gomine.db")
+// MFAOptions completes a login of a user with 2FA enabled. Either Code or RecoveryCode is required.
+type MFAOptions struct {
+	MFAToken     string `json:"mfaToken" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// MFAChallengeResponse is returned instead of tokens when a user with 2FA enabled logs in
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+}
+
+type RefreshOptions struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// retrieveUserIfCredentialsValid looks up the user with the given username and checks their password.
+// When the password is wrong the user is returned alongside the error so the attempt can be
+// attributed to them. Unknown usernames still go through a password comparison so they cannot be
+// told apart by response time.
+func retrieveUserIfCredentialsValid(users store.UserRepository, username, password string) (*user.User, error) {
+	user, err := users.GetByUsername(strings.TrimSpace(username))
 
 	if err != nil {
-		return "", err
+		passwords.CompareWithDummyHash(password)
+		return nil, err
 	}
 
-	defer db.Close()
-	transaction, err := db.Begin()
+	err = passwords.ComparePasswordWithHash(password, user.Hash)
 
 	if err != nil {
-		return "", err
+		return user, err
 	}
 
-	fmt.Println("3")
-	statement, err := transaction.Prepare("select * from users where username=?")
+	return user, nil
+}
+
+func AuthenticateUser(c *gin.Context) {
+	var options AuthenticationOptions
+
+	if err := c.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(c, err)
+		return
+	}
+
+	dataStore := store.FromContext(c)
+	ip := c.ClientIP()
+	keys := throttleKeys(options.Username, ip)
+	wait, err := checkLoginThrottle(dataStore.DB, keys)
 
 	if err != nil {
-		return "", err
+		httputils.RespondWithInternalServerError(c, err)
+		return
 	}
 
-	defer statement.Close()
+	if wait > 0 {
+		logLoginAttempt(dataStore.DB, options.Username, "", ip, LoginLocked)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		httputils.RespondWithError(c, httputils.TooManyRequests("too many failed login attempts. Try again later."))
+		return
+	}
 
-	user := user.User{}
-	err = statement.QueryRow(username).Scan(&user.ID, &user.Username, &user.Hash)
+	user, err := retrieveUserIfCredentialsValid(dataStore.Users, options.Username, options.Password)
 
 	if err != nil {
-		return "", err
+		fmt.Println(err.Error())
+
+		userId := ""
+
+		if user != nil {
+			userId = user.ID
+		}
+
+		logLoginAttempt(dataStore.DB, options.Username, userId, ip, LoginFailed)
+
+		if err := recordLoginFailure(dataStore.DB, newThrottlePolicy(&config.FromContext(c).Login), keys); err != nil {
+			fmt.Println(err.Error())
+		}
+
+		httputils.RespondWithError(c, httputils.BadRequest("username or password is incorrect."))
+		return
 	}
 
-	err = passwords.ComparePasswordWithHash(password, user.Hash)
+	// Disabled and unapproved accounts are only revealed to callers who know the password
+	if !checkAccountCanLogIn(c, user, options.Username, ip) {
+		return
+	}
+
+	if err := resetLoginThrottle(dataStore.DB, keys[0]); err != nil {
+		fmt.Println(err.Error())
+	}
+
+	completeLogin(c, user, options.Username, ip)
+}
+
+// checkAccountCanLogIn rejects disabled accounts and accounts awaiting approval. The response has
+// been written when false is returned.
+func checkAccountCanLogIn(c *gin.Context, user *user.User, username string, ip string) bool {
+	db := store.FromContext(c).DB
+
+	if user.Disabled {
+		logLoginAttempt(db, username, user.ID, ip, LoginDisabled)
+		httputils.RespondWithError(c, httputils.Forbidden("this account is disabled."))
+		return false
+	}
+
+	if user.PendingApproval {
+		logLoginAttempt(db, username, user.ID, ip, LoginPendingApproval)
+		httputils.RespondWithError(c, httputils.Forbidden("this account is awaiting approval by an admin."))
+		return false
+	}
+
+	return true
+}
+
+// completeLogin issues a session for a user who proved their identity, or an MFA challenge if
+// they enabled 2FA
+func completeLogin(c *gin.Context, user *user.User, username string, ip string) {
+	db := store.FromContext(c).DB
+	mfaEnabled, err := mfa.IsEnabled(db, user.ID)
 
 	if err != nil {
-		return "", err
+		httputils.RespondWithInternalServerError(c, err)
+		return
 	}
 
-	token, err := token.GenerateToken(user.ID)
+	if mfaEnabled {
+		challenge, err := token.FromContext(c).GenerateMFAChallengeToken(user.ID, user.Username)
+
+		if err != nil {
+			httputils.RespondWithInternalServerError(c, err)
+			return
+		}
+
+		logLoginAttempt(db, username, user.ID, ip, LoginMFARequired)
+		c.JSON(http.StatusOK, MFAChallengeResponse{MFARequired: true, MFAToken: challenge})
+		return
+	}
+
+	logLoginAttempt(db, username, user.ID, ip, LoginSucceeded)
+
+	tokens, err := token.FromContext(c).IssueTokenPair(db, user.ID)
 
 	if err != nil {
-		return "", err
+		httputils.RespondWithInternalServerError(c, err)
+		return
 	}
 
-	return token, nil
+	c.JSON(http.StatusOK, tokens)
 }
 
-func AuthenticateUser(c *gin.Context) {
-	var options AuthenticationOptions
+// AuthenticateMFAChallenge completes a login that was challenged for a second factor. Failed codes
+// are throttled like failed passwords.
+func AuthenticateMFAChallenge(c *gin.Context) {
+	var options MFAOptions
 
 	if err := c.ShouldBindJSON(&options); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		httputils.RespondWithInvalidBody(c, err)
 		return
 	}
 
-	token, err := retrieveAccessTokenIfCredentialsValid(options.Username, options.Password)
+	if (options.Code == "") == (options.RecoveryCode == "") {
+		httputils.RespondWithError(c, httputils.BadRequest("either code or recoveryCode is required."))
+		return
+	}
+
+	challenge, err := token.FromContext(c).ParseMFAChallengeToken(options.MFAToken)
 
 	if err != nil {
+		httputils.RespondWithError(c, httputils.Unauthorized("mfaToken is invalid or expired. Log in again."))
+		return
+	}
+
+	db := store.FromContext(c).DB
+	ip := c.ClientIP()
+	keys := []string{"mfa:" + challenge.UserID, "ip:" + ip}
+	wait, err := checkLoginThrottle(db, keys)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(c, err)
+		return
+	}
+
+	if wait > 0 {
+		logLoginAttempt(db, challenge.Username, challenge.UserID, ip, LoginLocked)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		httputils.RespondWithError(c, httputils.TooManyRequests("too many failed login attempts. Try again later."))
+		return
+	}
+
+	if options.Code != "" {
+		err = mfa.Verify(db, challenge.UserID, options.Code)
+	} else {
+		err = mfa.VerifyRecoveryCode(db, challenge.UserID, options.RecoveryCode)
+	}
+
+	if errors.Is(err, mfa.ErrInvalidCode) || errors.Is(err, mfa.ErrNotEnabled) {
+		logLoginAttempt(db, challenge.Username, challenge.UserID, ip, LoginMFAFailed)
+
+		if err := recordLoginFailure(db, newThrottlePolicy(&config.FromContext(c).Login), keys); err != nil {
+			fmt.Println(err.Error())
+		}
+
+		httputils.RespondWithError(c, httputils.BadRequest("two-factor authentication code is incorrect."))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(c, err)
+		return
+	}
+
+	err = token.CompleteMFAChallenge(db, challenge)
+
+	if errors.Is(err, token.ErrMFAChallengeUsed) {
+		httputils.RespondWithError(c, httputils.Unauthorized("mfaToken is invalid or expired. Log in again."))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(c, err)
+		return
+	}
+
+	logLoginAttempt(db, challenge.Username, challenge.UserID, ip, LoginSucceeded)
+
+	if err := resetLoginThrottle(db, keys[0]); err != nil {
+		fmt.Println(err.Error())
+	}
+
+	tokens, err := token.FromContext(c).IssueTokenPair(db, challenge.UserID)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// logLoginAttempt records a login attempt in the audit. Failing to audit does not block logging in.
+func logLoginAttempt(db *sql.DB, username string, userId string, ip string, outcome string) {
+	if err := recordLoginAttempt(db, username, userId, ip, outcome); err != nil {
 		fmt.Println(err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username or password is incorrect."})
+	}
+}
+
+// RefreshAccessToken exchanges a refresh token for a new access token and a rotated refresh token
+func RefreshAccessToken(c *gin.Context) {
+	var options RefreshOptions
+
+	if err := c.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(c, err)
+		return
+	}
+
+	tokens, err := token.FromContext(c).RefreshTokenPair(store.FromContext(c).DB, options.RefreshToken)
+
+	if errors.Is(err, token.ErrInvalidRefreshToken) || errors.Is(err, token.ErrRefreshTokenReused) {
+		httputils.RespondWithError(c, httputils.Unauthorized(err.Error()))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"accessToken": token})
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the access token of the request and the session it belongs to
+func Logout(c *gin.Context) {
+	if err := token.RevokeSession(store.FromContext(c).DB, token.GetAccessTokenClaims(c)); err != nil {
+		httputils.RespondWithInternalServerError(c, err)
+		return
+	}
 
+	c.Status(http.StatusNoContent)
 }
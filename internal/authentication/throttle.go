@@ -0,0 +1,160 @@
+package authentication
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/config"
+	"github.com/google/uuid"
+)
+
+const (
+	// LOGIN_BACKOFF_THRESHOLD is how many failed logins are tolerated before delays start
+	LOGIN_BACKOFF_THRESHOLD = 3
+	// LOGIN_BACKOFF_BASE is the delay after the first failure past LOGIN_BACKOFF_THRESHOLD. It
+	// doubles with every further failure.
+	LOGIN_BACKOFF_BASE = time.Second
+)
+
+// Outcomes of login attempts recorded in the login audit
+const (
+	LoginSucceeded       = "success"
+	LoginFailed          = "failure"
+	LoginLocked          = "locked"
+	LoginDisabled        = "disabled"
+	LoginPendingApproval = "pending_approval"
+	LoginMFARequired     = "mfa_required"
+	LoginMFAFailed       = "mfa_failure"
+)
+
+type throttlePolicy struct {
+	MaxFailures     int
+	LockoutDuration time.Duration
+}
+
+func newThrottlePolicy(config *config.LoginConfig) *throttlePolicy {
+	return &throttlePolicy{MaxFailures: config.MaxFailures, LockoutDuration: time.Duration(config.LockoutMinutes) * time.Minute}
+}
+
+// delayAfter returns how long logins are refused after a number of consecutive failures. Delays
+// grow exponentially past LOGIN_BACKOFF_THRESHOLD and become a full lockout at MaxFailures.
+func (policy *throttlePolicy) delayAfter(failures int) time.Duration {
+	if failures >= policy.MaxFailures {
+		return policy.LockoutDuration
+	}
+
+	if failures < LOGIN_BACKOFF_THRESHOLD {
+		return 0
+	}
+
+	delay := LOGIN_BACKOFF_BASE << (failures - LOGIN_BACKOFF_THRESHOLD)
+
+	if delay > policy.LockoutDuration || delay <= 0 {
+		return policy.LockoutDuration
+	}
+
+	return delay
+}
+
+// throttleKeys returns the keys failed logins are counted under: the username and the client IP
+func throttleKeys(username string, ip string) []string {
+	return []string{
+		"user:" + strings.ToLower(strings.TrimSpace(username)),
+		"ip:" + ip,
+	}
+}
+
+// checkLoginThrottle returns how long the caller has to wait before trying to log in again, which
+// is the longest remaining lock of the keys, or zero if none of them is currently locked
+func checkLoginThrottle(db *sql.DB, keys []string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+
+	for _, key := range keys {
+		var lockedUntil int64
+		err := db.QueryRow("select locked_until from login_throttles where key=?", key).Scan(&lockedUntil)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if remaining := time.Unix(lockedUntil, 0).Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+
+	return wait, nil
+}
+
+// recordLoginFailure counts a failed login against every key. Failures are forgotten once a key
+// has not failed for a full lockout duration.
+func recordLoginFailure(db *sql.DB, policy *throttlePolicy, keys []string) error {
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	now := time.Now()
+
+	for _, key := range keys {
+		failures := 0
+		var lastFailureAt int64
+		err := transaction.QueryRow("select failures, last_failure_at from login_throttles where key=?", key).Scan(&failures, &lastFailureAt)
+
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if now.Sub(time.Unix(lastFailureAt, 0)) > policy.LockoutDuration {
+			failures = 0
+		}
+
+		failures++
+		lockedUntil := now.Add(policy.delayAfter(failures)).Unix()
+
+		_, err = transaction.Exec(
+			`insert into login_throttles(key, failures, last_failure_at, locked_until) values(?, ?, ?, ?)
+				on conflict(key) do update set failures=excluded.failures, last_failure_at=excluded.last_failure_at, locked_until=excluded.locked_until`,
+			key, failures, now.Unix(), lockedUntil,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return transaction.Commit()
+}
+
+// resetLoginThrottle forgets the failed logins counted against a key
+func resetLoginThrottle(db *sql.DB, key string) error {
+	_, err := db.Exec("delete from login_throttles where key=?", key)
+
+	return err
+}
+
+// recordLoginAttempt adds an attempt to the login audit. userId is empty when the username did
+// not match any user.
+func recordLoginAttempt(db *sql.DB, username string, userId string, ip string, outcome string) error {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"insert into login_audit(id, username, user_id, ip, outcome, created_at) values(?, ?, ?, ?, ?, ?)",
+		id.String(), username, sql.NullString{String: userId, Valid: userId != ""}, ip, outcome, time.Now().Unix(),
+	)
+
+	return err
+}
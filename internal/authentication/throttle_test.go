@@ -0,0 +1,20 @@
+package authentication
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// Test delayAfter and assert that delays grow exponentially and end in a lockout
+func TestDelayAfter(t *testing.T) {
+	policy := throttlePolicy{MaxFailures: 8, LockoutDuration: 15 * time.Minute}
+
+	assert.Equal(t, time.Duration(0), policy.delayAfter(2))
+	assert.Equal(t, time.Second, policy.delayAfter(3))
+	assert.Equal(t, 4*time.Second, policy.delayAfter(5))
+	assert.Equal(t, 16*time.Second, policy.delayAfter(7))
+	assert.Equal(t, 15*time.Minute, policy.delayAfter(8))
+	assert.Equal(t, 15*time.Minute, policy.delayAfter(80))
+}
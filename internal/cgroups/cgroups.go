@@ -0,0 +1,385 @@
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/ecuyle/gomine/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// MANAGER_CONTEXT_KEY is the gin context key holding the manager requests are served with
+const MANAGER_CONTEXT_KEY = "cgroups"
+
+// MOUNT_POINT is where the cgroup v2 hierarchy is mounted
+const MOUNT_POINT = "/sys/fs/cgroup"
+
+// CPU_PERIOD is the period in microseconds CPU quotas are enforced over
+const CPU_PERIOD = 100000
+
+// CONTROLLERS are the controllers limits are enforced with
+var CONTROLLERS = []string{"cpu", "memory", "pids", "io"}
+
+var (
+	ErrDisabled              = errors.New("Resource limits are disabled")
+	ErrUnavailable           = errors.New("cgroup v2 is not available")
+	ErrControllerUnavailable = errors.New("Controller is not available")
+)
+
+// Limits are the resources a server process may use. Limits of 0 are not enforced.
+type Limits struct {
+	// CPUWeight is the share of CPU time the server gets when CPUs are busy, from 1 to 10000. The
+	// kernel gives every cgroup 100 by default.
+	CPUWeight int `json:"cpuWeight"`
+	// CPUQuotaPercent is the most CPU time the server gets, in percent of one CPU
+	CPUQuotaPercent int `json:"cpuQuotaPercent"`
+	MemoryMaxMB     int `json:"memoryMaxMb"`
+	PidsMax         int `json:"pidsMax"`
+	// IOWeight is the share of disk time the server gets when disks are busy, from 1 to 10000
+	IOWeight int `json:"ioWeight"`
+}
+
+// Usage is the resources the processes of a server currently use
+type Usage struct {
+	MemoryBytes int64 `json:"memoryBytes"`
+	// MemoryPeakBytes is 0 on kernels before 5.19
+	MemoryPeakBytes  int64 `json:"memoryPeakBytes"`
+	OOMKills         int64 `json:"oomKills"`
+	CPUUsec          int64 `json:"cpuUsec"`
+	CPUThrottledUsec int64 `json:"cpuThrottledUsec"`
+	Pids             int64 `json:"pids"`
+}
+
+// setting is a cgroup interface file written to enforce a limit
+type setting struct {
+	controller string
+	file       string
+	value      string
+	limited    bool
+}
+
+// settings returns every interface file of limits, with the kernel defaults for limits of 0 so a
+// reused cgroup does not keep old limits
+func (limits *Limits) settings() []setting {
+	cpuWeight, cpuQuota, memoryMax, pidsMax, ioWeight := "100", "max", "max", "max", "100"
+
+	if limits.CPUWeight > 0 {
+		cpuWeight = strconv.Itoa(limits.CPUWeight)
+	}
+
+	if limits.CPUQuotaPercent > 0 {
+		cpuQuota = strconv.Itoa(limits.CPUQuotaPercent * CPU_PERIOD / 100)
+	}
+
+	if limits.MemoryMaxMB > 0 {
+		memoryMax = strconv.FormatInt(int64(limits.MemoryMaxMB)*1024*1024, 10)
+	}
+
+	if limits.PidsMax > 0 {
+		pidsMax = strconv.Itoa(limits.PidsMax)
+	}
+
+	if limits.IOWeight > 0 {
+		ioWeight = strconv.Itoa(limits.IOWeight)
+	}
+
+	return []setting{
+		{controller: "cpu", file: "cpu.weight", value: cpuWeight, limited: limits.CPUWeight > 0},
+		{controller: "cpu", file: "cpu.max", value: cpuQuota + " " + strconv.Itoa(CPU_PERIOD), limited: limits.CPUQuotaPercent > 0},
+		{controller: "memory", file: "memory.max", value: memoryMax, limited: limits.MemoryMaxMB > 0},
+		{controller: "pids", file: "pids.max", value: pidsMax, limited: limits.PidsMax > 0},
+		{controller: "io", file: "io.weight", value: "default " + ioWeight, limited: limits.IOWeight > 0},
+	}
+}
+
+// IsZero reports whether no limit is set
+func (limits *Limits) IsZero() bool {
+	return *limits == Limits{}
+}
+
+// Manager confines server processes to cgroups created in a root cgroup. A nil manager confines
+// nothing, which is how hosts without cgroup v2 run servers.
+type Manager struct {
+	// Root is the cgroup server cgroups are created in
+	Root string
+	// Controllers are the controllers enabled in server cgroups
+	Controllers map[string]bool
+}
+
+// New sets up the cgroup servers are confined in, below the configured cgroup or the cgroup of
+// gomine. The cgroup must be delegated to the user gomine runs as, like systemd does for services
+// with Delegate=yes.
+func New(cgroupsConfig *config.CgroupsConfig) (*Manager, error) {
+	if !cgroupsConfig.Enabled {
+		return nil, ErrDisabled
+	}
+
+	parent := cgroupsConfig.Root
+
+	if parent == "" {
+		own, err := ownCgroup()
+
+		if err != nil {
+			return nil, err
+		}
+
+		parent = filepath.Join(MOUNT_POINT, own)
+	}
+
+	return setup(parent)
+}
+
+// ownCgroup returns the cgroup v2 path of the gomine process, like /system.slice/gomine.service
+func ownCgroup() (string, error) {
+	contents, err := os.ReadFile("/proc/self/cgroup")
+
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: gomine is not in a cgroup v2 hierarchy", ErrUnavailable)
+}
+
+// setup creates the servers cgroup in parent and enables the controllers of CONTROLLERS that
+// parent has in it
+func setup(parent string) (*Manager, error) {
+	available, err := readControllers(filepath.Join(parent, "cgroup.controllers"))
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	// Only the root cgroup, which has no cgroup.type, may have processes and hand controllers to
+	// its children at once. Anywhere else gomine moves out of the way into a leaf cgroup.
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.type")); err == nil {
+		if err := moveProcesses(parent, filepath.Join(parent, "gomine")); err != nil {
+			return nil, err
+		}
+	}
+
+	root := filepath.Join(parent, "servers")
+
+	if err := os.Mkdir(root, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, err
+	}
+
+	enabled := enableControllers(root, enableControllers(parent, available))
+
+	return &Manager{Root: root, Controllers: enabled}, nil
+}
+
+// readControllers reads a list of controllers, like cgroup.controllers
+func readControllers(path string) (map[string]bool, error) {
+	contents, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	controllers := map[string]bool{}
+
+	for _, controller := range strings.Fields(string(contents)) {
+		controllers[controller] = true
+	}
+
+	return controllers, nil
+}
+
+// moveProcesses moves every process of a cgroup to another one, which is created if needed
+func moveProcesses(from string, to string) error {
+	contents, err := os.ReadFile(filepath.Join(from, "cgroup.procs"))
+
+	if err != nil {
+		return err
+	}
+
+	pids := strings.Fields(string(contents))
+
+	if len(pids) == 0 {
+		return nil
+	}
+
+	if err := os.Mkdir(to, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	for _, pid := range pids {
+		// Processes that exited in the meantime cannot be found anymore
+		if err := write(filepath.Join(to, "cgroup.procs"), pid); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enableControllers enables the controllers of CONTROLLERS that are wanted for the children of a
+// cgroup and returns those that could be enabled
+func enableControllers(cgroup string, wanted map[string]bool) map[string]bool {
+	enabled := map[string]bool{}
+
+	for _, controller := range CONTROLLERS {
+		if wanted[controller] && write(filepath.Join(cgroup, "cgroup.subtree_control"), "+"+controller) == nil {
+			enabled[controller] = true
+		}
+	}
+
+	return enabled
+}
+
+func write(path string, value string) error {
+	return os.WriteFile(path, []byte(value), 0644)
+}
+
+func (manager *Manager) path(serverId string) string {
+	return filepath.Join(manager.Root, serverId)
+}
+
+// Open applies limits to the cgroup of a server and opens it, so the server can be started inside
+// it. The other limits are still enforced when one cannot be, which is reported with
+// ErrControllerUnavailable along with the cgroup. A nil manager opens no cgroup.
+func (manager *Manager) Open(serverId string, limits *Limits) (*os.File, error) {
+	if manager == nil {
+		if limits.IsZero() {
+			return nil, nil
+		}
+
+		return nil, ErrUnavailable
+	}
+
+	path := manager.path(serverId)
+
+	if err := os.Mkdir(path, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, err
+	}
+
+	unenforced := []string{}
+
+	for _, setting := range limits.settings() {
+		if !manager.Controllers[setting.controller] {
+			if setting.limited {
+				unenforced = append(unenforced, setting.file)
+			}
+
+			continue
+		}
+
+		if err := write(filepath.Join(path, setting.file), setting.value); err != nil {
+			return nil, fmt.Errorf("Could not write %v: %w", setting.file, err)
+		}
+	}
+
+	cgroup, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(unenforced) > 0 {
+		return cgroup, fmt.Errorf("%w: %v cannot be limited", ErrControllerUnavailable, strings.Join(unenforced, ", "))
+	}
+
+	return cgroup, nil
+}
+
+// Attach moves a process that was started outside of its cgroup into a cgroup opened with Open
+func Attach(cgroup *os.File, pid int) error {
+	return write(filepath.Join(cgroup.Name(), "cgroup.procs"), strconv.Itoa(pid))
+}
+
+// readInt reads an interface file holding a single number. Missing files and values like max
+// read as 0.
+func readInt(path string) int64 {
+	contents, err := os.ReadFile(path)
+
+	if err != nil {
+		return 0
+	}
+
+	value, _ := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+
+	return value
+}
+
+// readKeyed reads an interface file holding a number per key, like cpu.stat
+func readKeyed(path string) map[string]int64 {
+	contents, err := os.ReadFile(path)
+	values := map[string]int64{}
+
+	if err != nil {
+		return values
+	}
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) != 2 {
+			continue
+		}
+
+		values[fields[0]], _ = strconv.ParseInt(fields[1], 10, 64)
+	}
+
+	return values
+}
+
+// Usage reads the resources the processes of a server use from its cgroup
+func (manager *Manager) Usage(serverId string) (*Usage, error) {
+	if manager == nil {
+		return nil, ErrUnavailable
+	}
+
+	cgroup := manager.path(serverId)
+
+	if _, err := os.Stat(cgroup); err != nil {
+		return nil, err
+	}
+
+	cpu := readKeyed(filepath.Join(cgroup, "cpu.stat"))
+
+	return &Usage{
+		MemoryBytes:      readInt(filepath.Join(cgroup, "memory.current")),
+		MemoryPeakBytes:  readInt(filepath.Join(cgroup, "memory.peak")),
+		OOMKills:         readKeyed(filepath.Join(cgroup, "memory.events"))["oom_kill"],
+		CPUUsec:          cpu["usage_usec"],
+		CPUThrottledUsec: cpu["throttled_usec"],
+		Pids:             readInt(filepath.Join(cgroup, "pids.current")),
+	}, nil
+}
+
+// Remove deletes the cgroup of a server once its processes have exited
+func (manager *Manager) Remove(serverId string) error {
+	if manager == nil {
+		return nil
+	}
+
+	if err := os.Remove(manager.path(serverId)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Middleware makes a manager available to every handler through FromContext
+func Middleware(manager *Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(MANAGER_CONTEXT_KEY, manager)
+		c.Next()
+	}
+}
+
+// FromContext returns the manager set by Middleware, which is nil when servers are not confined
+func FromContext(c *gin.Context) *Manager {
+	return c.MustGet(MANAGER_CONTEXT_KEY).(*Manager)
+}
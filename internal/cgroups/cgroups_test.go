@@ -0,0 +1,71 @@
+package cgroups
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func readFile(t *testing.T, path string) string {
+	contents, err := os.ReadFile(path)
+	assert.NilError(t, err)
+
+	return string(contents)
+}
+
+// Test setup against a fake cgroup tree and assert that gomine moves into a leaf and that only the
+// available controllers are enabled
+func TestSetup(t *testing.T) {
+	parent := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(parent, "cgroup.controllers"), []byte("cpu memory pids\n"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(parent, "cgroup.type"), []byte("domain\n"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(parent, "cgroup.procs"), []byte("42\n"), 0644))
+
+	manager, err := setup(parent)
+	assert.NilError(t, err)
+
+	assert.Equal(t, manager.Root, filepath.Join(parent, "servers"))
+	assert.DeepEqual(t, manager.Controllers, map[string]bool{"cpu": true, "memory": true, "pids": true})
+	assert.Equal(t, readFile(t, filepath.Join(parent, "gomine", "cgroup.procs")), "42")
+
+	_, err = setup(t.TempDir())
+	assert.Assert(t, errors.Is(err, ErrUnavailable))
+}
+
+// Test Open, Attach and Usage against a fake cgroup tree and assert that limits are written, that
+// limits of missing controllers are reported and that usage is read back
+func TestOpen(t *testing.T) {
+	manager := &Manager{Root: t.TempDir(), Controllers: map[string]bool{"cpu": true, "memory": true, "pids": true}}
+	limits := &Limits{CPUQuotaPercent: 150, MemoryMaxMB: 2048, IOWeight: 200}
+
+	cgroup, err := manager.Open("s1", limits)
+	assert.Assert(t, errors.Is(err, ErrControllerUnavailable))
+	assert.ErrorContains(t, err, "io.weight")
+	defer cgroup.Close()
+
+	path := filepath.Join(manager.Root, "s1")
+	assert.Equal(t, cgroup.Name(), path)
+	assert.Equal(t, readFile(t, filepath.Join(path, "cpu.max")), "150000 100000")
+	assert.Equal(t, readFile(t, filepath.Join(path, "cpu.weight")), "100")
+	assert.Equal(t, readFile(t, filepath.Join(path, "memory.max")), "2147483648")
+	assert.Equal(t, readFile(t, filepath.Join(path, "pids.max")), "max")
+
+	assert.NilError(t, Attach(cgroup, 42))
+	assert.Equal(t, readFile(t, filepath.Join(path, "cgroup.procs")), "42")
+
+	assert.NilError(t, os.WriteFile(filepath.Join(path, "memory.current"), []byte("1048576\n"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(path, "cpu.stat"), []byte("usage_usec 5000\nuser_usec 4000\nthrottled_usec 10\n"), 0644))
+
+	usage, err := manager.Usage("s1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, *usage, Usage{MemoryBytes: 1048576, CPUUsec: 5000, CPUThrottledUsec: 10})
+
+	var unavailable *Manager
+	cgroup, err = unavailable.Open("s1", &Limits{})
+	assert.Assert(t, cgroup == nil && err == nil)
+	_, err = unavailable.Open("s1", limits)
+	assert.Assert(t, errors.Is(err, ErrUnavailable))
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/gin-gonic/gin"
+)
+
+// CONFIG_CONTEXT_KEY is the gin context key holding the configuration requests are served with
+const CONFIG_CONTEXT_KEY = "config"
+
+// REDACTED replaces secrets when the configuration is shown
+const REDACTED = "[redacted]"
+
+// Config is everything gomine can be configured with. Every field can be set in the config file
+// under its json name, with the environment variable in its env tag, or with a flag named after
+// that variable, like -listen-address for LISTEN_ADDRESS. Fields tagged secret are redacted when
+// the configuration is shown.
+type Config struct {
+	Server        ServerConfig        `json:"server"`
+	Ports         PortsConfig         `json:"ports"`
+	Java          JavaConfig          `json:"java"`
+	Cgroups       CgroupsConfig       `json:"cgroups"`
+	Database      DatabaseConfig      `json:"database"`
+	Tokens        TokenConfig         `json:"tokens"`
+	Login         LoginConfig         `json:"login"`
+	Registration  RegistrationConfig  `json:"registration"`
+	Passwords     PasswordConfig      `json:"passwords"`
+	PasswordReset PasswordResetConfig `json:"passwordReset"`
+	MFA           MFAConfig           `json:"mfa"`
+	SMTP          SMTPConfig          `json:"smtp"`
+	OIDC          OIDCConfig          `json:"oidc"`
+}
+
+type ServerConfig struct {
+	// ListenAddress is the host and port the API is served on
+	ListenAddress string `json:"listenAddress" env:"LISTEN_ADDRESS"`
+	// DataPath is the directory server jars, worlds and archives are kept in
+	DataPath string `json:"dataPath" env:"DATA_PATH"`
+}
+
+type PortsConfig struct {
+	// First and Last bound the range the game, query and RCON ports of new servers are assigned from
+	First int `json:"first" env:"SERVER_PORTS_FIRST"`
+	Last  int `json:"last" env:"SERVER_PORTS_LAST"`
+}
+
+type JavaConfig struct {
+	// Homes is a comma separated list of Java installations to use besides the detected ones
+	Homes string `json:"homes" env:"JAVA_HOMES"`
+	// DefaultMaxHeapMB is the -Xmx of new servers that do not set one. 0 leaves it to the JVM.
+	DefaultMaxHeapMB int `json:"defaultMaxHeapMb" env:"JAVA_DEFAULT_MAX_HEAP_MB"`
+}
+
+type CgroupsConfig struct {
+	// Enabled confines every server process to a cgroup v2 when the host supports it
+	Enabled bool `json:"enabled" env:"CGROUPS_ENABLED"`
+	// Root is the delegated cgroup server cgroups are created in. Empty uses the cgroup of gomine.
+	Root string `json:"root" env:"CGROUPS_ROOT"`
+}
+
+type DatabaseConfig struct {
+	// URL is a postgres:// URL or the path of a SQLite database. See store.ParseDatabaseURL.
+	URL string `json:"url" env:"DATABASE_URL"`
+}
+
+type TokenConfig struct {
+	// Secret signs tokens with HS256 unless PrivateKeyFile is set
+	Secret string `json:"secret" env:"API_SECRET" secret:"true"`
+	// KeyID is the kid of the key new tokens are signed with. It must change whenever the secret or
+	// private key does, and the old key be kept under its old kid, or tokens signed with the old key
+	// are checked against the new one and rejected.
+	KeyID string `json:"keyId" env:"JWT_KEY_ID"`
+	// PrivateKeyFile is a PEM encoded RSA or Ed25519 private key to sign with
+	PrivateKeyFile string `json:"privateKeyFile" env:"JWT_PRIVATE_KEY_FILE"`
+	// PreviousSecrets is a comma separated list of `kid:secret` HS256 keys still accepted
+	PreviousSecrets string `json:"previousSecrets" env:"JWT_PREVIOUS_SECRETS" secret:"true"`
+	// PublicKeyFiles is a comma separated list of `kid:path` PEM public keys still accepted
+	PublicKeyFiles             string `json:"publicKeyFiles" env:"JWT_PUBLIC_KEY_FILES"`
+	Issuer                     string `json:"issuer" env:"JWT_ISSUER"`
+	AccessTokenLifespanMinutes int    `json:"accessTokenLifespanMinutes" env:"JWT_AUTH_LIFESPAN_MINUTES"`
+	RefreshTokenLifespanHours  int    `json:"refreshTokenLifespanHours" env:"JWT_REFRESH_LIFESPAN_HOURS"`
+	// ClockSkewSeconds is the leeway applied to exp, nbf and iat
+	ClockSkewSeconds int `json:"clockSkewSeconds" env:"JWT_CLOCK_SKEW_SECONDS"`
+	// AcceptLegacyTokens accepts access tokens issued before tokens had a kid until they expire.
+	// They are checked against the `default` key and can be refused once they have all expired.
+	AcceptLegacyTokens bool `json:"acceptLegacyTokens" env:"JWT_ACCEPT_LEGACY_TOKENS"`
+}
+
+type LoginConfig struct {
+	// MaxFailures is how many failed logins lock a username or IP out
+	MaxFailures    int `json:"maxFailures" env:"LOGIN_MAX_FAILURES"`
+	LockoutMinutes int `json:"lockoutMinutes" env:"LOGIN_LOCKOUT_MINUTES"`
+}
+
+type RegistrationConfig struct {
+	// Mode is `open`, `invite` or `approval`
+	Mode string `json:"mode" env:"REGISTRATION_MODE"`
+	// AdminUsername is the user that becomes admin while there is no admin yet, when signing up or
+	// at startup if they already exist
+	AdminUsername string `json:"adminUsername" env:"ADMIN_USERNAME"`
+}
+
+type PasswordConfig struct {
+	MinLength int `json:"minLength" env:"PASSWORD_MIN_LENGTH"`
+	// BreachedPasswordsFile lists passwords that are refused, one per line
+	BreachedPasswordsFile string `json:"breachedPasswordsFile" env:"BREACHED_PASSWORDS_FILE"`
+}
+
+type PasswordResetConfig struct {
+	// URL is a frontend page reset tokens are added to, if there is one
+	URL             string `json:"url" env:"PASSWORD_RESET_URL"`
+	LifespanMinutes int    `json:"lifespanMinutes" env:"PASSWORD_RESET_LIFESPAN_MINUTES"`
+}
+
+type MFAConfig struct {
+	// TOTPIssuer is the issuer shown in authenticator apps
+	TOTPIssuer string `json:"totpIssuer" env:"TOTP_ISSUER"`
+}
+
+type SMTPConfig struct {
+	// Host enables email notifications. They are written to the log when it is empty.
+	Host     string `json:"host" env:"SMTP_HOST"`
+	Port     string `json:"port" env:"SMTP_PORT"`
+	From     string `json:"from" env:"SMTP_FROM"`
+	Username string `json:"username" env:"SMTP_USERNAME"`
+	Password string `json:"password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+type OIDCConfig struct {
+	// Issuer enables single sign-on with the identity provider it points to
+	Issuer       string `json:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string `json:"clientId" env:"OIDC_CLIENT_ID"`
+	ClientSecret string `json:"clientSecret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL  string `json:"redirectUrl" env:"OIDC_REDIRECT_URL"`
+	// Scopes and AdminGroups are separated by commas or spaces
+	Scopes        string `json:"scopes" env:"OIDC_SCOPES"`
+	GroupsClaim   string `json:"groupsClaim" env:"OIDC_GROUPS_CLAIM"`
+	AdminGroups   string `json:"adminGroups" env:"OIDC_ADMIN_GROUPS"`
+	AutoProvision bool   `json:"autoProvision" env:"OIDC_AUTO_PROVISION"`
+	// LinkByEmail links identities to the account that verified the same email address
+	LinkByEmail bool `json:"linkByEmail" env:"OIDC_LINK_BY_EMAIL"`
+}
+
+// Default returns the configuration gomine runs with when nothing is set
+func Default() *Config {
+	return &Config{
+		Server:        ServerConfig{ListenAddress: "localhost:8080", DataPath: "data"},
+		Ports:         PortsConfig{First: 25565, Last: 25664},
+		Java:          JavaConfig{DefaultMaxHeapMB: 2048},
+		Cgroups:       CgroupsConfig{Enabled: true},
+		Tokens:        TokenConfig{KeyID: "default", Issuer: "gomine", AccessTokenLifespanMinutes: 15, RefreshTokenLifespanHours: 720, ClockSkewSeconds: 30, AcceptLegacyTokens: true},
+		Login:         LoginConfig{MaxFailures: 10, LockoutMinutes: 15},
+		Registration:  RegistrationConfig{Mode: "open"},
+		Passwords:     PasswordConfig{MinLength: 10},
+		PasswordReset: PasswordResetConfig{LifespanMinutes: 30},
+		MFA:           MFAConfig{TOTPIssuer: "gomine"},
+		SMTP:          SMTPConfig{Port: "587", From: "gomine@localhost"},
+		OIDC:          OIDCConfig{Scopes: "openid profile email", GroupsClaim: "groups"},
+	}
+}
+
+// field is a setting of the configuration along with the environment variable it is read from
+type field struct {
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields lists every setting of a configuration. Setting the values of the returned fields
+// changes the configuration.
+func (config *Config) fields() []field {
+	fields := []field{}
+	sections := reflect.ValueOf(config).Elem()
+
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+
+		for j := 0; j < section.NumField(); j++ {
+			tag := section.Type().Field(j).Tag
+			fields = append(fields, field{env: tag.Get("env"), secret: tag.Get("secret") == "true", value: section.Field(j)})
+		}
+	}
+
+	return fields
+}
+
+// Redacted returns a copy of the configuration that is safe to show. Secrets are replaced and the
+// passwords of the database URL are masked.
+func (config *Config) Redacted() *Config {
+	redacted := *config
+
+	for _, field := range redacted.fields() {
+		if field.secret && field.value.String() != "" {
+			field.value.SetString(REDACTED)
+		}
+	}
+
+	redacted.Database.URL = redactDatabaseURL(redacted.Database.URL)
+
+	return &redacted
+}
+
+// redactDatabaseURL masks the password of a database URL the way url.URL.Redacted does. lib/pq
+// also takes passwords as query parameters.
+func redactDatabaseURL(rawUrl string) string {
+	databaseUrl, err := url.Parse(rawUrl)
+
+	if err != nil {
+		return rawUrl
+	}
+
+	query := databaseUrl.Query()
+	masked := false
+
+	for _, parameter := range []string{"password", "sslpassword"} {
+		if query.Has(parameter) {
+			query.Set(parameter, "xxxxx")
+			masked = true
+		}
+	}
+
+	if masked {
+		databaseUrl.RawQuery = query.Encode()
+	}
+
+	if !masked && databaseUrl.User == nil {
+		return rawUrl
+	}
+
+	return databaseUrl.Redacted()
+}
+
+// Middleware makes the configuration available to every handler through FromContext
+func Middleware(config *Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(CONFIG_CONTEXT_KEY, config)
+		c.Next()
+	}
+}
+
+// FromContext returns the configuration set by Middleware
+func FromContext(c *gin.Context) *Config {
+	return c.MustGet(CONFIG_CONTEXT_KEY).(*Config)
+}
+
+// GetConfig shows the configuration gomine is running with, without its secrets
+func GetConfig(context *gin.Context) {
+	httputils.RespondWithStatusOk(context, FromContext(context).Redacted())
+}
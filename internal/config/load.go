@@ -0,0 +1,221 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// DEFAULT_CONFIG_FILE is read when it exists and no other config file is given
+const DEFAULT_CONFIG_FILE = "gomine.json"
+
+// ENV_FILE holds environment variables for development. Variables set in the environment win.
+const ENV_FILE = ".env"
+
+// removedSettings maps variables gomine no longer reads to what replaced them. Setting one fails
+// to start rather than running with a setting that is silently ignored.
+var removedSettings = map[string]string{
+	"JWT_AUTH_LIFESPAN_HOURS": "JWT_AUTH_LIFESPAN_MINUTES, as access tokens are now short lived and refreshed. Sessions last JWT_REFRESH_LIFESPAN_HOURS",
+}
+
+// flagName is the flag a setting can be given with, like -listen-address for LISTEN_ADDRESS
+func flagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// set parses a raw value into a setting. name is where the value came from and is used in errors.
+func (field *field) set(name string, rawValue string) error {
+	switch field.value.Kind() {
+	case reflect.Int:
+		value, err := strconv.Atoi(rawValue)
+
+		if err != nil {
+			return fmt.Errorf("%v must be an integer, got `%v`", name, rawValue)
+		}
+
+		field.value.SetInt(int64(value))
+	case reflect.Bool:
+		value, err := strconv.ParseBool(rawValue)
+
+		if err != nil {
+			return fmt.Errorf("%v must be `true` or `false`, got `%v`", name, rawValue)
+		}
+
+		field.value.SetBool(value)
+	default:
+		field.value.SetString(rawValue)
+	}
+
+	return nil
+}
+
+// loadFile overrides the configuration with a JSON config file. Settings it does not know about
+// are refused, so typos are not silently ignored.
+func (config *Config) loadFile(path string) error {
+	contents, err := os.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("Could not read config file `%v`: %w", path, err)
+	}
+
+	return nil
+}
+
+// Load builds the configuration from, in increasing order of precedence, the defaults, the config
+// file, the .env file, the environment and the command line flags in args. The config file is
+// given with -config or GOMINE_CONFIG, and DEFAULT_CONFIG_FILE is used when it exists otherwise.
+// The configuration is validated before it is returned.
+func Load(args []string) (*Config, error) {
+	config := Default()
+	flags := flag.NewFlagSet("gomine", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("GOMINE_CONFIG"), "JSON config file")
+	rawFlags := map[string]string{}
+
+	for _, field := range config.fields() {
+		name := flagName(field.env)
+		flags.Func(name, fmt.Sprintf("overrides %v", field.env), func(value string) error {
+			rawFlags[name] = value
+			return nil
+		})
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := config.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	} else if err := config.loadFile(DEFAULT_CONFIG_FILE); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	envFile, err := godotenv.Read(ENV_FILE)
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("Could not read `%v`: %w", ENV_FILE, err)
+	}
+
+	for name, replacement := range removedSettings {
+		if _, ok := os.LookupEnv(name); ok {
+			return nil, fmt.Errorf("%v is no longer supported, use %v", name, replacement)
+		}
+
+		if _, ok := envFile[name]; ok {
+			return nil, fmt.Errorf("%v in `%v` is no longer supported, use %v", name, ENV_FILE, replacement)
+		}
+	}
+
+	for _, field := range config.fields() {
+		rawValue, ok := os.LookupEnv(field.env)
+
+		if !ok {
+			rawValue, ok = envFile[field.env]
+		}
+
+		if !ok || rawValue == "" {
+			continue
+		}
+
+		if err := field.set(field.env, rawValue); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range config.fields() {
+		name := flagName(field.env)
+
+		if rawValue, ok := rawFlags[name]; ok {
+			if err := field.set("-"+name, rawValue); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate checks that settings are usable together. Every problem is reported at once.
+func (config *Config) Validate() error {
+	problems := []string{}
+	positive := []struct {
+		name  string
+		value int
+	}{
+		{"JWT_AUTH_LIFESPAN_MINUTES", config.Tokens.AccessTokenLifespanMinutes},
+		{"JWT_REFRESH_LIFESPAN_HOURS", config.Tokens.RefreshTokenLifespanHours},
+		{"JWT_CLOCK_SKEW_SECONDS", config.Tokens.ClockSkewSeconds},
+		{"LOGIN_MAX_FAILURES", config.Login.MaxFailures},
+		{"LOGIN_LOCKOUT_MINUTES", config.Login.LockoutMinutes},
+		{"PASSWORD_MIN_LENGTH", config.Passwords.MinLength},
+		{"PASSWORD_RESET_LIFESPAN_MINUTES", config.PasswordReset.LifespanMinutes},
+	}
+
+	for _, setting := range positive {
+		if setting.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%v must be a positive integer, got `%v`", setting.name, setting.value))
+		}
+	}
+
+	if config.Server.ListenAddress == "" {
+		problems = append(problems, "LISTEN_ADDRESS is required")
+	}
+
+	if config.Server.DataPath == "" {
+		problems = append(problems, "DATA_PATH is required")
+	}
+
+	// Every server is assigned a game, a query and an RCON port
+	if config.Ports.First < 1 || config.Ports.Last > 65535 || config.Ports.Last-config.Ports.First < 2 {
+		problems = append(problems, fmt.Sprintf("SERVER_PORTS_FIRST and SERVER_PORTS_LAST must be a range of at least 3 ports between 1 and 65535, got `%v-%v`", config.Ports.First, config.Ports.Last))
+	}
+
+	if config.Java.DefaultMaxHeapMB < 0 {
+		problems = append(problems, fmt.Sprintf("JAVA_DEFAULT_MAX_HEAP_MB must not be negative, got `%v`", config.Java.DefaultMaxHeapMB))
+	}
+
+	if config.Tokens.Secret == "" && config.Tokens.PrivateKeyFile == "" {
+		problems = append(problems, "API_SECRET or JWT_PRIVATE_KEY_FILE is required to sign tokens")
+	}
+
+	switch config.Registration.Mode {
+	case "open", "invite", "approval":
+	default:
+		problems = append(problems, fmt.Sprintf("REGISTRATION_MODE must be `open`, `invite` or `approval`, got `%v`", config.Registration.Mode))
+	}
+
+	if config.SMTP.Host != "" && config.SMTP.Port == "" {
+		problems = append(problems, "SMTP_PORT is required when SMTP_HOST is set")
+	}
+
+	if config.OIDC.Issuer != "" && (config.OIDC.ClientID == "" || config.OIDC.RedirectURL == "") {
+		problems = append(problems, "OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER is set")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Invalid configuration: %v", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package http
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DEPRECATION_HEADER tells clients that a route is deprecated and since when, as described by
+// RFC 9745
+const DEPRECATION_HEADER = "Deprecation"
+
+// Deprecated marks every response of the routes it is used on as deprecated since deprecatedAt and
+// links to the route replacing them with a Link header
+func Deprecated(deprecatedAt time.Time, successor string) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", deprecatedAt.Unix())
+	link := fmt.Sprintf("<%v>; rel=\"successor-version\"", successor)
+
+	return func(context *gin.Context) {
+		context.Header(DEPRECATION_HEADER, deprecation)
+		context.Header("Link", link)
+		context.Next()
+	}
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrorCode identifies the kind of error a response describes. Clients should branch on it
+// rather than on the message, which is meant for people.
+type ErrorCode string
+
+const (
+	CodeBadRequest      ErrorCode = "bad_request"
+	CodeUnauthorized    ErrorCode = "unauthorized"
+	CodeForbidden       ErrorCode = "forbidden"
+	CodeNotFound        ErrorCode = "not_found"
+	CodeConflict        ErrorCode = "conflict"
+	CodeValidation      ErrorCode = "validation_failed"
+	CodeTooManyRequests ErrorCode = "too_many_requests"
+	CodeInternal        ErrorCode = "internal_error"
+	CodeBadGateway      ErrorCode = "bad_gateway"
+)
+
+// INTERNAL_ERROR_MESSAGE is shown in place of errors that are not meant for clients
+const INTERNAL_ERROR_MESSAGE = "Something went wrong. Try again later."
+
+// Error is an error that can be shown to clients. Cause is logged but never shown.
+type Error struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+	Details any
+	Cause   error
+}
+
+func (err *Error) Error() string {
+	if err.Cause != nil {
+		return fmt.Sprintf("%v: %v", err.Message, err.Cause)
+	}
+
+	return err.Message
+}
+
+func (err *Error) Unwrap() error {
+	return err.Cause
+}
+
+// WithDetails returns a copy of the error with details about what went wrong, like the fields
+// that failed validation
+func (err *Error) WithDetails(details any) *Error {
+	copy := *err
+	copy.Details = details
+	return &copy
+}
+
+func NewError(status int, code ErrorCode, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return NewError(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return NewError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return NewError(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return NewError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return NewError(http.StatusConflict, CodeConflict, message)
+}
+
+// Invalid reports a request that is well formed but breaks a rule, like a password that is too
+// short
+func Invalid(message string) *Error {
+	return NewError(http.StatusUnprocessableEntity, CodeValidation, message)
+}
+
+func TooManyRequests(message string) *Error {
+	return NewError(http.StatusTooManyRequests, CodeTooManyRequests, message)
+}
+
+func BadGateway(message string) *Error {
+	return NewError(http.StatusBadGateway, CodeBadGateway, message)
+}
+
+// Internal hides an unexpected error from clients. The error is logged when it is rendered.
+func Internal(cause error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: INTERNAL_ERROR_MESSAGE, Cause: cause}
+}
+
+// FieldError describes a field of a request body that failed validation
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+// InvalidBody describes why a request body could not be bound. Fields failing their binding
+// rules are reported as a validation failure listing each field, anything else as a bad request.
+func InvalidBody(err error) *Error {
+	var fieldErrors validator.ValidationErrors
+
+	if errors.As(err, &fieldErrors) {
+		details := []FieldError{}
+
+		for _, fieldError := range fieldErrors {
+			details = append(details, FieldError{Field: fieldError.Field(), Rule: fieldError.Tag()})
+		}
+
+		return Invalid("Request body failed validation").WithDetails(details)
+	}
+
+	if errors.Is(err, io.EOF) {
+		return BadRequest("Request body is required")
+	}
+
+	return BadRequest("Request body is invalid: " + err.Error())
+}
+
+// AsError converts any error to the Error shown to clients. Missing rows are reported as not
+// found and errors that are not an Error are hidden behind an internal error.
+func AsError(err error) *Error {
+	var apiError *Error
+
+	if errors.As(err, &apiError) {
+		return apiError
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFound("Not found")
+	}
+
+	return Internal(err)
+}
+
+// ErrorBody is what clients are shown about an error
+type ErrorBody struct {
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Details   any       `json:"details,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// ErrorResponse is the envelope every error response is sent in
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// RespondWithError renders an error in the error envelope and aborts the request. Every error
+// response goes through it.
+func RespondWithError(context *gin.Context, err error) {
+	apiError := AsError(err)
+	requestId := GetRequestID(context)
+
+	if apiError.Status >= http.StatusInternalServerError {
+		log.Printf("%v %v %v: %v", requestId, context.Request.Method, context.Request.URL.Path, err)
+	}
+
+	context.AbortWithStatusJSON(apiError.Status, ErrorResponse{
+		Error: ErrorBody{
+			Code:      apiError.Code,
+			Message:   apiError.Message,
+			Details:   apiError.Details,
+			RequestID: requestId,
+		},
+	})
+}
+
+func RespondWithInternalServerError(context *gin.Context, err error) {
+	RespondWithError(context, Internal(err))
+}
+
+func RespondWithNotFound(context *gin.Context, err error) {
+	RespondWithError(context, NotFound(err.Error()))
+}
+
+func RespondWithForbidden(context *gin.Context, err error) {
+	RespondWithError(context, Forbidden(err.Error()))
+}
+
+// RespondWithInvalidBody reports a request body that could not be bound. See InvalidBody.
+func RespondWithInvalidBody(context *gin.Context, err error) {
+	RespondWithError(context, InvalidBody(err))
+}
+
+// RespondWithNoRoute reports requests to routes that do not exist
+func RespondWithNoRoute(context *gin.Context) {
+	RespondWithError(context, NotFound(fmt.Sprintf("No route for %v %v", context.Request.Method, context.Request.URL.Path)))
+}
+
+// Recover reports panics as internal errors instead of closing the connection
+func Recover(context *gin.Context, recovered any) {
+	RespondWithInternalServerError(context, fmt.Errorf("panic: %v", recovered))
+}
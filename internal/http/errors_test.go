@@ -0,0 +1,78 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gotest.tools/assert"
+)
+
+// respond runs handler behind RequestID and returns the status and decoded error envelope
+func respond(t *testing.T, body string, handler gin.HandlerFunc) (int, ErrorResponse) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", RequestID, handler)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	request.Header.Set(REQUEST_ID_HEADER, "test-request")
+	router.ServeHTTP(recorder, request)
+
+	var response ErrorResponse
+	assert.NilError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, recorder.Header().Get(REQUEST_ID_HEADER), "test-request")
+
+	return recorder.Code, response
+}
+
+// Test RespondWithError and assert that errors are mapped to statuses and internals are hidden
+func TestRespondWithError(t *testing.T) {
+	status, response := respond(t, "", func(context *gin.Context) {
+		RespondWithError(context, fmt.Errorf("Could not find server: %w", sql.ErrNoRows))
+	})
+	assert.Equal(t, status, http.StatusNotFound)
+	assert.Equal(t, response.Error.Code, CodeNotFound)
+	assert.Equal(t, response.Error.RequestID, "test-request")
+
+	status, response = respond(t, "", func(context *gin.Context) {
+		RespondWithError(context, errors.New("sqlite3: database is locked"))
+	})
+	assert.Equal(t, status, http.StatusInternalServerError)
+	assert.Equal(t, response.Error.Code, CodeInternal)
+	assert.Equal(t, response.Error.Message, INTERNAL_ERROR_MESSAGE)
+
+	status, response = respond(t, "", func(context *gin.Context) {
+		RespondWithError(context, fmt.Errorf("PostUser: %w", Conflict("Username is taken")))
+	})
+	assert.Equal(t, status, http.StatusConflict)
+	assert.Equal(t, response.Error.Message, "Username is taken")
+}
+
+// Test RespondWithInvalidBody and assert that fields failing validation are listed
+func TestRespondWithInvalidBody(t *testing.T) {
+	bind := func(context *gin.Context) {
+		var options struct {
+			Username string `json:"username" binding:"required"`
+		}
+
+		if err := context.ShouldBindJSON(&options); err != nil {
+			RespondWithInvalidBody(context, err)
+		}
+	}
+
+	status, response := respond(t, `{}`, bind)
+	assert.Equal(t, status, http.StatusUnprocessableEntity)
+	assert.Equal(t, response.Error.Code, CodeValidation)
+	assert.DeepEqual(t, response.Error.Details, []any{map[string]any{"field": "Username", "rule": "required"}})
+
+	status, response = respond(t, `{`, bind)
+	assert.Equal(t, status, http.StatusBadRequest)
+	assert.Equal(t, response.Error.Code, CodeBadRequest)
+}
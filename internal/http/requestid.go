@@ -0,0 +1,37 @@
+package http
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// REQUEST_ID_HEADER carries the id of a request. Clients may set it so their logs can be matched
+// with ours, and it is always set on the response.
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// REQUEST_ID_CONTEXT_KEY is the gin context key holding the id of the request
+const REQUEST_ID_CONTEXT_KEY = "requestId"
+
+// requestIdPattern is what request ids given by clients must look like to be kept
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an id, taken from REQUEST_ID_HEADER when the client sent a valid
+// one. It is included in error responses and in the logs of internal errors.
+func RequestID(context *gin.Context) {
+	requestId := context.GetHeader(REQUEST_ID_HEADER)
+
+	if !requestIdPattern.MatchString(requestId) {
+		requestId = uuid.NewString()
+	}
+
+	context.Set(REQUEST_ID_CONTEXT_KEY, requestId)
+	context.Header(REQUEST_ID_HEADER, requestId)
+	context.Next()
+}
+
+// GetRequestID returns the id set by RequestID, or an empty string outside of it
+func GetRequestID(context *gin.Context) string {
+	return context.GetString(REQUEST_ID_CONTEXT_KEY)
+}
@@ -0,0 +1,288 @@
+package java
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/config"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/gin-gonic/gin"
+)
+
+// REGISTRY_CONTEXT_KEY is the gin context key holding the registry requests are served with
+const REGISTRY_CONTEXT_KEY = "java"
+
+// PROBE_TIMEOUT is how long `java -version` may take before an installation is ignored
+const PROBE_TIMEOUT = 10 * time.Second
+
+// SEARCH_PATTERNS are the globs of the directories Java installations are usually found in
+var SEARCH_PATTERNS = []string{
+	"/usr/lib/jvm/*",
+	"/usr/java/*",
+	"/opt/java/*",
+	"/opt/jdk*",
+	"/Library/Java/JavaVirtualMachines/*/Contents/Home",
+}
+
+var (
+	ErrUnknownInstallation = errors.New("Unknown Java installation")
+	ErrJavaTooOld          = errors.New("Java installation is too old")
+	ErrNoSuitableJava      = errors.New("No Java installation meets the required version")
+)
+
+// versionPattern matches the version `java -version` prints, like `openjdk version "17.0.2"`
+var versionPattern = regexp.MustCompile(`version "([^"]+)"`)
+
+// Installation is a Java runtime found on the host
+type Installation struct {
+	Home string `json:"home"`
+	// Version is the full version, like 17.0.2 or 1.8.0_292
+	Version string `json:"version"`
+	// Major is the major version, like 17 or 8
+	Major int `json:"major"`
+	// executable is where the java command links to, to find installations linked from other homes
+	executable string
+}
+
+// Executable returns the location of the java command of an installation
+func (installation *Installation) Executable() string {
+	return filepath.Join(installation.Home, "bin", "java")
+}
+
+// Registry is the Java installations servers can be started with
+type Registry struct {
+	// Installations are ordered by major version, then by home
+	Installations []Installation
+}
+
+// ParseVersion reads the full and major version from the output of `java -version`. Versions
+// before Java 9 are numbered 1.x, where x is the major version.
+func ParseVersion(output string) (string, int, error) {
+	match := versionPattern.FindStringSubmatch(output)
+
+	if match == nil {
+		return "", 0, fmt.Errorf("No version in `%v`", strings.TrimSpace(output))
+	}
+
+	version := match[1]
+	parts := strings.FieldsFunc(strings.TrimPrefix(version, "1."), func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+
+	if len(parts) == 0 {
+		return "", 0, fmt.Errorf("Invalid Java version `%v`", version)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid Java version `%v`", version)
+	}
+
+	return version, major, nil
+}
+
+// probe runs `java -version` of the installation at home
+func probe(home string) (*Installation, error) {
+	installation := &Installation{Home: home}
+	timeout, cancel := context.WithTimeout(context.Background(), PROBE_TIMEOUT)
+	defer cancel()
+
+	// java prints its version to stderr
+	output, err := exec.CommandContext(timeout, installation.Executable(), "-version").CombinedOutput()
+
+	if err != nil {
+		return nil, err
+	}
+
+	installation.Version, installation.Major, err = ParseVersion(string(output))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return installation, nil
+}
+
+// candidates lists the homes that may hold a Java installation: the configured ones, JAVA_HOME,
+// the one of the java command on PATH and the ones in SEARCH_PATTERNS
+func candidates(javaConfig *config.JavaConfig) []string {
+	homes := []string{}
+
+	for _, home := range strings.Split(javaConfig.Homes, ",") {
+		if home = strings.TrimSpace(home); home != "" {
+			homes = append(homes, home)
+		}
+	}
+
+	if home := os.Getenv("JAVA_HOME"); home != "" {
+		homes = append(homes, home)
+	}
+
+	if executable, err := exec.LookPath("java"); err == nil {
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+
+		homes = append(homes, filepath.Dir(filepath.Dir(executable)))
+	}
+
+	for _, pattern := range SEARCH_PATTERNS {
+		matches, _ := filepath.Glob(pattern)
+		homes = append(homes, matches...)
+	}
+
+	return homes
+}
+
+// Detect finds the Java installations of the host. Configured homes that are not Java
+// installations are logged, and every other candidate is silently skipped.
+func Detect(javaConfig *config.JavaConfig) *Registry {
+	registry := &Registry{Installations: []Installation{}}
+	configured := map[string]bool{}
+	seen := map[string]bool{}
+
+	for _, home := range strings.Split(javaConfig.Homes, ",") {
+		configured[filepath.Clean(strings.TrimSpace(home))] = true
+	}
+
+	for _, home := range candidates(javaConfig) {
+		home = filepath.Clean(home)
+
+		// Installations are often linked from several places, like /usr/lib/jvm/default-java
+		executable, err := filepath.EvalSymlinks((&Installation{Home: home}).Executable())
+
+		if err != nil {
+			if configured[home] {
+				log.Printf("Ignoring Java installation `%v`: %v", home, err)
+			}
+
+			continue
+		}
+
+		if seen[executable] {
+			continue
+		}
+
+		installation, err := probe(home)
+
+		if err != nil {
+			if configured[home] {
+				log.Printf("Ignoring Java installation `%v`: %v", home, err)
+			}
+
+			continue
+		}
+
+		seen[executable] = true
+		installation.executable = executable
+		registry.Installations = append(registry.Installations, *installation)
+	}
+
+	sort.SliceStable(registry.Installations, func(i, j int) bool {
+		a, b := registry.Installations[i], registry.Installations[j]
+
+		if a.Major != b.Major {
+			return a.Major < b.Major
+		}
+
+		return a.Home < b.Home
+	})
+
+	return registry
+}
+
+// Find returns the installation at a home, or the one a home links to
+func (registry *Registry) Find(home string) (*Installation, bool) {
+	home = filepath.Clean(home)
+
+	for i := range registry.Installations {
+		if registry.Installations[i].Home == home {
+			return &registry.Installations[i], true
+		}
+	}
+
+	executable, err := filepath.EvalSymlinks((&Installation{Home: home}).Executable())
+
+	if err != nil {
+		return nil, false
+	}
+
+	for i := range registry.Installations {
+		if registry.Installations[i].executable == executable {
+			return &registry.Installations[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// Select returns the installation closest to a required major version, which is the oldest one
+// that is at least as new. The newest installation is selected when the requirement is unknown (0).
+func (registry *Registry) Select(required int) (*Installation, error) {
+	installations := registry.Installations
+
+	if len(installations) == 0 {
+		return nil, fmt.Errorf("%w: no Java installation was found", ErrNoSuitableJava)
+	}
+
+	if required == 0 {
+		return &installations[len(installations)-1], nil
+	}
+
+	for i := range installations {
+		if installations[i].Major >= required {
+			return &installations[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: Java %v or newer is required", ErrNoSuitableJava, required)
+}
+
+// Resolve returns the installation a server is started with: the one at home when it is set, or
+// the one selected for the required major version
+func (registry *Registry) Resolve(home string, required int) (*Installation, error) {
+	if home == "" {
+		return registry.Select(required)
+	}
+
+	installation, ok := registry.Find(home)
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownInstallation, home)
+	}
+
+	if installation.Major < required {
+		return nil, fmt.Errorf("%w: %v is Java %v, Java %v or newer is required", ErrJavaTooOld, home, installation.Major, required)
+	}
+
+	return installation, nil
+}
+
+// GetInstallations lists the Java installations servers can be started with
+func GetInstallations(context *gin.Context) {
+	httputils.RespondWithStatusOk(context, FromContext(context).Installations)
+}
+
+// Middleware makes a registry available to every handler through FromContext
+func Middleware(registry *Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(REGISTRY_CONTEXT_KEY, registry)
+		c.Next()
+	}
+}
+
+// FromContext returns the registry set by Middleware
+func FromContext(c *gin.Context) *Registry {
+	return c.MustGet(REGISTRY_CONTEXT_KEY).(*Registry)
+}
@@ -0,0 +1,62 @@
+package java
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Test ParseVersion and assert that both version schemes give the major version
+func TestParseVersion(t *testing.T) {
+	outputs := map[string]int{
+		"openjdk version \"17.0.2\" 2022-01-18\nOpenJDK Runtime Environment (build 17.0.2+8-86)": 17,
+		"java version \"1.8.0_292\"\nJava(TM) SE Runtime Environment (build 1.8.0_292-b10)":      8,
+		"openjdk version \"21\" 2023-09-19":                                                      21,
+		"openjdk version \"22-ea\" 2024-03-19":                                                   22,
+	}
+
+	for output, major := range outputs {
+		_, parsed, err := ParseVersion(output)
+		assert.NilError(t, err)
+		assert.Equal(t, parsed, major)
+	}
+
+	_, _, err := ParseVersion("bash: java: command not found")
+	assert.ErrorContains(t, err, "No version")
+}
+
+// Test Resolve and assert that the closest installation is selected and that chosen installations
+// must meet the required version
+func TestResolve(t *testing.T) {
+	registry := &Registry{Installations: []Installation{
+		{Home: "/usr/lib/jvm/java-8", Version: "1.8.0_292", Major: 8},
+		{Home: "/usr/lib/jvm/java-17", Version: "17.0.2", Major: 17},
+		{Home: "/usr/lib/jvm/java-21", Version: "21.0.1", Major: 21},
+	}}
+
+	installation, err := registry.Resolve("", 8)
+	assert.NilError(t, err)
+	assert.Equal(t, installation.Major, 8)
+
+	installation, err = registry.Resolve("", 16)
+	assert.NilError(t, err)
+	assert.Equal(t, installation.Major, 17)
+
+	installation, err = registry.Resolve("", 0)
+	assert.NilError(t, err)
+	assert.Equal(t, installation.Major, 21)
+
+	_, err = registry.Resolve("", 25)
+	assert.Assert(t, errors.Is(err, ErrNoSuitableJava))
+
+	installation, err = registry.Resolve("/usr/lib/jvm/java-21/", 17)
+	assert.NilError(t, err)
+	assert.Equal(t, installation.Major, 21)
+
+	_, err = registry.Resolve("/usr/lib/jvm/java-8", 17)
+	assert.Assert(t, errors.Is(err, ErrJavaTooOld))
+
+	_, err = registry.Resolve("/opt/java-11", 8)
+	assert.Assert(t, errors.Is(err, ErrUnknownInstallation))
+}
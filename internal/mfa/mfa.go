@@ -0,0 +1,246 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RECOVERY_CODE_COUNT is how many recovery codes are issued when 2FA is activated
+const RECOVERY_CODE_COUNT = 10
+
+var (
+	ErrAlreadyEnabled = errors.New("Two-factor authentication is already enabled")
+	ErrNotEnrolling   = errors.New("Two-factor authentication has to be enrolled before it can be verified")
+	ErrNotEnabled     = errors.New("Two-factor authentication is not enabled")
+	ErrInvalidCode    = errors.New("Invalid two-factor authentication code")
+)
+
+// Enrollment is what a user needs to add their account to an authenticator app
+type Enrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+// hashRecoveryCode hashes a recovery code for storage. Recovery codes are random, so a fast hash
+// is enough.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCode picks every character uniformly, which taking random bytes modulo the
+// length of the alphabet would not
+func generateRecoveryCode() (string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	size := big.NewInt(int64(len(alphabet)))
+	code := make([]byte, 0, 11)
+
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			code = append(code, '-')
+		}
+
+		index, err := rand.Int(rand.Reader, size)
+
+		if err != nil {
+			return "", err
+		}
+
+		code = append(code, alphabet[index.Int64()])
+	}
+
+	return string(code), nil
+}
+
+// IsEnabled reports whether a user has activated 2FA
+func IsEnabled(db *sql.DB, userId string) (bool, error) {
+	var enabled bool
+	err := db.QueryRow("select exists(select 1 from user_mfa where user_id=? and enabled)", userId).Scan(&enabled)
+
+	return enabled, err
+}
+
+// BeginEnrollment generates a new secret for a user. 2FA stays inactive until a code generated
+// from the secret is verified with ActivateEnrollment. issuer is shown in authenticator apps.
+func BeginEnrollment(db *sql.DB, issuer string, userId string, accountName string) (*Enrollment, error) {
+	enabled, err := IsEnabled(db, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	secret, err := GenerateSecret()
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		`insert into user_mfa(user_id, secret, enabled, last_used_step, created_at) values(?, ?, false, 0, ?)
+			on conflict(user_id) do update set secret=excluded.secret, created_at=excluded.created_at where not enabled`,
+		userId, secret, time.Now().Unix(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{Secret: secret, ProvisioningURI: ProvisioningURI(issuer, accountName, secret)}, nil
+}
+
+// ActivateEnrollment enables 2FA once the user proves their authenticator app works. The recovery
+// codes are returned once and only their hashes are kept.
+func ActivateEnrollment(db *sql.DB, userId string, code string) ([]string, error) {
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer transaction.Rollback()
+
+	var secret string
+	err = transaction.QueryRow("select secret from user_mfa where user_id=? and not enabled", userId).Scan(&secret)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotEnrolling
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := ValidateCode(secret, code, time.Now())
+
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	if _, err := transaction.Exec("update user_mfa set enabled=true, last_used_step=? where user_id=?", step, userId); err != nil {
+		return nil, err
+	}
+
+	if _, err := transaction.Exec("delete from mfa_recovery_codes where user_id=?", userId); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, RECOVERY_CODE_COUNT)
+
+	for i := 0; i < RECOVERY_CODE_COUNT; i++ {
+		recoveryCode, err := generateRecoveryCode()
+
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := uuid.NewRandom()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := transaction.Exec(
+			"insert into mfa_recovery_codes(id, user_id, hash) values(?, ?, ?)", id.String(), userId, hashRecoveryCode(recoveryCode),
+		); err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, recoveryCode)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Verify checks a TOTP code of a user with 2FA enabled. A code cannot be used twice.
+func Verify(db *sql.DB, userId string, code string) error {
+	var secret string
+	err := db.QueryRow("select secret from user_mfa where user_id=? and enabled", userId).Scan(&secret)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotEnabled
+	}
+
+	if err != nil {
+		return err
+	}
+
+	step, ok := ValidateCode(secret, code, time.Now())
+
+	if !ok {
+		return ErrInvalidCode
+	}
+
+	// Only steps after the last accepted one are allowed, which rejects replayed codes
+	result, err := db.Exec("update user_mfa set last_used_step=? where user_id=? and last_used_step<?", step, userId, step)
+
+	if err != nil {
+		return err
+	}
+
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return ErrInvalidCode
+	}
+
+	return nil
+}
+
+// VerifyRecoveryCode uses up one of a user's recovery codes
+func VerifyRecoveryCode(db *sql.DB, userId string, code string) error {
+	result, err := db.Exec(
+		"update mfa_recovery_codes set used_at=? where user_id=? and hash=? and used_at is null",
+		time.Now().Unix(), userId, hashRecoveryCode(code),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return ErrInvalidCode
+	}
+
+	return nil
+}
+
+// Disable turns 2FA off for a user and forgets their secret and recovery codes
+func Disable(db *sql.DB, userId string) error {
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	if _, err := transaction.Exec("delete from mfa_recovery_codes where user_id=?", userId); err != nil {
+		return err
+	}
+
+	if _, err := transaction.Exec("delete from user_mfa where user_id=?", userId); err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
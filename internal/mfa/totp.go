@@ -0,0 +1,112 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTP_PERIOD is how long a code is valid
+	TOTP_PERIOD = 30 * time.Second
+	// TOTP_DIGITS is the length of a code
+	TOTP_DIGITS = 6
+	// TOTP_SKEW_STEPS is how many periods before and after the current one are accepted to
+	// tolerate clock drift of authenticator apps
+	TOTP_SKEW_STEPS = 1
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random base32 encoded TOTP secret
+func GenerateSecret() (string, error) {
+	bytes := make([]byte, 20)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return secretEncoding.EncodeToString(bytes), nil
+}
+
+// ProvisioningURI returns the otpauth URI authenticator apps enroll a secret from
+func ProvisioningURI(issuer string, accountName string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTP_DIGITS))
+	query.Set("period", fmt.Sprint(int(TOTP_PERIOD.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func stepAt(t time.Time) int64 {
+	return t.Unix() / int64(TOTP_PERIOD.Seconds())
+}
+
+// codeAt computes the code of a time step as described in RFC 4226 and RFC 6238
+func codeAt(key []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+
+	for i := 0; i < TOTP_DIGITS; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", TOTP_DIGITS, value%modulo)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	return secretEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+// GenerateCode returns the code of a secret at a point in time
+func GenerateCode(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+
+	if err != nil {
+		return "", err
+	}
+
+	return codeAt(key, stepAt(t)), nil
+}
+
+// ValidateCode checks a code against a secret and returns the time step it matched. Callers
+// remember the step to reject codes that are replayed.
+func ValidateCode(secret string, code string, t time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.ReplaceAll(code, " ", "")
+	current := stepAt(t)
+
+	for step := current - TOTP_SKEW_STEPS; step <= current+TOTP_SKEW_STEPS; step++ {
+		if subtle.ConstantTimeCompare([]byte(codeAt(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,34 @@
+package mfa
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// Test GenerateCode and ValidateCode against the SHA1 test vectors of RFC 6238
+func TestTOTP(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range vectors {
+		code, err := GenerateCode(secret, time.Unix(unix, 0))
+		assert.NilError(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	step, ok := ValidateCode(secret, "081804", time.Unix(1111111109+30, 0))
+	assert.Assert(t, ok)
+	assert.Equal(t, int64(1111111109/30), step)
+
+	_, ok = ValidateCode(secret, "081804", time.Unix(1111111109+90, 0))
+	assert.Assert(t, !ok)
+}
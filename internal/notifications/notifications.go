@@ -0,0 +1,111 @@
+package notifications
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+
+	"github.com/ecuyle/gomine/internal/config"
+)
+
+// Message is a notification for a single user. To is empty when the user has no email address.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages to users
+type Sender interface {
+	Send(message *Message) error
+}
+
+// SMTPSender delivers messages by email
+type SMTPSender struct {
+	Host     string
+	Port     string
+	From     string
+	Username string
+	Password string
+}
+
+// LogSender writes messages to the server log for admins to pass on, for installs without mail
+type LogSender struct {
+	Logger *log.Logger
+}
+
+// fallbackSender sends with SMTP when a message has a recipient and logs it otherwise
+type fallbackSender struct {
+	primary  Sender
+	fallback Sender
+}
+
+func (sender *SMTPSender) Send(message *Message) error {
+	if message.To == "" {
+		return fmt.Errorf("Cannot email `%v` without a recipient", message.Subject)
+	}
+
+	var auth smtp.Auth
+
+	if sender.Username != "" {
+		auth = smtp.PlainAuth("", sender.Username, sender.Password, sender.Host)
+	}
+
+	body := strings.Join([]string{
+		"From: " + sender.From,
+		"To: " + message.To,
+		"Subject: " + message.Subject,
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		message.Body,
+	}, "\r\n")
+
+	return smtp.SendMail(sender.Host+":"+sender.Port, auth, sender.From, []string{message.To}, []byte(body))
+}
+
+func (sender *LogSender) Send(message *Message) error {
+	logger := sender.Logger
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	recipient := message.To
+
+	if recipient == "" {
+		recipient = "(no email address)"
+	}
+
+	logger.Printf("Notification for %v: %v\n%v", recipient, message.Subject, message.Body)
+
+	return nil
+}
+
+func (sender *fallbackSender) Send(message *Message) error {
+	if message.To == "" {
+		return sender.fallback.Send(message)
+	}
+
+	return sender.primary.Send(message)
+}
+
+// NewSender returns an SMTP sender when an SMTP host is configured and a log sender otherwise.
+// Users without an email address are always notified through the log.
+func NewSender(config *config.SMTPConfig) Sender {
+	logSender := &LogSender{}
+
+	if config.Host == "" {
+		return logSender
+	}
+
+	smtpSender := &SMTPSender{
+		Host:     config.Host,
+		Port:     config.Port,
+		From:     config.From,
+		Username: config.Username,
+		Password: config.Password,
+	}
+
+	return &fallbackSender{primary: smtpSender, fallback: logSender}
+}
@@ -0,0 +1,93 @@
+package notifications
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// serveSMTP accepts a single connection on listener, speaks enough SMTP to receive one message and
+// sends what was received to received
+func serveSMTP(t *testing.T, listener net.Listener, received chan<- string) {
+	conn, err := listener.Accept()
+
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	var transcript strings.Builder
+
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := reader.ReadString('\n')
+
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		command := strings.ToUpper(strings.TrimSpace(line))
+		transcript.WriteString(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case command == "DATA":
+			reply("354 go ahead")
+
+			for {
+				dataLine, err := reader.ReadString('\n')
+
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if dataLine == ".\r\n" {
+					break
+				}
+
+				transcript.WriteString(dataLine)
+			}
+
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			received <- transcript.String()
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// Test SMTPSender against a local SMTP stand-in and assert that the message is delivered
+func TestSMTPSender(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go serveSMTP(t, listener, received)
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	assert.NilError(t, err)
+
+	sender := SMTPSender{Host: host, Port: port, From: "gomine@localhost"}
+	err = sender.Send(&Message{To: "steve@example.com", Subject: "Reset your gomine password", Body: "token: abc"})
+	assert.NilError(t, err)
+
+	transcript := <-received
+	assert.Assert(t, strings.Contains(transcript, "RCPT TO:<steve@example.com>"), transcript)
+	assert.Assert(t, strings.Contains(transcript, "Subject: Reset your gomine password"), transcript)
+	assert.Assert(t, strings.Contains(transcript, "token: abc"), transcript)
+}
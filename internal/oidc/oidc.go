@@ -0,0 +1,461 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/config"
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// PROVIDER_CONTEXT_KEY is the gin context key holding the identity provider users log in with
+const PROVIDER_CONTEXT_KEY = "oidcProvider"
+
+// HTTP_TIMEOUT bounds every request to the identity provider
+const HTTP_TIMEOUT = 10 * time.Second
+
+var (
+	ErrNotConfigured   = errors.New("OIDC login is not configured")
+	ErrInvalidIDToken  = errors.New("ID token is invalid")
+	ErrNonceMismatch   = errors.New("ID token nonce does not match the login")
+	ErrUnknownSigner   = errors.New("ID token is signed with an unknown key")
+	ErrTokenExchange   = errors.New("Could not exchange the authorization code")
+	ErrDiscoveryFailed = errors.New("Could not discover the identity provider")
+)
+
+// Config describes the identity provider and how gomine is registered with it. ClientSecret is
+// empty for public clients, which rely on PKCE alone.
+type Config struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	GroupsClaim   string
+	AdminGroups   []string
+	AutoProvision bool
+	LinkByEmail   bool
+}
+
+// Identity is what gomine learns about a user from a verified ID token
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	Groups        []string
+}
+
+// providerMetadata is the part of the discovery document gomine uses
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to an OIDC identity provider. Its metadata and signing keys are fetched lazily
+// and cached.
+type Provider struct {
+	Config *Config
+	Client *http.Client
+
+	mutex    sync.Mutex
+	metadata *providerMetadata
+	keys     map[string]interface{}
+}
+
+func splitList(value string) []string {
+	items := []string{}
+
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// LoadConfig reads the identity provider of a configuration. It returns ErrNotConfigured when no
+// issuer is set.
+func LoadConfig(settings *config.OIDCConfig) (*Config, error) {
+	if settings.Issuer == "" {
+		return nil, ErrNotConfigured
+	}
+
+	return &Config{
+		Issuer:        strings.TrimRight(settings.Issuer, "/"),
+		ClientID:      settings.ClientID,
+		ClientSecret:  settings.ClientSecret,
+		RedirectURL:   settings.RedirectURL,
+		Scopes:        splitList(settings.Scopes),
+		GroupsClaim:   settings.GroupsClaim,
+		AdminGroups:   splitList(settings.AdminGroups),
+		AutoProvision: settings.AutoProvision,
+		LinkByEmail:   settings.LinkByEmail,
+	}, nil
+}
+
+// Middleware makes a provider available to every handler through FromContext. provider is nil
+// when OIDC is not configured.
+func Middleware(provider *Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(PROVIDER_CONTEXT_KEY, provider)
+		c.Next()
+	}
+}
+
+// FromContext returns the provider set by Middleware, or nil when OIDC is not configured
+func FromContext(c *gin.Context) *Provider {
+	provider, _ := c.Value(PROVIDER_CONTEXT_KEY).(*Provider)
+
+	return provider
+}
+
+func NewProvider(config *Config) *Provider {
+	return &Provider{Config: config, Client: &http.Client{Timeout: HTTP_TIMEOUT}}
+}
+
+// IsAdmin reports whether an identity belongs to one of the groups mapped to the admin role
+func (provider *Provider) IsAdmin(identity *Identity) bool {
+	for _, group := range identity.Groups {
+		for _, adminGroup := range provider.Config.AdminGroups {
+			if group == adminGroup {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// MapsRoles reports whether roles are managed through IdP groups
+func (provider *Provider) MapsRoles() bool {
+	return len(provider.Config.AdminGroups) > 0
+}
+
+func (provider *Provider) getJSON(ctx context.Context, endpoint string, target interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+
+	if err != nil {
+		return err
+	}
+
+	response, err := provider.Client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %v responded with %v", endpoint, response.Status)
+	}
+
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+func (provider *Provider) getMetadata(ctx context.Context) (*providerMetadata, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.metadata != nil {
+		return provider.metadata, nil
+	}
+
+	metadata := providerMetadata{}
+
+	if err := provider.getJSON(ctx, provider.Config.Issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscoveryFailed, err)
+	}
+
+	if strings.TrimRight(metadata.Issuer, "/") != provider.Config.Issuer {
+		return nil, fmt.Errorf("%w: discovery document is for issuer `%v`", ErrDiscoveryFailed, metadata.Issuer)
+	}
+
+	provider.metadata = &metadata
+
+	return provider.metadata, nil
+}
+
+// GenerateCodeVerifier returns a random PKCE code verifier
+func GenerateCodeVerifier() (string, error) {
+	return randomString(32)
+}
+
+// CodeChallenge derives the S256 PKCE code challenge of a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthorizationURL returns where to send the user to log in with the identity provider
+func (provider *Provider) AuthorizationURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	metadata, err := provider.getMetadata(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.Config.ClientID)
+	query.Set("redirect_uri", provider.Config.RedirectURL)
+	query.Set("scope", strings.Join(provider.Config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades an authorization code for an ID token and returns the verified identity in it
+func (provider *Provider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*Identity, error) {
+	metadata, err := provider.getMetadata(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.Config.RedirectURL)
+	form.Set("client_id", provider.Config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	if provider.Config.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(provider.Config.ClientID), url.QueryEscape(provider.Config.ClientSecret))
+	}
+
+	response, err := provider.Client.Do(request)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenExchange, err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("%w: token endpoint responded with %v: %s", ErrTokenExchange, response.Status, body)
+	}
+
+	tokens := struct {
+		IDToken string `json:"id_token"`
+	}{}
+
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenExchange, err)
+	}
+
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: token endpoint did not return an ID token", ErrTokenExchange)
+	}
+
+	return provider.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (provider *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(
+		rawIDToken,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+
+			return provider.getSigningKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(provider.Config.Issuer),
+		jwt.WithAudience(provider.Config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	if errors.Is(err, ErrUnknownSigner) {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	identity := Identity{Issuer: provider.Config.Issuer}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Username, _ = claims["preferred_username"].(string)
+
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: sub claim is missing", ErrInvalidIDToken)
+	}
+
+	if groups, ok := claims[provider.Config.GroupsClaim].([]interface{}); ok {
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	}
+
+	return &identity, nil
+}
+
+// jsonWebKey is the part of a JWK gomine understands: RSA and EC public keys
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+func (key *jsonWebKey) publicKey() (interface{}, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeBigInt(key.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(key.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[key.Crv]
+
+		if !ok {
+			return nil, fmt.Errorf("Unsupported curve `%v`", key.Crv)
+		}
+
+		x, err := decodeBigInt(key.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(key.Y)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported key type `%v`", key.Kty)
+}
+
+// getSigningKey returns the provider's key with a kid. The key set is fetched again when the kid
+// is unknown, which picks up keys the provider rotated in.
+func (provider *Provider) getSigningKey(ctx context.Context, kid string) (interface{}, error) {
+	provider.mutex.Lock()
+	key, ok := provider.keys[kid]
+	provider.mutex.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	metadata, err := provider.getMetadata(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keySet := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+
+	if err := provider.getJSON(ctx, metadata.JWKSURI, &keySet); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+
+	for _, webKey := range keySet.Keys {
+		if webKey.Use != "" && webKey.Use != "sig" {
+			continue
+		}
+
+		if publicKey, err := webKey.publicKey(); err == nil {
+			keys[webKey.Kid] = publicKey
+		}
+	}
+
+	provider.mutex.Lock()
+	provider.keys = keys
+	provider.mutex.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	// Providers with a single key may leave kid out of their tokens
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+
+	return nil, ErrUnknownSigner
+}
@@ -0,0 +1,155 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"gotest.tools/assert"
+)
+
+// mockIdP is a minimal identity provider. Authorize stands in for the user logging in and returns
+// an authorization code bound to the PKCE challenge and nonce of an authorization URL.
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	mutex  sync.Mutex
+	codes  map[string]url.Values
+	claims jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+
+	idp := &mockIdP{key: key, codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(providerMetadata{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "mock",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mutex.Lock()
+		authorization, ok := idp.codes[r.FormValue("code")]
+		delete(idp.codes, r.FormValue("code"))
+		idp.mutex.Unlock()
+
+		if !ok || CodeChallenge(r.FormValue("code_verifier")) != authorization.Get("code_challenge") ||
+			r.FormValue("redirect_uri") != authorization.Get("redirect_uri") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   idp.server.URL,
+			"aud":   authorization.Get("client_id"),
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": authorization.Get("nonce"),
+		}
+
+		for name, value := range idp.claims {
+			claims[name] = value
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "mock"
+		idToken, err := token.SignedString(key)
+		assert.NilError(t, err)
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+	})
+
+	return idp
+}
+
+func (idp *mockIdP) authorize(t *testing.T, authorizationUrl string) string {
+	parsed, err := url.Parse(authorizationUrl)
+	assert.NilError(t, err)
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+
+	code := "code-" + parsed.Query().Get("state")
+	idp.codes[code] = parsed.Query()
+
+	return code
+}
+
+// Test the authorization code flow with PKCE against a mock identity provider and assert that
+// the identity is read from a verified ID token
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.claims = jwt.MapClaims{
+		"sub":                "alex-1",
+		"preferred_username": "alex",
+		"email":              "alex@example.com",
+		"email_verified":     true,
+		"groups":             []string{"minecraft-admins"},
+	}
+
+	provider := NewProvider(&Config{
+		Issuer:      idp.server.URL,
+		ClientID:    "gomine",
+		RedirectURL: "http://localhost:8080/api/oidc/callback",
+		Scopes:      []string{"openid"},
+		GroupsClaim: "groups",
+		AdminGroups: []string{"minecraft-admins"},
+	})
+	ctx := context.Background()
+	verifier, err := GenerateCodeVerifier()
+	assert.NilError(t, err)
+
+	authorizationUrl, err := provider.AuthorizationURL(ctx, "state-1", "nonce-1", verifier)
+	assert.NilError(t, err)
+
+	identity, err := provider.Exchange(ctx, idp.authorize(t, authorizationUrl), verifier, "nonce-1")
+	assert.NilError(t, err)
+	assert.Equal(t, "alex-1", identity.Subject)
+	assert.Equal(t, "alex", identity.Username)
+	assert.Assert(t, identity.EmailVerified)
+	assert.Assert(t, provider.IsAdmin(identity))
+
+	authorizationUrl, err = provider.AuthorizationURL(ctx, "state-2", "nonce-2", verifier)
+	assert.NilError(t, err)
+
+	_, err = provider.Exchange(ctx, idp.authorize(t, authorizationUrl), "wrong-verifier", "nonce-2")
+	assert.Assert(t, errors.Is(err, ErrTokenExchange))
+
+	authorizationUrl, err = provider.AuthorizationURL(ctx, "state-3", "nonce-3", verifier)
+	assert.NilError(t, err)
+
+	_, err = provider.Exchange(ctx, idp.authorize(t, authorizationUrl), verifier, "another-nonce")
+	assert.Assert(t, errors.Is(err, ErrNonceMismatch))
+}
@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// LOGIN_STATE_LIFESPAN is how long a user has to finish logging in with the identity provider
+const LOGIN_STATE_LIFESPAN = 10 * time.Minute
+
+// ErrInvalidState is returned for login states that are unknown, expired or already used
+var ErrInvalidState = errors.New("OIDC login state is invalid or expired. Log in again.")
+
+// LoginState is what gomine remembers between sending a user to the identity provider and the
+// user coming back
+type LoginState struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+func randomString(size int) (string, error) {
+	bytes := make([]byte, size)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CreateLoginState starts a login with a fresh state, nonce and PKCE code verifier
+func CreateLoginState(db *sql.DB) (*LoginState, error) {
+	state := LoginState{}
+	var err error
+
+	if state.State, err = randomString(32); err != nil {
+		return nil, err
+	}
+
+	if state.Nonce, err = randomString(32); err != nil {
+		return nil, err
+	}
+
+	if state.CodeVerifier, err = GenerateCodeVerifier(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	// Abandoned logins are only remembered until they expire
+	if _, err := db.Exec("delete from oidc_login_states where expires_at<=?", now.Unix()); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"insert into oidc_login_states(state, nonce, code_verifier, expires_at) values(?, ?, ?, ?)",
+		state.State, state.Nonce, state.CodeVerifier, now.Add(LOGIN_STATE_LIFESPAN).Unix(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// ConsumeLoginState looks up and forgets a login state so the callback cannot be replayed
+func ConsumeLoginState(db *sql.DB, state string) (*LoginState, error) {
+	loginState := LoginState{State: state}
+	err := db.QueryRow(
+		"delete from oidc_login_states where state=? and expires_at>? returning nonce, code_verifier", state, time.Now().Unix(),
+	).Scan(&loginState.Nonce, &loginState.CodeVerifier)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidState
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &loginState, nil
+}
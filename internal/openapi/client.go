@@ -0,0 +1,412 @@
+package openapi
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// CLIENT_HEADER marks the client as generated so it is not edited by hand
+const CLIENT_HEADER = "// Code generated by internal/openapi/clientgen from internal/openapi/openapi.json. DO NOT EDIT.\n\n"
+
+// initialisms are words spelled in capitals in Go names
+var initialisms = map[string]string{
+	"api":  "API",
+	"id":   "ID",
+	"ids":  "IDs",
+	"mfa":  "MFA",
+	"oidc": "OIDC",
+	"pid":  "PID",
+	"smtp": "SMTP",
+	"totp": "TOTP",
+	"uri":  "URI",
+	"url":  "URL",
+}
+
+// reservedNames cannot be used as the names of arguments
+var reservedNames = map[string]bool{
+	"body": true, "client": true, "ctx": true, "error": true, "query": true, "result": true, "type": true,
+}
+
+// methodOrder is the order operations on the same path are generated in
+var methodOrder = []string{"get", "post", "put", "patch", "delete"}
+
+// goName turns a JSON property, parameter or operation name into an exported Go name, like
+// ServerIDs for serverIds and AllowFlight for allow-flight
+func goName(name string) string {
+	words := []string{}
+	word := []rune{}
+
+	for i, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			words = append(words, string(word))
+			word = []rune{}
+			continue
+		}
+
+		if i > 0 && unicode.IsUpper(r) && len(word) > 0 && unicode.IsLower(word[len(word)-1]) {
+			words = append(words, string(word))
+			word = []rune{}
+		}
+
+		word = append(word, r)
+	}
+
+	words = append(words, string(word))
+	result := ""
+
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+
+		if initialism, ok := initialisms[strings.ToLower(word)]; ok {
+			result += initialism
+		} else {
+			result += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+
+	return result
+}
+
+// argumentName turns a parameter name into the name of a Go function argument
+func argumentName(name string) string {
+	argument := goName(name)
+
+	if initialism, ok := initialisms[strings.ToLower(argument)]; ok && initialism == argument {
+		argument = strings.ToLower(argument)
+	} else {
+		argument = strings.ToLower(argument[:1]) + argument[1:]
+	}
+
+	if reservedNames[argument] {
+		return argument + "Param"
+	}
+
+	return argument
+}
+
+// goType returns the Go type values of a schema are decoded into. Optional and nullable scalars
+// and structs are pointers so they can be left out.
+func goType(schema *Schema, required bool) string {
+	pointer := !required || schema.Nullable
+	var name string
+
+	switch {
+	case schema.Ref != "":
+		name = schema.RefName()
+	case schema.Type == "string" && schema.Format == "date-time":
+		name = "time.Time"
+	case schema.Type == "string":
+		name = "string"
+	case schema.Type == "integer":
+		name = "int"
+	case schema.Type == "number":
+		name = "float64"
+	case schema.Type == "boolean":
+		name = "bool"
+	case schema.Type == "array":
+		return "[]" + goType(schema.Items, true)
+	case schema.Type == "object" && schema.ValueSchema() != nil:
+		return "map[string]" + goType(schema.ValueSchema(), true)
+	case schema.Type == "object":
+		return "map[string]any"
+	default:
+		return "any"
+	}
+
+	if pointer {
+		return "*" + name
+	}
+
+	return name
+}
+
+// writeComment writes text as a Go comment, if there is any
+func writeComment(builder *strings.Builder, indent string, text string) {
+	if text == "" {
+		return
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(builder, "%v// %v\n", indent, line)
+	}
+}
+
+// writeFields writes a field for every property of an object schema, in alphabetical order
+func writeFields(builder *strings.Builder, schema *Schema) {
+	names := []string{}
+
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		property := schema.Properties[name]
+		required := schema.IsRequired(name)
+		tag := name
+
+		if !required {
+			tag += ",omitempty"
+		}
+
+		writeComment(builder, "\t", property.Description)
+		fmt.Fprintf(builder, "\t%v %v `json:\"%v\"`\n", goName(name), goType(property, required), tag)
+	}
+}
+
+// writeType writes the Go type of a named schema. Schemas combining others with allOf embed them,
+// and schemas choosing one of others with oneOf embed a pointer to each, of which one is set.
+// Objects without properties are maps.
+func writeType(builder *strings.Builder, name string, schema *Schema) {
+	if schema.Description != "" {
+		writeComment(builder, "", name+": "+schema.Description)
+	}
+
+	if schema.Type == "object" && len(schema.Properties) == 0 && len(schema.AllOf) == 0 && len(schema.OneOf) == 0 {
+		fmt.Fprintf(builder, "type %v %v\n\n", name, goType(schema, true))
+		return
+	}
+
+	fmt.Fprintf(builder, "type %v struct {\n", name)
+
+	for _, part := range schema.AllOf {
+		if part.Ref != "" {
+			fmt.Fprintf(builder, "\t%v\n", part.RefName())
+		} else {
+			writeFields(builder, part)
+		}
+	}
+
+	for _, part := range schema.OneOf {
+		fmt.Fprintf(builder, "\t*%v\n", part.RefName())
+	}
+
+	writeFields(builder, schema)
+	builder.WriteString("}\n\n")
+}
+
+// writeOperation writes the method of the client calling an operation. Parameters become
+// arguments in the order they are listed, followed by the request body.
+func writeOperation(builder *strings.Builder, path string, method string, operation *Operation) {
+	status, response := operation.SuccessResponse()
+
+	if status == "" {
+		// Operations that do not succeed with a response, like redirects to log in, are for browsers
+		return
+	}
+
+	name := goName(operation.OperationID)
+	arguments := []string{"ctx context.Context"}
+	resultType := ""
+
+	for _, parameter := range operation.Parameters {
+		arguments = append(arguments, argumentName(parameter.Name)+" string")
+	}
+
+	if operation.RequestBody != nil {
+		arguments = append(arguments, "body *"+operation.RequestBody.Content["application/json"].Schema.RefName())
+	}
+
+	if media, ok := response.Content["application/json"]; ok {
+		resultType = goType(media.Schema, media.Schema.Ref == "")
+	} else if _, ok := response.Content["text/plain"]; ok {
+		resultType = "string"
+	}
+
+	returns := "error"
+
+	if resultType != "" {
+		returns = fmt.Sprintf("(%v, error)", resultType)
+	}
+
+	writeComment(builder, "", fmt.Sprintf("%v calls %v %v: %v", name, strings.ToUpper(method), path, operation.Summary))
+
+	if operation.Deprecated {
+		writeComment(builder, "", "\nDeprecated: "+operation.Description)
+	}
+
+	fmt.Fprintf(builder, "func (client *Client) %v(%v) %v {\n", name, strings.Join(arguments, ", "), returns)
+	fmt.Fprintf(builder, "\tpath := %q\n", path)
+	builder.WriteString("\tquery := url.Values{}\n")
+
+	for _, parameter := range operation.Parameters {
+		argument := argumentName(parameter.Name)
+
+		switch {
+		case parameter.In == "path":
+			fmt.Fprintf(builder, "\tpath = strings.Replace(path, %q, url.PathEscape(%v), 1)\n", "{"+parameter.Name+"}", argument)
+		case parameter.Required:
+			fmt.Fprintf(builder, "\tquery.Set(%q, %v)\n", parameter.Name, argument)
+		default:
+			fmt.Fprintf(builder, "\tif %v != \"\" {\n\t\tquery.Set(%q, %v)\n\t}\n", argument, parameter.Name, argument)
+		}
+	}
+
+	body := "nil"
+
+	if operation.RequestBody != nil {
+		body = "body"
+	}
+
+	if resultType == "" {
+		fmt.Fprintf(builder, "\treturn client.do(ctx, %q, path, query, %v, nil)\n}\n\n", strings.ToUpper(method), body)
+		return
+	}
+
+	fmt.Fprintf(builder, "\tvar result %v\n", resultType)
+	fmt.Fprintf(builder, "\terr := client.do(ctx, %q, path, query, %v, &result)\n", strings.ToUpper(method), body)
+	builder.WriteString("\treturn result, err\n}\n\n")
+}
+
+// GenerateClient generates a typed Go client for a document. Every schema becomes a type and
+// every operation a method of Client.
+func GenerateClient(document *Document, packageName string) ([]byte, error) {
+	builder := &strings.Builder{}
+	builder.WriteString(CLIENT_HEADER)
+	fmt.Fprintf(builder, "// Package %v is a typed client of the gomine API.\npackage %v\n\n", packageName, packageName)
+	builder.WriteString(clientRuntime)
+
+	names := []string{}
+
+	for name := range document.Components.Schemas {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		writeType(builder, name, document.Components.Schemas[name])
+	}
+
+	paths := []string{}
+
+	for path := range document.Paths {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		for _, method := range methodOrder {
+			if operation, ok := document.Paths[path][method]; ok {
+				writeOperation(builder, path, method, operation)
+			}
+		}
+	}
+
+	return format.Source([]byte(builder.String()))
+}
+
+// clientRuntime is the part of the client that does not depend on the document
+const clientRuntime = `import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the gomine API
+type Client struct {
+	// BaseURL is where gomine is served, like http://localhost:8080
+	BaseURL string
+	// Token is sent as a Bearer credential. It is either an access token or an API key.
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client of the gomine API served at baseURL
+func NewClient(baseURL string, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token, HTTPClient: http.DefaultClient}
+}
+
+// Error is returned when the API responds with an error
+type Error struct {
+	StatusCode int
+	ErrorBody
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("gomine: %v %v: %v", err.StatusCode, err.Code, err.Message)
+}
+
+// do sends a request and decodes the response into result, unless result is nil
+func (client *Client) do(ctx context.Context, method string, path string, query url.Values, body any, result any) error {
+	var reader io.Reader
+
+	if body != nil {
+		encoded, err := json.Marshal(body)
+
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(encoded)
+	}
+
+	target := client.BaseURL + path
+
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, reader)
+
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	if client.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+client.Token)
+	}
+
+	response, err := client.HTTPClient.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+	contents, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= 400 {
+		var envelope ErrorResponse
+
+		if err := json.Unmarshal(contents, &envelope); err != nil || envelope.Error.Code == "" {
+			return &Error{StatusCode: response.StatusCode, ErrorBody: ErrorBody{Message: string(contents)}}
+		}
+
+		return &Error{StatusCode: response.StatusCode, ErrorBody: envelope.Error}
+	}
+
+	if text, ok := result.(*string); ok {
+		*text = string(contents)
+		return nil
+	}
+
+	if result == nil || len(contents) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(contents, result)
+}
+
+`
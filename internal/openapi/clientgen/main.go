@@ -0,0 +1,28 @@
+// Command clientgen generates the typed Go client of the API from the OpenAPI document. It is run
+// by go generate in the client package and writes client.go to the working directory.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/ecuyle/gomine/internal/openapi"
+)
+
+func main() {
+	document, err := openapi.Load()
+
+	if err != nil {
+		log.Fatalf("clientgen: Could not read the OpenAPI document: %v", err)
+	}
+
+	source, err := openapi.GenerateClient(document, "client")
+
+	if err != nil {
+		log.Fatalf("clientgen: Could not generate the client: %v", err)
+	}
+
+	if err := os.WriteFile("client.go", source, 0644); err != nil {
+		log.Fatalf("clientgen: Could not write the client: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spec is the OpenAPI 3 document describing every route of the API. It is maintained by hand and
+// checked against the routes and types it describes by tests.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Document is the part of an OpenAPI document gomine uses
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// PathItem maps lowercase HTTP methods to the operation they perform on a path
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description"`
+	Deprecated  bool                  `json:"deprecated"`
+	Security    []map[string][]string `json:"security"`
+	Parameters  []Parameter           `json:"parameters"`
+	RequestBody *RequestBody          `json:"requestBody"`
+	Responses   map[string]*Response  `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas   map[string]*Schema   `json:"schemas"`
+	Responses map[string]*Response `json:"responses"`
+}
+
+type Schema struct {
+	Ref         string             `json:"$ref"`
+	Type        string             `json:"type"`
+	Format      string             `json:"format"`
+	Description string             `json:"description"`
+	Nullable    bool               `json:"nullable"`
+	Enum        []string           `json:"enum"`
+	Required    []string           `json:"required"`
+	Properties  map[string]*Schema `json:"properties"`
+	Items       *Schema            `json:"items"`
+	AllOf       []*Schema          `json:"allOf"`
+	OneOf       []*Schema          `json:"oneOf"`
+	// AdditionalProperties is either `true` or the schema of the values of a map
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+}
+
+// SCHEMA_REF_PREFIX starts references to the schemas of a document
+const SCHEMA_REF_PREFIX = "#/components/schemas/"
+
+// Load parses Spec
+func Load() (*Document, error) {
+	var document Document
+
+	if err := json.Unmarshal(Spec, &document); err != nil {
+		return nil, err
+	}
+
+	return &document, nil
+}
+
+// RefName returns the name of the schema a schema refers to, or an empty string when it does not
+// refer to one
+func (schema *Schema) RefName() string {
+	return strings.TrimPrefix(schema.Ref, SCHEMA_REF_PREFIX)
+}
+
+// IsRequired reports whether an object schema requires a property
+func (schema *Schema) IsRequired(property string) bool {
+	for _, required := range schema.Required {
+		if required == property {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValueSchema returns the schema of the values of a map, or nil when values can be anything
+func (schema *Schema) ValueSchema() *Schema {
+	var values Schema
+
+	if err := json.Unmarshal(schema.AdditionalProperties, &values); err != nil {
+		return nil
+	}
+
+	return &values
+}
+
+// SuccessResponse returns the status and response of an operation when it succeeds. The status
+// is empty for operations that do not succeed with a 2xx status, like redirects.
+func (operation *Operation) SuccessResponse() (string, *Response) {
+	for status, response := range operation.Responses {
+		if len(status) == 3 && status[0] == '2' {
+			return status, response
+		}
+	}
+
+	return "", nil
+}
+
+// GetSpec serves Spec
+func GetSpec(context *gin.Context) {
+	context.Data(http.StatusOK, "application/json; charset=utf-8", Spec)
+}
@@ -0,0 +1,305 @@
+package openapi
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/apikeys"
+	"github.com/ecuyle/gomine/internal/authentication"
+	"github.com/ecuyle/gomine/internal/cgroups"
+	"github.com/ecuyle/gomine/internal/config"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/java"
+	"github.com/ecuyle/gomine/internal/mfa"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/registration"
+	"github.com/ecuyle/gomine/internal/servers"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/ecuyle/gomine/internal/user"
+	"gotest.tools/assert"
+)
+
+// schemaTypes maps schemas to the Go types requests and responses are bound to
+var schemaTypes = map[string]any{
+	"APIKey":                        apikeys.APIKey{},
+	"APIKeyOptions":                 apikeys.APIKeyOptions{},
+	"ActivatedMFA":                  user.ActivatedMFA{},
+	"AdminUpdateUserOptions":        user.AdminUpdateUserOptions{},
+	"AuthenticationOptions":         authentication.AuthenticationOptions{},
+	"CgroupsConfig":                 config.CgroupsConfig{},
+	"ChangePasswordOptions":         user.ChangePasswordOptions{},
+	"Config":                        config.Config{},
+	"ConsoleCommand":                servers.ConsoleCommand{},
+	"ConsoleCommandOptions":         servers.ConsoleCommandOptions{},
+	"CreatedAPIKey":                 user.CreatedAPIKey{},
+	"CreatedInvite":                 user.CreatedInvite{},
+	"DatabaseConfig":                config.DatabaseConfig{},
+	"DeleteAccountOptions":          user.DeleteAccountOptions{},
+	"DisableMFAOptions":             user.DisableMFAOptions{},
+	"EULA":                          servers.EULA{},
+	"Enrollment":                    mfa.Enrollment{},
+	"ErrorBody":                     httputils.ErrorBody{},
+	"ErrorResponse":                 httputils.ErrorResponse{},
+	"GrantOptions":                  servers.GrantOptions{},
+	"JavaConfig":                    config.JavaConfig{},
+	"JavaInstallation":              java.Installation{},
+	"Invite":                        registration.Invite{},
+	"InviteOptions":                 registration.InviteOptions{},
+	"LaunchSettings":                servers.LaunchSettings{},
+	"LoginConfig":                   config.LoginConfig{},
+	"MCServer":                      servers.MCServer{},
+	"MCServerLite":                  servers.MCServerLite{},
+	"MFAChallengeResponse":          authentication.MFAChallengeResponse{},
+	"MFAConfig":                     config.MFAConfig{},
+	"MFAOptions":                    authentication.MFAOptions{},
+	"MFAVerificationOptions":        user.MFAVerificationOptions{},
+	"OIDCConfig":                    config.OIDCConfig{},
+	"PasswordConfig":                config.PasswordConfig{},
+	"PasswordResetConfig":           config.PasswordResetConfig{},
+	"PasswordResetOptions":          user.PasswordResetOptions{},
+	"PasswordResetRequestOptions":   user.PasswordResetRequestOptions{},
+	"PortsConfig":                   config.PortsConfig{},
+	"PropertiesRevert":              servers.PropertiesRevert{},
+	"PropertiesUpdateResult":        servers.PropertiesUpdateResult{},
+	"PropertyChange":                servers.PropertyChange{},
+	"PropertyChangeRecord":          servers.PropertyChangeRecord{},
+	"RefreshOptions":                authentication.RefreshOptions{},
+	"RegistrationConfig":            config.RegistrationConfig{},
+	"RevertServerPropertiesOptions": servers.RevertServerPropertiesOptions{},
+	"ResourceLimits":                cgroups.Limits{},
+	"ResourceUsage":                 cgroups.Usage{},
+	"SMTPConfig":                    config.SMTPConfig{},
+	"ServerActionOptions":           servers.ServerActionOptions{},
+	"ServerConfig":                  config.ServerConfig{},
+	"ServerGrant":                   permissions.ServerGrant{},
+	"ServerList":                    servers.ServerList{},
+	"ServerOptions":                 servers.ServerOptions{},
+	"ServerProperties":              servers.ServerProperties{},
+	"TokenConfig":                   config.TokenConfig{},
+	"TokenPair":                     token.TokenPair{},
+	"UpdateProfileOptions":          user.UpdateProfileOptions{},
+	"UpdatedServerProperties":       servers.UpdatedServerProperties{},
+	"UserOptions":                   user.UserOptions{},
+	"UserProfile":                   user.UserProfile{},
+}
+
+// untypedSchemas describe maps and responses built from maps or combining other schemas
+var untypedSchemas = []string{"CreatedUser", "LoginResponse", "PropertiesPatch", "ServerActionResult"}
+
+// jsonFields lists the JSON fields of a struct, including the fields of embedded structs
+func jsonFields(structType reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+
+		if field.Anonymous && tag == "" {
+			for embeddedName, embeddedType := range jsonFields(field.Type) {
+				fields[embeddedName] = embeddedType
+			}
+
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+// schemaProperties lists the properties of an object schema, including those of the schemas it
+// combines with allOf
+func schemaProperties(document *Document, schema *Schema) map[string]*Schema {
+	if schema.Ref != "" {
+		return schemaProperties(document, document.Components.Schemas[schema.RefName()])
+	}
+
+	properties := map[string]*Schema{}
+
+	for _, part := range schema.AllOf {
+		for name, property := range schemaProperties(document, part) {
+			properties[name] = property
+		}
+	}
+
+	for name, property := range schema.Properties {
+		properties[name] = property
+	}
+
+	return properties
+}
+
+// jsonType is the type of the JSON values a Go type is encoded to
+func jsonType(goType reflect.Type) string {
+	if goType.Kind() == reflect.Pointer {
+		goType = goType.Elem()
+	}
+
+	if goType == reflect.TypeOf(time.Time{}) {
+		return "string"
+	}
+
+	switch goType.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+
+	return ""
+}
+
+// Test the schemas of the OpenAPI document and assert that they have the fields and types of the
+// Go types they describe
+func TestSchemasMatchTypes(t *testing.T) {
+	document, err := Load()
+	assert.NilError(t, err)
+
+	for name := range document.Components.Schemas {
+		_, typed := schemaTypes[name]
+		assert.Assert(t, typed || contains(untypedSchemas, name), "schema %v describes no Go type", name)
+	}
+
+	for name, value := range schemaTypes {
+		schema, ok := document.Components.Schemas[name]
+
+		if !ok {
+			t.Errorf("%T is not described by a schema named %v", value, name)
+			continue
+		}
+
+		fields := jsonFields(reflect.TypeOf(value))
+		properties := schemaProperties(document, schema)
+
+		for field, fieldType := range fields {
+			property, ok := properties[field]
+
+			if !ok {
+				t.Errorf("schema %v is missing property %v", name, field)
+				continue
+			}
+
+			propertyType := property.Type
+
+			if property.Ref != "" {
+				propertyType = "object"
+			}
+
+			if expected := jsonType(fieldType); expected != "" && propertyType != expected {
+				t.Errorf("property %v of schema %v is a %v, but %v is encoded as a %v", field, name, propertyType, fieldType, expected)
+			}
+		}
+
+		for property := range properties {
+			if _, ok := fields[property]; !ok {
+				t.Errorf("schema %v has property %v that %T does not have", name, property, value)
+			}
+		}
+	}
+}
+
+// Test the references of the OpenAPI document and assert that every schema referred to exists
+func TestReferencesResolve(t *testing.T) {
+	document, err := Load()
+	assert.NilError(t, err)
+
+	var check func(where string, schema *Schema)
+	check = func(where string, schema *Schema) {
+		if schema == nil {
+			return
+		}
+
+		if _, ok := document.Components.Schemas[schema.RefName()]; schema.Ref != "" && !ok {
+			t.Errorf("%v refers to unknown schema %v", where, schema.Ref)
+		}
+
+		for name, property := range schema.Properties {
+			check(where+"."+name, property)
+		}
+
+		for _, part := range append(schema.AllOf, schema.OneOf...) {
+			check(where, part)
+		}
+
+		check(where+"[]", schema.Items)
+		check(where+"{}", schema.ValueSchema())
+	}
+
+	for name, schema := range document.Components.Schemas {
+		check(name, schema)
+	}
+
+	for path, item := range document.Paths {
+		for method, operation := range item {
+			where := strings.ToUpper(method) + " " + path
+
+			if operation.RequestBody != nil {
+				check(where, operation.RequestBody.Content["application/json"].Schema)
+			}
+
+			for _, response := range operation.Responses {
+				check(where, response.Content["application/json"].Schema)
+			}
+		}
+	}
+}
+
+// Test GenerateClient and assert that the client package was generated from the current document
+func TestClientIsGenerated(t *testing.T) {
+	document, err := Load()
+	assert.NilError(t, err)
+
+	source, err := GenerateClient(document, "client")
+	assert.NilError(t, err)
+
+	generated, err := os.ReadFile("../../client/client.go")
+	assert.NilError(t, err)
+	assert.Assert(t, string(source) == string(generated), "client/client.go is out of date, run `go generate ./client`")
+}
+
+// Test goName and assert that names follow Go conventions
+func TestGoName(t *testing.T) {
+	names := map[string]string{
+		"serverIds":         "ServerIDs",
+		"allow-flight":      "AllowFlight",
+		"con.port":          "ConPort",
+		"error_description": "ErrorDescription",
+		"createAPIKey":      "CreateAPIKey",
+		"PID":               "PID",
+	}
+
+	for name, expected := range names {
+		assert.Equal(t, goName(name), expected)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
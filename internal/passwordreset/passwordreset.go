@@ -0,0 +1,125 @@
+package passwordreset
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RESET_REQUEST_INTERVAL is how often a user can be sent a reset token. It keeps the reset
+// endpoint from being used to flood someone's inbox.
+const RESET_REQUEST_INTERVAL = time.Minute
+
+var (
+	// ErrInvalidResetToken is returned for reset tokens that are unknown, expired or already used
+	ErrInvalidResetToken = errors.New("Invalid or expired password reset token")
+	// ErrTooManyRequests is returned when a reset token was issued for the user moments ago
+	ErrTooManyRequests = errors.New("A password reset was requested moments ago")
+)
+
+func hashResetToken(rawToken string) string {
+	sum := sha256.Sum256([]byte(rawToken))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func generateResetToken() (string, error) {
+	bytes := make([]byte, 32)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CreateResetToken issues a reset token for a user that is valid for lifespan and invalidates the
+// ones issued before. The raw token is returned once and only its hash is kept.
+func CreateResetToken(db *sql.DB, userId string, lifespan time.Duration) (string, time.Time, error) {
+	rawToken, err := generateResetToken()
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	defer transaction.Rollback()
+
+	now := time.Now()
+	var recentlyRequested bool
+	err = transaction.QueryRow(
+		"select exists(select 1 from password_resets where user_id=? and created_at>?)", userId, now.Add(-RESET_REQUEST_INTERVAL).Unix(),
+	).Scan(&recentlyRequested)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if recentlyRequested {
+		return "", time.Time{}, ErrTooManyRequests
+	}
+
+	if _, err := transaction.Exec("update password_resets set used_at=? where user_id=? and used_at is null", now.Unix(), userId); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := now.Add(lifespan)
+	_, err = transaction.Exec(
+		"insert into password_resets(id, user_id, hash, created_at, expires_at) values(?, ?, ?, ?, ?)",
+		id.String(), userId, hashResetToken(rawToken), now.Unix(), expiresAt.Unix(),
+	)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return rawToken, expiresAt, nil
+}
+
+// RevokeResetTokens invalidates the reset tokens a user has not used yet
+func RevokeResetTokens(db *sql.DB, userId string) error {
+	_, err := db.Exec("update password_resets set used_at=? where user_id=? and used_at is null", time.Now().Unix(), userId)
+
+	return err
+}
+
+// ConsumeResetToken uses up a reset token and returns the id of the user it was issued for
+func ConsumeResetToken(db *sql.DB, rawToken string) (string, error) {
+	now := time.Now().Unix()
+	var userId string
+	err := db.QueryRow(
+		"update password_resets set used_at=? where hash=? and used_at is null and expires_at>? returning user_id",
+		now, hashResetToken(rawToken), now,
+	).Scan(&userId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidResetToken
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return userId, nil
+}
@@ -1,9 +1,16 @@
 package passwords
 
 import (
+	"sync"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+var dummyHash struct {
+	once sync.Once
+	hash []byte
+}
+
 func GenerateHashFromPassword(password string) (string, error) {
 	hashedPasswordBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 
@@ -13,3 +20,14 @@ func GenerateHashFromPassword(password string) (string, error) {
 func ComparePasswordWithHash(passwordAttempt, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(passwordAttempt))
 }
+
+// CompareWithDummyHash spends as long as a real password comparison without being able to succeed.
+// It is used when there is no user to compare against, so response times do not reveal whether a
+// username exists.
+func CompareWithDummyHash(passwordAttempt string) {
+	dummyHash.once.Do(func() {
+		dummyHash.hash, _ = bcrypt.GenerateFromPassword([]byte("gomine-dummy-password"), bcrypt.DefaultCost)
+	})
+
+	bcrypt.CompareHashAndPassword(dummyHash.hash, []byte(passwordAttempt))
+}
@@ -0,0 +1,108 @@
+package passwords
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ecuyle/gomine/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// POLICY_CONTEXT_KEY is the gin context key holding the policy passwords are checked against
+	POLICY_CONTEXT_KEY = "passwordPolicy"
+	// PASSWORD_MAX_BYTES is the most bcrypt hashes. Anything longer would be silently truncated.
+	PASSWORD_MAX_BYTES = 72
+)
+
+var (
+	ErrPasswordTooShort = errors.New("Password is too short")
+	ErrPasswordTooLong  = fmt.Errorf("Password must be at most %v bytes long", PASSWORD_MAX_BYTES)
+	ErrPasswordBreached = errors.New("Password appears in a list of breached passwords")
+)
+
+// Policy describes which passwords are accepted. BreachedPasswords holds lowercased passwords
+// known to have leaked.
+type Policy struct {
+	MinLength         int
+	BreachedPasswords map[string]bool
+}
+
+// Validate checks a password against the policy
+func (policy *Policy) Validate(password string) error {
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return fmt.Errorf("%w, it must be at least %v characters long", ErrPasswordTooShort, policy.MinLength)
+	}
+
+	if len(password) > PASSWORD_MAX_BYTES {
+		return ErrPasswordTooLong
+	}
+
+	if policy.BreachedPasswords[strings.ToLower(password)] {
+		return ErrPasswordBreached
+	}
+
+	return nil
+}
+
+// LoadBreachedPasswords reads a list of breached passwords with one password per line
+func LoadBreachedPasswords(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	breached := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			breached[strings.ToLower(line)] = true
+		}
+	}
+
+	return breached, scanner.Err()
+}
+
+// NewPolicy builds the password policy of a password configuration, loading its list of
+// breached passwords
+func NewPolicy(config *config.PasswordConfig) (*Policy, error) {
+	policy := Policy{MinLength: config.MinLength}
+
+	if config.BreachedPasswordsFile != "" {
+		breached, err := LoadBreachedPasswords(config.BreachedPasswordsFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		policy.BreachedPasswords = breached
+	}
+
+	return &policy, nil
+}
+
+// Middleware makes a policy available to every handler through FromContext
+func Middleware(policy *Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(POLICY_CONTEXT_KEY, policy)
+		c.Next()
+	}
+}
+
+// FromContext returns the policy set by Middleware
+func FromContext(c *gin.Context) *Policy {
+	return c.MustGet(POLICY_CONTEXT_KEY).(*Policy)
+}
+
+// IsPolicyViolation reports whether an error means a password was rejected by the policy
+func IsPolicyViolation(err error) bool {
+	return errors.Is(err, ErrPasswordTooShort) || errors.Is(err, ErrPasswordTooLong) || errors.Is(err, ErrPasswordBreached)
+}
@@ -0,0 +1,28 @@
+package passwords
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Test Validate and assert that short, overlong and breached passwords are rejected
+func TestPolicyValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	assert.NilError(t, os.WriteFile(path, []byte("Password123\n\nletmein-please\n"), 0644))
+
+	breached, err := LoadBreachedPasswords(path)
+	assert.NilError(t, err)
+
+	policy := Policy{MinLength: 10, BreachedPasswords: breached}
+
+	assert.NilError(t, policy.Validate("correct horse battery"))
+	assert.Assert(t, errors.Is(policy.Validate("short"), ErrPasswordTooShort))
+	assert.Assert(t, errors.Is(policy.Validate(strings.Repeat("a", PASSWORD_MAX_BYTES+1)), ErrPasswordTooLong))
+	assert.Assert(t, errors.Is(policy.Validate("LETMEIN-PLEASE"), ErrPasswordBreached))
+	assert.Assert(t, IsPolicyViolation(policy.Validate("short")))
+}
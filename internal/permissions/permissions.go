@@ -0,0 +1,178 @@
+package permissions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Role is the account wide role of a user stored in the users table
+type Role string
+
+const (
+	// RoleAdmin can manage every server and user
+	RoleAdmin Role = "admin"
+	// RoleUser has full control over the servers they own and whatever access they are granted
+	RoleUser Role = "user"
+)
+
+// ServerRole is the role a user has on a single server
+type ServerRole string
+
+const (
+	ServerRoleNone     ServerRole = ""
+	ServerRoleViewer   ServerRole = "viewer"
+	ServerRoleOperator ServerRole = "operator"
+	ServerRoleOwner    ServerRole = "owner"
+	ServerRoleAdmin    ServerRole = "admin"
+)
+
+// Action is something a user can do to a server
+type Action string
+
+const (
+	// ActionView allows reading a server's details, properties and history
+	ActionView Action = "view"
+	// ActionOperate allows starting and stopping a server
+	ActionOperate Action = "operate"
+	// ActionConsole allows sending commands to a running server's console
+	ActionConsole Action = "console"
+	// ActionConfigure allows changing a server's properties
+	ActionConfigure Action = "configure"
+	// ActionManage allows granting and revoking access to a server
+	ActionManage Action = "manage"
+)
+
+var allowedActions = map[ServerRole][]Action{
+	ServerRoleViewer:   {ActionView},
+	ServerRoleOperator: {ActionView, ActionOperate, ActionConsole, ActionConfigure},
+	ServerRoleOwner:    {ActionView, ActionOperate, ActionConsole, ActionConfigure, ActionManage},
+	ServerRoleAdmin:    {ActionView, ActionOperate, ActionConsole, ActionConfigure, ActionManage},
+}
+
+// ErrInvalidServerRole is returned when granting a role that cannot be granted per server
+var ErrInvalidServerRole = errors.New("Server access can only be granted as `operator` or `viewer`")
+
+// ServerGrant gives a user a role on a server they do not own
+type ServerGrant struct {
+	ServerID string     `json:"serverId"`
+	UserID   string     `json:"userId"`
+	Role     ServerRole `json:"role"`
+}
+
+// Can reports whether a server role allows performing an action
+func Can(role ServerRole, action Action) bool {
+	for _, allowed := range allowedActions[role] {
+		if allowed == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsGrantable reports whether a server role can be given to another user
+func IsGrantable(role ServerRole) bool {
+	return role == ServerRoleOperator || role == ServerRoleViewer
+}
+
+// GetUserRole returns the account wide role of a user
+func GetUserRole(db *sql.DB, userId string) (Role, error) {
+	var role Role
+	err := db.QueryRow("select role from users where id=?", userId).Scan(&role)
+
+	if err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+func selectServerGrantRole(db *sql.DB, serverId string, userId string) (ServerRole, error) {
+	var role ServerRole
+	err := db.QueryRow("select role from server_grants where server_id=? and user_id=?", serverId, userId).Scan(&role)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ServerRoleNone, nil
+	}
+
+	if err != nil {
+		return ServerRoleNone, err
+	}
+
+	return role, nil
+}
+
+// ResolveServerRole determines the role a user has on a server owned by ownerId. Admins get
+// ServerRoleAdmin on every server, owners get ServerRoleOwner and everybody else gets the role
+// they were granted, or ServerRoleNone.
+func ResolveServerRole(db *sql.DB, userId string, serverId string, ownerId string) (ServerRole, error) {
+	role, err := GetUserRole(db, userId)
+
+	if err != nil {
+		return ServerRoleNone, err
+	}
+
+	if role == RoleAdmin {
+		return ServerRoleAdmin, nil
+	}
+
+	if userId == ownerId {
+		return ServerRoleOwner, nil
+	}
+
+	return selectServerGrantRole(db, serverId, userId)
+}
+
+// GrantServerAccess gives a user a role on a server, replacing any role they were granted before
+func GrantServerAccess(db *sql.DB, grant *ServerGrant) error {
+	if !IsGrantable(grant.Role) {
+		return ErrInvalidServerRole
+	}
+
+	_, err := db.Exec(
+		"insert into server_grants(server_id, user_id, role) values(?, ?, ?) on conflict(server_id, user_id) do update set role=excluded.role",
+		grant.ServerID, grant.UserID, grant.Role,
+	)
+
+	return err
+}
+
+// RevokeServerAccess removes the role a user was granted on a server
+func RevokeServerAccess(db *sql.DB, serverId string, userId string) error {
+	result, err := db.Exec("delete from server_grants where server_id=? and user_id=?", serverId, userId)
+
+	if err != nil {
+		return err
+	}
+
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return fmt.Errorf("User `%v` has no access granted to server `%v`: %w", userId, serverId, sql.ErrNoRows)
+	}
+
+	return err
+}
+
+// ListServerGrants returns every grant given on a server
+func ListServerGrants(db *sql.DB, serverId string) ([]ServerGrant, error) {
+	rows, err := db.Query("select user_id, role from server_grants where server_id=? order by user_id", serverId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	grants := []ServerGrant{}
+
+	for rows.Next() {
+		grant := ServerGrant{ServerID: serverId}
+
+		if err := rows.Scan(&grant.UserID, &grant.Role); err != nil {
+			return nil, err
+		}
+
+		grants = append(grants, grant)
+	}
+
+	return grants, rows.Err()
+}
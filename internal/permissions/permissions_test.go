@@ -0,0 +1,18 @@
+package permissions
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Test Can and assert that per-server roles only allow their actions
+func TestCan(t *testing.T) {
+	assert.Assert(t, Can(ServerRoleViewer, ActionView))
+	assert.Assert(t, !Can(ServerRoleViewer, ActionOperate))
+	assert.Assert(t, Can(ServerRoleOperator, ActionConfigure))
+	assert.Assert(t, !Can(ServerRoleOperator, ActionManage))
+	assert.Assert(t, Can(ServerRoleOwner, ActionManage))
+	assert.Assert(t, Can(ServerRoleAdmin, ActionManage))
+	assert.Assert(t, !Can(ServerRoleNone, ActionView))
+}
@@ -0,0 +1,376 @@
+package ports
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/ecuyle/gomine/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// ALLOCATOR_CONTEXT_KEY is the gin context key holding the allocator requests are served with
+const ALLOCATOR_CONTEXT_KEY = "ports"
+
+// Kind is what a port of a server is used for
+type Kind string
+
+const (
+	// KindGame is the TCP port players connect to
+	KindGame Kind = "game"
+	// KindQuery is the UDP port the server answers status queries on
+	KindQuery Kind = "query"
+	// KindRCON is the TCP port of the remote console
+	KindRCON Kind = "rcon"
+)
+
+// Kinds lists every kind of port a server is assigned, in the order they are allocated
+var Kinds = []Kind{KindGame, KindQuery, KindRCON}
+
+// PropertyKeys maps each kind of port to the server.properties key it is configured with
+var PropertyKeys = map[Kind]string{
+	KindGame:  "server-port",
+	KindQuery: "query.port",
+	KindRCON:  "rcon.port",
+}
+
+// networks maps each kind of port to the network the server listens on it with
+var networks = map[Kind]string{
+	KindGame:  "tcp",
+	KindQuery: "udp",
+	KindRCON:  "tcp",
+}
+
+var (
+	ErrInvalidPort = errors.New("Invalid port")
+	ErrPortTaken   = errors.New("Port is already assigned")
+	ErrPortInUse   = errors.New("Port is in use on the host")
+	ErrNoFreePort  = errors.New("No free port is left in the configured range")
+)
+
+// Assignment maps the kinds of port of a server to the port assigned to each
+type Assignment map[Kind]int
+
+// Allocator assigns the ports of a range to servers so no two servers listen on the same port.
+// Assignments are stored in the server_ports table.
+type Allocator struct {
+	First int
+	Last  int
+	// IsFree reports whether nothing on the host listens on a port of a network
+	IsFree func(port int, network string) bool
+	mutex  sync.Mutex
+}
+
+// NewAllocator returns an allocator of the range of a ports configuration that checks ports are
+// free on the host
+func NewAllocator(config *config.PortsConfig) *Allocator {
+	return &Allocator{First: config.First, Last: config.Last, IsFree: IsFreeOnHost}
+}
+
+// IsFreeOnHost reports whether a port of a network can be listened on
+func IsFreeOnHost(port int, network string) bool {
+	address := ":" + strconv.Itoa(port)
+
+	if network == "udp" {
+		connection, err := net.ListenPacket(network, address)
+
+		if err != nil {
+			return false
+		}
+
+		connection.Close()
+		return true
+	}
+
+	listener, err := net.Listen(network, address)
+
+	if err != nil {
+		return false
+	}
+
+	listener.Close()
+	return true
+}
+
+// ParsePort reads a port from a server.properties value, which is either a JSON number or a string.
+// Ports are numbers between 1 and 65535.
+func ParsePort(value interface{}) (int, error) {
+	port := 0
+
+	switch value := value.(type) {
+	case float64:
+		if value != math.Trunc(value) {
+			return 0, ErrInvalidPort
+		}
+
+		port = int(value)
+	case int:
+		port = value
+	case string:
+		parsed, err := strconv.Atoi(value)
+
+		if err != nil {
+			return 0, ErrInvalidPort
+		}
+
+		port = parsed
+	default:
+		return 0, ErrInvalidPort
+	}
+
+	if port < 1 || port > 65535 {
+		return 0, ErrInvalidPort
+	}
+
+	return port, nil
+}
+
+// FromProperties returns the ports set by server.properties changes. Ports cannot be removed, since
+// servers would fall back to the default port other servers may be using.
+func FromProperties(properties map[string]interface{}) (Assignment, error) {
+	assignment := Assignment{}
+
+	for _, kind := range Kinds {
+		value, ok := properties[PropertyKeys[kind]]
+
+		if !ok {
+			continue
+		}
+
+		if value == nil {
+			return nil, fmt.Errorf("%w: %v cannot be removed", ErrInvalidPort, PropertyKeys[kind])
+		}
+
+		port, err := ParsePort(value)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v must be a number between 1 and 65535, got `%v`", err, PropertyKeys[kind], value)
+		}
+
+		assignment[kind] = port
+	}
+
+	return assignment, nil
+}
+
+// Properties returns the server.properties values that configure an assignment
+func (assignment Assignment) Properties() map[string]interface{} {
+	properties := map[string]interface{}{}
+
+	for kind, port := range assignment {
+		properties[PropertyKeys[kind]] = port
+	}
+
+	return properties
+}
+
+// assignedPorts maps every assigned port to the server it is assigned to
+func assignedPorts(transaction *sql.Tx) (map[int]string, error) {
+	rows, err := transaction.Query("select port, server_id from server_ports")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	assigned := map[int]string{}
+
+	for rows.Next() {
+		var port int
+		var serverId string
+
+		if err := rows.Scan(&port, &serverId); err != nil {
+			return nil, err
+		}
+
+		assigned[port] = serverId
+	}
+
+	return assigned, rows.Err()
+}
+
+// Allocate assigns a port of every kind to a new server. Requested ports are assigned as they are
+// when they are free, and every other kind is given the first free port of the range.
+func (allocator *Allocator) Allocate(db *sql.DB, serverId string, requested Assignment) (Assignment, error) {
+	allocator.mutex.Lock()
+	defer allocator.mutex.Unlock()
+
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer transaction.Rollback()
+
+	assigned, err := assignedPorts(transaction)
+
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := Assignment{}
+
+	for _, kind := range Kinds {
+		port, ok := requested[kind]
+
+		if ok {
+			if _, taken := assigned[port]; taken {
+				return nil, fmt.Errorf("%w: %v", ErrPortTaken, port)
+			}
+
+			if !allocator.IsFree(port, networks[kind]) {
+				return nil, fmt.Errorf("%w: %v", ErrPortInUse, port)
+			}
+		} else {
+			for candidate := allocator.First; candidate <= allocator.Last; candidate++ {
+				if _, taken := assigned[candidate]; !taken && allocator.IsFree(candidate, networks[kind]) {
+					port, ok = candidate, true
+					break
+				}
+			}
+
+			if !ok {
+				return nil, fmt.Errorf("%w: %v-%v", ErrNoFreePort, allocator.First, allocator.Last)
+			}
+		}
+
+		assigned[port] = serverId
+		assignment[kind] = port
+
+		if _, err := transaction.Exec("insert into server_ports(port, server_id, kind) values(?, ?, ?)", port, serverId, kind); err != nil {
+			return nil, err
+		}
+	}
+
+	return assignment, transaction.Commit()
+}
+
+// Reassign changes ports assigned to a server. Ports the server already has keep their assignment,
+// and new ports must not be assigned to another server or in use on the host. apply, unless nil,
+// runs before the new assignment is committed, and the previous assignment is kept if it fails.
+func (allocator *Allocator) Reassign(db *sql.DB, serverId string, changes Assignment, apply func() error) error {
+	allocator.mutex.Lock()
+	defer allocator.mutex.Unlock()
+
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	assigned, err := assignedPorts(transaction)
+
+	if err != nil {
+		return err
+	}
+
+	current, err := get(transaction, serverId)
+
+	if err != nil {
+		return err
+	}
+
+	// Free the ports that change first, so a server can swap the ports of two kinds
+	for kind := range changes {
+		if port, ok := current[kind]; ok {
+			delete(assigned, port)
+		}
+	}
+
+	for _, kind := range Kinds {
+		port, ok := changes[kind]
+
+		if !ok {
+			continue
+		}
+
+		if _, taken := assigned[port]; taken {
+			return fmt.Errorf("%w: %v", ErrPortTaken, port)
+		}
+
+		if port != current[kind] && !allocator.IsFree(port, networks[kind]) {
+			return fmt.Errorf("%w: %v", ErrPortInUse, port)
+		}
+
+		assigned[port] = serverId
+
+		if _, err := transaction.Exec("delete from server_ports where server_id=? and kind=?", serverId, kind); err != nil {
+			return err
+		}
+	}
+
+	for kind, port := range changes {
+		if _, err := transaction.Exec("insert into server_ports(port, server_id, kind) values(?, ?, ?)", port, serverId, kind); err != nil {
+			return err
+		}
+	}
+
+	if apply != nil {
+		if err := apply(); err != nil {
+			return err
+		}
+	}
+
+	return transaction.Commit()
+}
+
+// querier is a database or a transaction
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Get returns the ports assigned to a server
+func Get(db *sql.DB, serverId string) (Assignment, error) {
+	return get(db, serverId)
+}
+
+func get(db querier, serverId string) (Assignment, error) {
+	rows, err := db.Query("select kind, port from server_ports where server_id=?", serverId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	assignment := Assignment{}
+
+	for rows.Next() {
+		var kind Kind
+		var port int
+
+		if err := rows.Scan(&kind, &port); err != nil {
+			return nil, err
+		}
+
+		assignment[kind] = port
+	}
+
+	return assignment, rows.Err()
+}
+
+// Release frees every port assigned to a server
+func Release(db *sql.DB, serverId string) error {
+	_, err := db.Exec("delete from server_ports where server_id=?", serverId)
+
+	return err
+}
+
+// Middleware makes an allocator available to every handler through FromContext
+func Middleware(allocator *Allocator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ALLOCATOR_CONTEXT_KEY, allocator)
+		c.Next()
+	}
+}
+
+// FromContext returns the allocator set by Middleware
+func FromContext(c *gin.Context) *Allocator {
+	return c.MustGet(ALLOCATOR_CONTEXT_KEY).(*Allocator)
+}
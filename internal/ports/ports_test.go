@@ -0,0 +1,98 @@
+package ports
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ecuyle/gomine/internal/store"
+	"gotest.tools/assert"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	db, dialect, err := store.Open(filepath.Join(t.TempDir(), "gomine.db"))
+	assert.NilError(t, err)
+	t.Cleanup(func() { db.Close() })
+	assert.NilError(t, store.Migrate(db, dialect))
+
+	return db
+}
+
+// Test Allocate and assert that servers are given distinct ports of the range that are free on
+// the host, and that ports are reused once released
+func TestAllocate(t *testing.T) {
+	db := openDatabase(t)
+	allocator := &Allocator{First: 25565, Last: 25570, IsFree: func(port int, network string) bool {
+		return port != 25566
+	}}
+
+	first, err := allocator.Allocate(db, "s1", Assignment{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, first, Assignment{KindGame: 25565, KindQuery: 25567, KindRCON: 25568})
+
+	_, err = allocator.Allocate(db, "s2", Assignment{KindGame: 25565})
+	assert.Assert(t, errors.Is(err, ErrPortTaken))
+
+	_, err = allocator.Allocate(db, "s2", Assignment{KindGame: 25566})
+	assert.Assert(t, errors.Is(err, ErrPortInUse))
+
+	second, err := allocator.Allocate(db, "s2", Assignment{KindRCON: 30000})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, second, Assignment{KindGame: 25569, KindQuery: 25570, KindRCON: 30000})
+
+	_, err = allocator.Allocate(db, "s3", Assignment{})
+	assert.Assert(t, errors.Is(err, ErrNoFreePort))
+
+	assert.NilError(t, Release(db, "s1"))
+
+	third, err := allocator.Allocate(db, "s3", Assignment{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, third, first)
+}
+
+// Test Reassign and assert that a server can swap its own ports but not take those of another, and
+// that the ports are not changed when applying them fails
+func TestReassign(t *testing.T) {
+	db := openDatabase(t)
+	allocator := &Allocator{First: 25565, Last: 25575, IsFree: func(port int, network string) bool {
+		return true
+	}}
+
+	_, err := allocator.Allocate(db, "s1", Assignment{})
+	assert.NilError(t, err)
+	_, err = allocator.Allocate(db, "s2", Assignment{})
+	assert.NilError(t, err)
+
+	err = allocator.Reassign(db, "s1", Assignment{KindGame: 25568}, nil)
+	assert.Assert(t, errors.Is(err, ErrPortTaken))
+
+	assert.NilError(t, allocator.Reassign(db, "s1", Assignment{KindGame: 25566, KindQuery: 25565}, nil))
+	assert.NilError(t, allocator.Reassign(db, "s1", Assignment{KindRCON: 25575}, nil))
+
+	failed := errors.New("Could not write server.properties")
+	err = allocator.Reassign(db, "s1", Assignment{KindRCON: 25574}, func() error { return failed })
+	assert.Assert(t, errors.Is(err, failed))
+
+	assignment, err := Get(db, "s1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, assignment, Assignment{KindGame: 25566, KindQuery: 25565, KindRCON: 25575})
+}
+
+// Test FromProperties and assert that ports are parsed from numbers and strings and cannot be
+// removed
+func TestFromProperties(t *testing.T) {
+	assignment, err := FromProperties(map[string]interface{}{"server-port": float64(25570), "query.port": "25571", "motd": "hi"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, assignment, Assignment{KindGame: 25570, KindQuery: 25571})
+
+	for _, properties := range []map[string]interface{}{
+		{"server-port": nil},
+		{"rcon.port": "high"},
+		{"server-port": float64(70000)},
+		{"server-port": 25565.5},
+	} {
+		_, err := FromProperties(properties)
+		assert.Assert(t, errors.Is(err, ErrInvalidPort), "%v", properties)
+	}
+}
@@ -0,0 +1,194 @@
+package registration
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Mode controls who may create an account
+type Mode string
+
+const (
+	// ModeOpen lets anyone who can reach the API sign up
+	ModeOpen Mode = "open"
+	// ModeInvite requires an invite code created by an admin to sign up
+	ModeInvite Mode = "invite"
+	// ModeApproval lets anyone sign up, but accounts cannot log in until an admin approves them
+	ModeApproval Mode = "approval"
+)
+
+// ErrInvalidInvite is returned for invite codes that are unknown, revoked, expired or used up
+var ErrInvalidInvite = errors.New("A valid invite code is required to sign up")
+
+// ErrInvalidInviteOptions is returned when creating an invite with negative limits
+var ErrInvalidInviteOptions = errors.New("maxUses and expiresInHours must not be negative")
+
+// Invite is a code admins hand out to let people sign up while registration is invite-only
+type Invite struct {
+	ID        string     `json:"id"`
+	CreatedBy string     `json:"createdBy"`
+	MaxUses   int        `json:"maxUses"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// InviteOptions describes an invite to create. Invites are single-use unless MaxUses says
+// otherwise and never expire unless ExpiresInHours is set.
+type InviteOptions struct {
+	MaxUses        int `json:"maxUses"`
+	ExpiresInHours int `json:"expiresInHours"`
+}
+
+func hashInviteCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func generateInviteCode() (string, error) {
+	bytes := make([]byte, 16)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CreateInvite stores a new invite created by an admin. The raw code is returned once and only
+// its hash is kept.
+func CreateInvite(db *sql.DB, createdBy string, options *InviteOptions) (*Invite, string, error) {
+	if options.MaxUses < 0 || options.ExpiresInHours < 0 {
+		return nil, "", ErrInvalidInviteOptions
+	}
+
+	code, err := generateInviteCode()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	invite := Invite{
+		ID:        id.String(),
+		CreatedBy: createdBy,
+		MaxUses:   options.MaxUses,
+		CreatedAt: time.Unix(time.Now().Unix(), 0),
+	}
+
+	if invite.MaxUses == 0 {
+		invite.MaxUses = 1
+	}
+
+	var expiresAt sql.NullInt64
+
+	if options.ExpiresInHours > 0 {
+		expiry := invite.CreatedAt.Add(time.Duration(options.ExpiresInHours) * time.Hour)
+		invite.ExpiresAt = &expiry
+		expiresAt = sql.NullInt64{Int64: expiry.Unix(), Valid: true}
+	}
+
+	_, err = db.Exec(
+		"insert into invites(id, created_by, hash, max_uses, created_at, expires_at) values(?, ?, ?, ?, ?, ?)",
+		invite.ID, invite.CreatedBy, hashInviteCode(code), invite.MaxUses, invite.CreatedAt.Unix(), expiresAt,
+	)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &invite, code, nil
+}
+
+// ListInvites returns the invites that have not been revoked, including used up and expired ones
+func ListInvites(db *sql.DB) ([]Invite, error) {
+	rows, err := db.Query("select id, created_by, max_uses, uses, created_at, expires_at from invites where revoked_at is null order by created_at")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	invites := []Invite{}
+
+	for rows.Next() {
+		invite := Invite{}
+		var createdAt int64
+		var expiresAt sql.NullInt64
+
+		if err := rows.Scan(&invite.ID, &invite.CreatedBy, &invite.MaxUses, &invite.Uses, &createdAt, &expiresAt); err != nil {
+			return nil, err
+		}
+
+		invite.CreatedAt = time.Unix(createdAt, 0)
+
+		if expiresAt.Valid {
+			expiry := time.Unix(expiresAt.Int64, 0)
+			invite.ExpiresAt = &expiry
+		}
+
+		invites = append(invites, invite)
+	}
+
+	return invites, rows.Err()
+}
+
+// RevokeInvite stops an invite from being redeemed
+func RevokeInvite(db *sql.DB, id string) error {
+	result, err := db.Exec("update invites set revoked_at=? where id=? and revoked_at is null", time.Now().Unix(), id)
+
+	if err != nil {
+		return err
+	}
+
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return fmt.Errorf("Could not find invite with id `%v`: %w", id, sql.ErrNoRows)
+	}
+
+	return err
+}
+
+// RedeemInvite uses up one use of an invite. It runs in the transaction that creates the account
+// so an invite cannot be redeemed more often than allowed.
+func RedeemInvite(transaction *sql.Tx, code string) error {
+	if code == "" {
+		return ErrInvalidInvite
+	}
+
+	result, err := transaction.Exec(
+		`update invites set uses=uses+1 where hash=? and revoked_at is null and uses<max_uses
+			and (expires_at is null or expires_at>?)`,
+		hashInviteCode(code), time.Now().Unix(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrInvalidInvite
+	}
+
+	return nil
+}
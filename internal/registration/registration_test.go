@@ -0,0 +1,46 @@
+package registration
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// Test RedeemInvite and assert that invites stop working once used up, expired or revoked
+func TestRedeemInvite(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "invites.db"))
+	assert.NilError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`create table invites (id text, created_by text, hash text, max_uses integer, uses integer default 0,
+		created_at integer, expires_at integer, revoked_at integer)`)
+	assert.NilError(t, err)
+
+	now := time.Now().Unix()
+	_, err = db.Exec(
+		`insert into invites(id, created_by, hash, max_uses, created_at, expires_at, revoked_at) values
+			('a', 'admin', ?, 2, ?, null, null), ('b', 'admin', ?, 5, ?, ?, null), ('c', 'admin', ?, 5, ?, null, ?)`,
+		hashInviteCode("twice"), now, hashInviteCode("expired"), now, now-1, hashInviteCode("revoked"), now, now,
+	)
+	assert.NilError(t, err)
+
+	redeem := func(code string) error {
+		transaction, err := db.Begin()
+		assert.NilError(t, err)
+		defer transaction.Commit()
+
+		return RedeemInvite(transaction, code)
+	}
+
+	assert.NilError(t, redeem("twice"))
+	assert.NilError(t, redeem("twice"))
+	assert.Assert(t, errors.Is(redeem("twice"), ErrInvalidInvite))
+	assert.Assert(t, errors.Is(redeem("expired"), ErrInvalidInvite))
+	assert.Assert(t, errors.Is(redeem("revoked"), ErrInvalidInvite))
+	assert.Assert(t, errors.Is(redeem("unknown"), ErrInvalidInvite))
+	assert.Assert(t, errors.Is(redeem(""), ErrInvalidInvite))
+}
@@ -0,0 +1,82 @@
+package servers
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ecuyle/gomine/internal/store"
+)
+
+// Disposition is what happens to the servers of a user whose account is deleted
+type Disposition string
+
+const (
+	// DispositionTransfer hands the servers over to another user
+	DispositionTransfer Disposition = "transfer"
+	// DispositionArchive moves the server worlds to the archive directory and forgets the servers
+	DispositionArchive Disposition = "archive"
+	// DispositionDelete removes the server worlds and forgets the servers
+	DispositionDelete Disposition = "delete"
+)
+
+// IsValidDisposition reports whether a disposition is known
+func IsValidDisposition(disposition Disposition) bool {
+	return disposition == DispositionTransfer || disposition == DispositionArchive || disposition == DispositionDelete
+}
+
+// GetArchiveFilepath returns the location a server world is archived to in the data directory
+func GetArchiveFilepath(dataPath string, serverID string) string {
+	return filepath.Join(dataPath, "archive", serverID)
+}
+
+// removeServer stops a server, archives or deletes its world and forgets about it
+func removeServer(dataStore *store.Store, dataPath string, server *MCServer, archive bool) error {
+	if IsServerRunning(server.ID) {
+		if err := stopServerProcess(server.ID); err != nil {
+			return err
+		}
+	}
+
+	if archive {
+		archivePath := GetArchiveFilepath(dataPath, server.ID)
+		log.Printf("Archiving world `%v` to `%v`", server.Path, archivePath)
+
+		if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+			return err
+		}
+
+		if err := os.Rename(server.Path, archivePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		log.Printf("Deleting world `%v`", server.Path)
+
+		if err := os.RemoveAll(server.Path); err != nil {
+			return err
+		}
+	}
+
+	return dataStore.Servers.Delete(server.ID)
+}
+
+// DisposeOfUserServers transfers, archives or deletes every server owned by a user
+func DisposeOfUserServers(dataStore *store.Store, dataPath string, userId string, disposition Disposition, transferToUserId string) error {
+	if disposition == DispositionTransfer {
+		return dataStore.Servers.Transfer(userId, transferToUserId)
+	}
+
+	records, err := dataStore.Servers.ListByUserId(userId)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		if err := removeServer(dataStore, dataPath, newMCServer(&records[i]), disposition == DispositionArchive); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
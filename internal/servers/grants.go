@@ -0,0 +1,116 @@
+package servers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// GrantOptions gives a user a role on the server identified in the path
+type GrantOptions struct {
+	UserID string                 `json:"userId"`
+	Role   permissions.ServerRole `json:"role"`
+}
+
+// respondWithServerGrants responds with the users given access to a server
+func respondWithServerGrants(context *gin.Context, server *MCServer) {
+	grants, err := permissions.ListServerGrants(store.FromContext(context).DB, server.ID)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, grants)
+}
+
+// grantServerAccess gives another user operator or viewer access to a server
+func grantServerAccess(context *gin.Context, server *MCServer, grant *permissions.ServerGrant) {
+	if grant.UserID == server.UserID {
+		httputils.RespondWithError(context, httputils.BadRequest("The owner of a server already has full access to it."))
+		return
+	}
+
+	db := store.FromContext(context).DB
+
+	if _, err := permissions.GetUserRole(db, grant.UserID); errors.Is(err, sql.ErrNoRows) {
+		httputils.RespondWithNotFound(context, errors.New("Could not find user with id: "+grant.UserID))
+		return
+	} else if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	if err := permissions.GrantServerAccess(db, grant); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusCreated(context, grant)
+}
+
+// revokeServerAccess revokes the access a user was granted to a server
+func revokeServerAccess(context *gin.Context, server *MCServer, userId string) {
+	err := permissions.RevokeServerAccess(store.FromContext(context).DB, server.ID, userId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		httputils.RespondWithError(context, httputils.NotFound("Could not find access granted to user with id: "+userId))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
+
+func GetServerGrants(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Query("s"), permissions.ActionManage)
+
+	if !ok {
+		return
+	}
+
+	respondWithServerGrants(context, server)
+}
+
+// PostServerGrant gives another user operator or viewer access to the server identified in the body
+func PostServerGrant(context *gin.Context) {
+	var grant permissions.ServerGrant
+
+	if err := context.ShouldBindJSON(&grant); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	if !permissions.IsGrantable(grant.Role) {
+		httputils.RespondWithError(context, httputils.BadRequest(permissions.ErrInvalidServerRole.Error()))
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, grant.ServerID, permissions.ActionManage)
+
+	if !ok {
+		return
+	}
+
+	grantServerAccess(context, server, &grant)
+}
+
+// DeleteServerGrant revokes the access the user in the `u` query param was granted to a server
+func DeleteServerGrant(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Query("s"), permissions.ActionManage)
+
+	if !ok {
+		return
+	}
+
+	revokeServerAccess(context, server, context.Query("u"))
+}
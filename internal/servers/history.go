@@ -0,0 +1,246 @@
+package servers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/ports"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PropertyChangeRecord is a single audited update of a server's server.properties file
+type PropertyChangeRecord struct {
+	ID        string          `json:"id"`
+	ServerID  string          `json:"serverId"`
+	UserID    string          `json:"userId"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Changes   PropertyChanges `json:"changes"`
+}
+
+// PropertiesRevert identifies the change a server's properties should be reverted to the state
+// before
+type PropertiesRevert struct {
+	ChangeID string `json:"changeId"`
+}
+
+// RevertServerPropertiesOptions identifies a server and the change its properties should be
+// reverted to the state before
+type RevertServerPropertiesOptions struct {
+	ServerID string `json:"serverId"`
+	PropertiesRevert
+}
+
+func insertPropertyChangeRecord(db *sql.DB, record *PropertyChangeRecord) error {
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	statement, err := transaction.Prepare("insert into server_property_changes(id, server_id, user_id, created_at, changes) values(?, ?, ?, ?, ?)")
+
+	if err != nil {
+		return err
+	}
+
+	defer statement.Close()
+
+	changes, err := json.Marshal(record.Changes)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = statement.Exec(record.ID, record.ServerID, record.UserID, record.CreatedAt, string(changes))
+
+	if err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+// selectPropertyChangeRecordsByServerId returns every recorded property change of a server,
+// oldest first
+func selectPropertyChangeRecordsByServerId(db *sql.DB, serverId string) ([]PropertyChangeRecord, error) {
+	statement, err := db.Prepare("select id, user_id, created_at, changes from server_property_changes where server_id=? order by created_at, id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer statement.Close()
+
+	rows, err := statement.Query(serverId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	records := []PropertyChangeRecord{}
+
+	for rows.Next() {
+		record := PropertyChangeRecord{ServerID: serverId}
+		var changes string
+
+		if err := rows.Scan(&record.ID, &record.UserID, &record.CreatedAt, &changes); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(changes), &record.Changes); err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// recordPropertyChanges stores an audit record of changes made to a server's properties by a user.
+// Updates that did not change any value are not recorded.
+func recordPropertyChanges(db *sql.DB, serverId string, userId string, changes PropertyChanges) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return err
+	}
+
+	return insertPropertyChangeRecord(db, &PropertyChangeRecord{
+		ID:        id.String(),
+		ServerID:  serverId,
+		UserID:    userId,
+		CreatedAt: time.Now().UTC(),
+		Changes:   changes,
+	})
+}
+
+// propertiesBeforeChange computes the property values that undo the change with the given id and
+// every change recorded after it. For each key, the value it had before it was first touched at or
+// after that change wins. Keys that did not exist before are mapped to nil so they get removed.
+func propertiesBeforeChange(records []PropertyChangeRecord, changeId string) (map[string]interface{}, error) {
+	start := -1
+
+	for i, record := range records {
+		if record.ID == changeId {
+			start = i
+			break
+		}
+	}
+
+	if start == -1 {
+		return nil, errors.New("Could not find property change with id: " + changeId)
+	}
+
+	restored := map[string]interface{}{}
+
+	for _, record := range records[start:] {
+		for key, change := range record.Changes {
+			if _, ok := restored[key]; ok {
+				continue
+			}
+
+			if change.Old == nil {
+				restored[key] = nil
+			} else {
+				restored[key] = *change.Old
+			}
+		}
+	}
+
+	return restored, nil
+}
+
+// respondWithPropertiesHistory responds with the recorded property changes of a server, most
+// recent first
+func respondWithPropertiesHistory(context *gin.Context, server *MCServer) {
+	records, err := selectPropertyChangeRecordsByServerId(store.FromContext(context).DB, server.ID)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	// Most recent changes are the most interesting ones to browse
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	httputils.RespondWithStatusOk(context, records)
+}
+
+// revertServerProperties restores a server's properties to the state they were in before the
+// given change. The revert itself is recorded as a new change.
+func revertServerProperties(context *gin.Context, server *MCServer, changeId string) {
+	dataStore := store.FromContext(context)
+	records, err := selectPropertyChangeRecordsByServerId(dataStore.DB, server.ID)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	restored, err := propertiesBeforeChange(records, changeId)
+
+	if err != nil {
+		httputils.RespondWithNotFound(context, err)
+		return
+	}
+
+	result, err := updateServerWorld(dataStore, ports.FromContext(context), server, token.GetAuthenticatedUserId(context), restored)
+
+	if err != nil {
+		respondWithPortsError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusCreated(context, result)
+}
+
+func GetServerPropertiesHistory(context *gin.Context) {
+	serverId := context.Query("s")
+
+	if serverId == "" {
+		httputils.RespondWithNotFound(context, errors.New("GetServerPropertiesHistory: No server id provided."))
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, serverId, permissions.ActionView)
+
+	if !ok {
+		return
+	}
+
+	respondWithPropertiesHistory(context, server)
+}
+
+func PostServerPropertiesRevert(context *gin.Context) {
+	var options RevertServerPropertiesOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, options.ServerID, permissions.ActionConfigure)
+
+	if !ok {
+		return
+	}
+
+	revertServerProperties(context, server, options.ChangeID)
+}
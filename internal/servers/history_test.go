@@ -0,0 +1,30 @@
+package servers
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+// Test propertiesBeforeChange and assert that the earliest old value of every key touched at or
+// after the reverted change is restored
+func TestPropertiesBeforeChange(t *testing.T) {
+	records := []PropertyChangeRecord{
+		{ID: "1", Changes: PropertyChanges{"difficulty": {Old: stringPointer("easy"), New: stringPointer("hard")}}},
+		{ID: "2", Changes: PropertyChanges{"difficulty": {Old: stringPointer("hard"), New: stringPointer("peaceful")}, "motd": {Old: nil, New: stringPointer("hi")}}},
+		{ID: "3", Changes: PropertyChanges{"motd": {Old: stringPointer("hi"), New: stringPointer("bye")}}},
+	}
+
+	restored, err := propertiesBeforeChange(records, "2")
+
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"difficulty": "hard", "motd": nil}, restored)
+
+	_, err = propertiesBeforeChange(records, "4")
+
+	assert.ErrorContains(t, err, "Could not find property change")
+}
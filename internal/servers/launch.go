@@ -0,0 +1,271 @@
+package servers
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/ecuyle/gomine/internal/cgroups"
+	"github.com/ecuyle/gomine/internal/config"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/java"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+)
+
+// DEFAULT_JVM_ARGS are the JVM args new servers are started with when they do not set any
+var DEFAULT_JVM_ARGS = []string{"-XX:+UseG1GC"}
+
+// ErrInvalidLaunchSettings is returned when launch settings cannot start a JVM
+var ErrInvalidLaunchSettings = errors.New("Invalid launch settings")
+
+// LaunchSettings is how the JVM of a server is started. Heap sizes of 0 are left to the JVM, and
+// an empty javaHome selects the installation closest to the Java version the server needs. Limits
+// confine the server process when the host supports cgroup v2.
+type LaunchSettings struct {
+	MinHeapMB  int             `json:"minHeapMb"`
+	MaxHeapMB  int             `json:"maxHeapMb"`
+	JVMArgs    []string        `json:"jvmArgs"`
+	ServerArgs []string        `json:"serverArgs"`
+	JavaHome   string          `json:"javaHome"`
+	Limits     *cgroups.Limits `json:"limits"`
+}
+
+// defaultLaunchSettings returns the launch settings of new servers that do not set any
+func defaultLaunchSettings(javaConfig *config.JavaConfig) *LaunchSettings {
+	return &LaunchSettings{
+		MaxHeapMB:  javaConfig.DefaultMaxHeapMB,
+		JVMArgs:    append([]string{}, DEFAULT_JVM_ARGS...),
+		ServerArgs: []string{},
+		Limits:     &cgroups.Limits{},
+	}
+}
+
+func newLaunchSettings(launch *store.Launch) LaunchSettings {
+	return LaunchSettings{
+		MinHeapMB:  launch.MinHeapMB,
+		MaxHeapMB:  launch.MaxHeapMB,
+		JVMArgs:    launch.JVMArgs,
+		ServerArgs: launch.ServerArgs,
+		JavaHome:   launch.JavaHome,
+		Limits: &cgroups.Limits{
+			CPUWeight:       launch.CPUWeight,
+			CPUQuotaPercent: launch.CPUQuotaPercent,
+			MemoryMaxMB:     launch.MemoryMaxMB,
+			PidsMax:         launch.PidsMax,
+			IOWeight:        launch.IOWeight,
+		},
+	}
+}
+
+func (launch *LaunchSettings) record() store.Launch {
+	return store.Launch{
+		MinHeapMB:       launch.MinHeapMB,
+		MaxHeapMB:       launch.MaxHeapMB,
+		JVMArgs:         launch.JVMArgs,
+		ServerArgs:      launch.ServerArgs,
+		JavaHome:        launch.JavaHome,
+		CPUWeight:       launch.Limits.CPUWeight,
+		CPUQuotaPercent: launch.Limits.CPUQuotaPercent,
+		MemoryMaxMB:     launch.Limits.MemoryMaxMB,
+		PidsMax:         launch.Limits.PidsMax,
+		IOWeight:        launch.Limits.IOWeight,
+	}
+}
+
+// mergeLaunchSettings returns requested settings over current ones. Args and limits that are not
+// requested are kept.
+func mergeLaunchSettings(current *LaunchSettings, requested *LaunchSettings) *LaunchSettings {
+	merged := *requested
+
+	if merged.JVMArgs == nil {
+		merged.JVMArgs = current.JVMArgs
+	}
+
+	if merged.ServerArgs == nil {
+		merged.ServerArgs = current.ServerArgs
+	}
+
+	if merged.Limits == nil {
+		merged.Limits = current.Limits
+	}
+
+	return &merged
+}
+
+// validateLaunchSettings checks that launch settings make a valid java command
+func validateLaunchSettings(launch *LaunchSettings) error {
+	if launch.MinHeapMB < 0 || launch.MaxHeapMB < 0 {
+		return fmt.Errorf("%w: heap sizes must not be negative", ErrInvalidLaunchSettings)
+	}
+
+	if launch.MaxHeapMB > 0 && launch.MinHeapMB > launch.MaxHeapMB {
+		return fmt.Errorf("%w: minHeapMb must not be more than maxHeapMb", ErrInvalidLaunchSettings)
+	}
+
+	limits := launch.Limits
+
+	if limits.CPUWeight < 0 || limits.CPUWeight > 10000 || limits.IOWeight < 0 || limits.IOWeight > 10000 {
+		return fmt.Errorf("%w: cpuWeight and ioWeight must be between 1 and 10000, or 0 for the default", ErrInvalidLaunchSettings)
+	}
+
+	if limits.CPUQuotaPercent < 0 || limits.MemoryMaxMB < 0 || limits.PidsMax < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidLaunchSettings)
+	}
+
+	// The JVM needs memory besides its heap, and would be killed before reaching its heap size
+	if limits.MemoryMaxMB > 0 && launch.MaxHeapMB >= limits.MemoryMaxMB {
+		return fmt.Errorf("%w: maxHeapMb must be less than memoryMaxMb", ErrInvalidLaunchSettings)
+	}
+
+	for _, arg := range launch.JVMArgs {
+		// Anything else would be taken as the main class or the jarFile to run
+		if !strings.HasPrefix(arg, "-") || arg == "-jar" {
+			return fmt.Errorf("%w: `%v` is not a JVM option", ErrInvalidLaunchSettings, arg)
+		}
+	}
+
+	return nil
+}
+
+func isSameArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// authorizeLaunchSettings checks that only admins change the JVM and server args of a server,
+// since they can run anything on the host, and its resource limits, since they protect the other
+// servers. API keys cannot change them either, even those of admins, since no scope is meant to
+// grant that. The response has already been written when false is returned.
+func authorizeLaunchSettings(context *gin.Context, current *LaunchSettings, launch *LaunchSettings) bool {
+	if isSameArgs(current.JVMArgs, launch.JVMArgs) && isSameArgs(current.ServerArgs, launch.ServerArgs) && *current.Limits == *launch.Limits {
+		return true
+	}
+
+	if key := token.GetAPIKey(context); key != nil {
+		httputils.RespondWithForbidden(context, fmt.Errorf("API key `%v` is not allowed to change the JVM and server args or the resource limits of a server", key.Prefix))
+		return false
+	}
+
+	role, err := permissions.GetUserRole(store.FromContext(context).DB, token.GetAuthenticatedUserId(context))
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return false
+	}
+
+	if role != permissions.RoleAdmin {
+		httputils.RespondWithError(context, httputils.Forbidden("Only admins can change the JVM and server args and the resource limits of a server."))
+		return false
+	}
+
+	return true
+}
+
+// launchCommand builds the command that runs a jarFile inside a world directory
+func launchCommand(installation *java.Installation, launch *LaunchSettings, worldPath string, jarFileName string) *exec.Cmd {
+	args := []string{}
+
+	if launch.MinHeapMB > 0 {
+		args = append(args, fmt.Sprintf("-Xms%vM", launch.MinHeapMB))
+	}
+
+	if launch.MaxHeapMB > 0 {
+		args = append(args, fmt.Sprintf("-Xmx%vM", launch.MaxHeapMB))
+	}
+
+	args = append(args, launch.JVMArgs...)
+	args = append(args, "-jar", jarFileName, "nogui")
+	args = append(args, launch.ServerArgs...)
+
+	cmd := exec.Command(installation.Executable(), args...)
+	cmd.Dir = worldPath
+
+	return cmd
+}
+
+// respondWithLaunchError responds to an error of creating a server or changing its launch
+// settings. Installations the host is missing are conflicts, and other errors are handled like
+// port errors.
+func respondWithLaunchError(context *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidLaunchSettings), errors.Is(err, java.ErrUnknownInstallation), errors.Is(err, java.ErrJavaTooOld):
+		httputils.RespondWithError(context, httputils.Invalid(err.Error()))
+	case errors.Is(err, java.ErrNoSuitableJava):
+		httputils.RespondWithError(context, httputils.Conflict(err.Error()))
+	default:
+		respondWithPortsError(context, err)
+	}
+}
+
+func GetLaunch(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionView)
+
+	if !ok {
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, server.Launch)
+}
+
+// PutLaunch changes how a server is started, which takes effect the next time it starts. Only
+// installations of the Java registry that meet the server's Java version can be selected.
+func PutLaunch(context *gin.Context) {
+	var requested LaunchSettings
+
+	if err := context.ShouldBindJSON(&requested); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionConfigure)
+
+	if !ok {
+		return
+	}
+
+	launch := mergeLaunchSettings(&server.Launch, &requested)
+
+	if !authorizeLaunchSettings(context, &server.Launch, launch) {
+		return
+	}
+
+	if err := validateLaunchSettings(launch); err != nil {
+		respondWithLaunchError(context, err)
+		return
+	}
+
+	if _, err := java.FromContext(context).Resolve(launch.JavaHome, server.JavaVersion); err != nil {
+		respondWithLaunchError(context, err)
+		return
+	}
+
+	dataStore := store.FromContext(context)
+	record := launch.record()
+
+	if err := dataStore.Servers.SetLaunch(server.ID, &record); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	if IsServerRunning(server.ID) {
+		if err := dataStore.Servers.SetPendingRestart(server.ID, true); err != nil {
+			httputils.RespondWithInternalServerError(context, err)
+			return
+		}
+	}
+
+	httputils.RespondWithStatusOk(context, launch)
+}
@@ -0,0 +1,65 @@
+package servers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecuyle/gomine/internal/apikeys"
+	"github.com/ecuyle/gomine/internal/cgroups"
+	"github.com/ecuyle/gomine/internal/java"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+	"gotest.tools/assert"
+)
+
+// Test launchCommand and assert that heap sizes and JVM args come before the jarFile and server
+// args after it
+func TestLaunchCommand(t *testing.T) {
+	installation := &java.Installation{Home: "/usr/lib/jvm/java-17", Version: "17.0.2", Major: 17}
+	launch := &LaunchSettings{MinHeapMB: 1024, MaxHeapMB: 4096, JVMArgs: []string{"-XX:+UseG1GC"}, ServerArgs: []string{"--nojline"}}
+
+	cmd := launchCommand(installation, launch, "worlds/s1", "1.20.1.jar")
+
+	assert.Equal(t, cmd.Path, "/usr/lib/jvm/java-17/bin/java")
+	assert.Equal(t, cmd.Dir, "worlds/s1")
+	assert.DeepEqual(t, cmd.Args[1:], []string{"-Xms1024M", "-Xmx4096M", "-XX:+UseG1GC", "-jar", "1.20.1.jar", "nogui", "--nojline"})
+
+	cmd = launchCommand(installation, &LaunchSettings{}, "worlds/s1", "1.20.1.jar")
+
+	assert.DeepEqual(t, cmd.Args[1:], []string{"-jar", "1.20.1.jar", "nogui"})
+}
+
+// Test validateLaunchSettings and assert that settings that cannot start a JVM are rejected
+func TestValidateLaunchSettings(t *testing.T) {
+	assert.NilError(t, validateLaunchSettings(&LaunchSettings{MinHeapMB: 512, Limits: &cgroups.Limits{}}))
+	assert.NilError(t, validateLaunchSettings(&LaunchSettings{MinHeapMB: 512, MaxHeapMB: 512, JVMArgs: []string{"-Dfile.encoding=UTF-8"}, Limits: &cgroups.Limits{MemoryMaxMB: 1024}}))
+
+	for _, launch := range []*LaunchSettings{
+		{MaxHeapMB: -1, Limits: &cgroups.Limits{}},
+		{MinHeapMB: 2048, MaxHeapMB: 1024, Limits: &cgroups.Limits{}},
+		{JVMArgs: []string{"-jar"}, Limits: &cgroups.Limits{}},
+		{JVMArgs: []string{"net.minecraft.server.Main"}, Limits: &cgroups.Limits{}},
+		{Limits: &cgroups.Limits{CPUWeight: 20000}},
+		{Limits: &cgroups.Limits{PidsMax: -1}},
+		{MaxHeapMB: 2048, Limits: &cgroups.Limits{MemoryMaxMB: 2048}},
+	} {
+		assert.Assert(t, errors.Is(validateLaunchSettings(launch), ErrInvalidLaunchSettings))
+	}
+}
+
+// Test authorizeLaunchSettings with an API key and assert that unchanged args pass while changed
+// args are forbidden without looking up the role of the key's user
+func TestAuthorizeLaunchSettingsWithAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	current := &LaunchSettings{JVMArgs: []string{"-XX:+UseG1GC"}, ServerArgs: []string{}, Limits: &cgroups.Limits{}}
+
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	context.Set(token.API_KEY_CONTEXT_KEY, &apikeys.APIKey{Prefix: "gmn_abcd", Scopes: []apikeys.Scope{apikeys.ScopeProperties}})
+
+	assert.Assert(t, authorizeLaunchSettings(context, current, &LaunchSettings{MaxHeapMB: 1024, JVMArgs: current.JVMArgs, ServerArgs: current.ServerArgs, Limits: current.Limits}))
+	assert.Assert(t, !authorizeLaunchSettings(context, current, &LaunchSettings{JVMArgs: []string{"-XX:OnOutOfMemoryError=sh"}, Limits: current.Limits}))
+	assert.Equal(t, recorder.Code, http.StatusForbidden)
+}
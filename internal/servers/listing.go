@@ -0,0 +1,100 @@
+package servers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// DEFAULT_PAGE_SIZE is how many servers are listed per page when no limit is requested
+const DEFAULT_PAGE_SIZE = 50
+
+// MAX_PAGE_SIZE is the most servers that can be listed per page
+const MAX_PAGE_SIZE = 200
+
+// ServerList is a page of servers. NextCursor is passed as the `cursor` query param to list the
+// next page and is left out on the last page.
+type ServerList struct {
+	Servers    []MCServerLite `json:"servers"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// parseServerQuery reads the filters, sort and page of a server listing from the query params:
+// `status` is running or stopped, `runtime` a Minecraft version, `name` part of a name, `sort` a
+// sort optionally prefixed with - to reverse it, and `limit` and `cursor` select the page
+func parseServerQuery(context *gin.Context) (*store.ServerQuery, error) {
+	query := &store.ServerQuery{
+		Runtime: context.Query("runtime"),
+		Name:    context.Query("name"),
+		Cursor:  context.Query("cursor"),
+	}
+
+	switch context.Query("status") {
+	case "":
+	case "running":
+		running := true
+		query.Status = &running
+	case "stopped":
+		stopped := false
+		query.Status = &stopped
+	default:
+		return nil, httputils.BadRequest("status must be running or stopped.")
+	}
+
+	sort := context.DefaultQuery("sort", string(store.ServerSortCreatedAt))
+	query.Descending = strings.HasPrefix(sort, "-")
+	query.Sort = store.ServerSort(strings.TrimPrefix(sort, "-"))
+
+	if !store.IsValidServerSort(query.Sort) {
+		return nil, httputils.BadRequest("sort must be createdAt or name, optionally prefixed with -.")
+	}
+
+	limit, err := strconv.Atoi(context.DefaultQuery("limit", strconv.Itoa(DEFAULT_PAGE_SIZE)))
+
+	if err != nil || limit < 1 || limit > MAX_PAGE_SIZE {
+		return nil, httputils.BadRequest("limit must be a number between 1 and " + strconv.Itoa(MAX_PAGE_SIZE) + ".")
+	}
+
+	query.Limit = limit
+
+	return query, nil
+}
+
+// respondWithServerList responds with the page of servers a query selects
+func respondWithServerList(context *gin.Context, query *store.ServerQuery) {
+	page, err := store.FromContext(context).Servers.Query(query)
+
+	if errors.Is(err, store.ErrInvalidCursor) {
+		httputils.RespondWithError(context, httputils.BadRequest("The cursor was not issued for this listing."))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	list := ServerList{Servers: []MCServerLite{}, NextCursor: page.NextCursor}
+
+	for _, record := range page.Servers {
+		list.Servers = append(list.Servers, newMCServerLite(&record))
+	}
+
+	httputils.RespondWithStatusOk(context, list)
+}
+
+// ListAllServers lists a page of the servers of every user. It is only routed for admins.
+func ListAllServers(context *gin.Context) {
+	query, err := parseServerQuery(context)
+
+	if err != nil {
+		httputils.RespondWithError(context, err)
+		return
+	}
+
+	respondWithServerList(context, query)
+}
@@ -0,0 +1,68 @@
+package servers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// liveConsoleCommands maps server.properties keys that can be applied to a running server to the
+// console command that applies them. Every other key only takes effect after a restart.
+var liveConsoleCommands = map[string]func(value string) string{
+	"difficulty": func(value string) string {
+		return fmt.Sprintf("difficulty %v", value)
+	},
+	"gamemode": func(value string) string {
+		return fmt.Sprintf("defaultgamemode %v", value)
+	},
+	"white-list": func(value string) string {
+		if value == "true" {
+			return "whitelist on"
+		}
+
+		return "whitelist off"
+	},
+}
+
+// PropertiesUpdateResult is the outcome of updating the properties of a server. Changed keys are
+// classified as either applied to the running server or requiring a restart to take effect.
+type PropertiesUpdateResult struct {
+	Properties      ServerProperties `json:"properties"`
+	AppliedLive     []string         `json:"appliedLive"`
+	RestartRequired []string         `json:"restartRequired"`
+	PendingRestart  bool             `json:"pendingRestart"`
+}
+
+// applyPropertyChanges pushes changes that can be applied live to the server if it is running.
+// When the server is not running every change is picked up on the next start, so nothing is
+// flagged as requiring a restart.
+func applyPropertyChanges(serverId string, changes PropertyChanges) (appliedLive []string, restartRequired []string) {
+	appliedLive = []string{}
+	restartRequired = []string{}
+
+	if !IsServerRunning(serverId) {
+		return appliedLive, restartRequired
+	}
+
+	for key, change := range changes {
+		command, ok := liveConsoleCommands[key]
+
+		if !ok || change.New == nil {
+			restartRequired = append(restartRequired, key)
+			continue
+		}
+
+		if err := SendConsoleCommand(serverId, command(*change.New)); err != nil {
+			log.Println(err)
+			restartRequired = append(restartRequired, key)
+			continue
+		}
+
+		appliedLive = append(appliedLive, key)
+	}
+
+	sort.Strings(appliedLive)
+	sort.Strings(restartRequired)
+
+	return appliedLive, restartRequired
+}
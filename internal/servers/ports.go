@@ -0,0 +1,84 @@
+package servers
+
+import (
+	"errors"
+	"log"
+
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/ports"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithPortsError responds to an error of creating a server or changing its properties.
+// Invalid and conflicting ports are the client's fault, anything else is an internal error.
+func respondWithPortsError(context *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ports.ErrInvalidPort):
+		httputils.RespondWithError(context, httputils.Invalid(err.Error()))
+	case errors.Is(err, ports.ErrPortTaken), errors.Is(err, ports.ErrPortInUse), errors.Is(err, ports.ErrNoFreePort):
+		httputils.RespondWithError(context, httputils.Conflict(err.Error()))
+	default:
+		httputils.RespondWithInternalServerError(context, err)
+	}
+}
+
+// AssignExistingServerPorts records the ports of servers created before ports were assigned, as
+// they are configured in their server.properties. Ports already assigned to another server are
+// logged and left for an admin to change.
+func AssignExistingServerPorts(dataStore *store.Store, allocator *ports.Allocator) error {
+	query := &store.ServerQuery{Limit: MAX_PAGE_SIZE}
+
+	for {
+		page, err := dataStore.Servers.Query(query)
+
+		if err != nil {
+			return err
+		}
+
+		for _, server := range page.Servers {
+			assigned, err := ports.Get(dataStore.DB, server.ID)
+
+			if err != nil {
+				return err
+			}
+
+			if len(assigned) > 0 {
+				continue
+			}
+
+			properties, err := GetServerProperties(server.Path)
+
+			if err != nil {
+				log.Printf("Could not read the ports of server `%v`: %v", server.ID, err)
+				continue
+			}
+
+			for _, kind := range ports.Kinds {
+				key := ports.PropertyKeys[kind]
+				port, err := ports.ParsePort(properties.GetString(key, defaultPorts[kind]))
+
+				if err == nil {
+					err = allocator.Reassign(dataStore.DB, server.ID, ports.Assignment{kind: port}, nil)
+				}
+
+				if err != nil {
+					log.Printf("Could not assign %v of server `%v`: %v", key, server.ID, err)
+				}
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+
+		query.Cursor = page.NextCursor
+	}
+}
+
+// defaultPorts are the ports Minecraft listens on when server.properties does not set them
+var defaultPorts = map[ports.Kind]string{
+	ports.KindGame:  "25565",
+	ports.KindQuery: "25565",
+	ports.KindRCON:  "25575",
+}
@@ -0,0 +1,306 @@
+package servers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/cgroups"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/java"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// STOP_TIMEOUT is how long a server is given to shut down gracefully before it is killed
+const STOP_TIMEOUT = 30 * time.Second
+
+// serverProcess is a running Minecraft server started by gomine. Console commands are written
+// to the process' stdin.
+type serverProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{}
+}
+
+var processes = struct {
+	sync.Mutex
+	byServerID map[string]*serverProcess
+}{byServerID: map[string]*serverProcess{}}
+
+// ServerActionOptions identifies the server a lifecycle action is performed on
+type ServerActionOptions struct {
+	ServerID string `json:"serverId"`
+}
+
+// ConsoleCommand is a command to run on the console of a server
+type ConsoleCommand struct {
+	Command string `json:"command" binding:"required"`
+}
+
+// ConsoleCommandOptions is a command to run on the console of the server identified in the body
+type ConsoleCommandOptions struct {
+	ServerID string `json:"serverId"`
+	ConsoleCommand
+}
+
+func getServerProcess(serverId string) (*serverProcess, bool) {
+	processes.Lock()
+	defer processes.Unlock()
+
+	process, ok := processes.byServerID[serverId]
+
+	return process, ok
+}
+
+// IsServerRunning reports whether gomine currently has a running process for the server
+func IsServerRunning(serverId string) bool {
+	_, ok := getServerProcess(serverId)
+
+	return ok
+}
+
+// startCommand starts the JVM of a server, inside a cgroup when one is given
+func startCommand(server *MCServer, installation *java.Installation, cgroup *os.File) (*exec.Cmd, io.WriteCloser, error) {
+	cmd := launchCommand(installation, &server.Launch, server.Path, fmt.Sprintf("%v.jar", server.Runtime))
+
+	if cgroup != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: int(cgroup.Fd())}
+	}
+
+	stdin, err := cmd.StdinPipe()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	return cmd, stdin, nil
+}
+
+// startServerProcess launches the server jarFile inside the server's world directory with a Java
+// installation, and inside the server's cgroup when it has one. Servers whose limits cannot be
+// enforced run unconfined, and on kernels before 5.7 servers run unconfined until they are moved
+// into their cgroup right after starting. The process is recorded in repository when it starts and
+// when it exits.
+func startServerProcess(repository store.ServerRepository, manager *cgroups.Manager, server *MCServer, installation *java.Installation) error {
+	processes.Lock()
+	defer processes.Unlock()
+
+	if _, ok := processes.byServerID[server.ID]; ok {
+		return fmt.Errorf("Server `%v` is already running", server.ID)
+	}
+
+	cgroup, err := manager.Open(server.ID, server.Launch.Limits)
+
+	// The server is started unconfined rather than not at all when its limits cannot be enforced
+	if err != nil {
+		log.Printf("Could not enforce the resource limits of server `%v`: %v", server.ID, err)
+	}
+
+	if cgroup != nil {
+		defer cgroup.Close()
+	}
+
+	log.Printf("Starting server `%v` at `%v` with Java %v...", server.ID, server.Path, installation.Version)
+	cmd, stdin, err := startCommand(server, installation, cgroup)
+
+	// Kernels before 5.7 cannot start a process inside a cgroup, so it is moved there once started
+	if err != nil && cgroup != nil {
+		log.Printf("Could not start server `%v` inside its cgroup: %v", server.ID, err)
+		cmd, stdin, err = startCommand(server, installation, nil)
+
+		if err == nil {
+			if err := cgroups.Attach(cgroup, cmd.Process.Pid); err != nil {
+				log.Printf("Could not enforce the resource limits of server `%v`: %v", server.ID, err)
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	process := &serverProcess{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	processes.byServerID[server.ID] = process
+
+	// The running status is written before the exit of the process is waited for, or a server that
+	// exits right away could be recorded as running after its exit was recorded
+	err = repository.SetProcess(server.ID, cmd.Process.Pid, true)
+
+	go func() {
+		err := cmd.Wait()
+		log.Printf("Server `%v` exited: %v", server.ID, err)
+
+		processes.Lock()
+		delete(processes.byServerID, server.ID)
+		processes.Unlock()
+
+		if err := repository.SetProcess(server.ID, -1, false); err != nil {
+			log.Println(err)
+		}
+
+		if err := manager.Remove(server.ID); err != nil {
+			log.Println(err)
+		}
+
+		close(process.done)
+	}()
+
+	return err
+}
+
+// stopServerProcess asks the server to stop through its console and kills it if it does not
+// exit within STOP_TIMEOUT
+func stopServerProcess(serverId string) error {
+	process, ok := getServerProcess(serverId)
+
+	if !ok {
+		return fmt.Errorf("Server `%v` is not running", serverId)
+	}
+
+	if err := SendConsoleCommand(serverId, "stop"); err != nil {
+		log.Println(err)
+	}
+
+	select {
+	case <-process.done:
+		return nil
+	case <-time.After(STOP_TIMEOUT):
+		log.Printf("Server `%v` did not stop within %v. Killing it.", serverId, STOP_TIMEOUT)
+		return process.cmd.Process.Kill()
+	}
+}
+
+// SendConsoleCommand writes a command to the console of a running server
+func SendConsoleCommand(serverId string, command string) error {
+	process, ok := getServerProcess(serverId)
+
+	if !ok {
+		return fmt.Errorf("Server `%v` is not running", serverId)
+	}
+
+	_, err := fmt.Fprintln(process.stdin, command)
+
+	return err
+}
+
+// startServer starts a server the authenticated user is allowed to operate and responds with its id
+func startServer(context *gin.Context, server *MCServer) {
+	if !IsEulaAccepted(server.Path) {
+		httputils.RespondWithError(context, httputils.Conflict("The EULA has not been accepted for this server."))
+		return
+	}
+
+	// The installation may have been removed from the host since the server was configured
+	installation, err := java.FromContext(context).Resolve(server.Launch.JavaHome, server.JavaVersion)
+
+	if err != nil {
+		httputils.RespondWithError(context, httputils.Conflict(err.Error()))
+		return
+	}
+
+	if err := startServerProcess(store.FromContext(context).Servers, cgroups.FromContext(context), server, installation); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, map[string]string{"serverId": server.ID})
+}
+
+// stopServer stops a server the authenticated user is allowed to operate and responds with its id
+func stopServer(context *gin.Context, server *MCServer) {
+	if !IsServerRunning(server.ID) {
+		httputils.RespondWithError(context, httputils.Conflict("Server is not running."))
+		return
+	}
+
+	if err := stopServerProcess(server.ID); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, map[string]string{"serverId": server.ID})
+}
+
+// sendConsoleCommand runs a single line command on the console of a running server
+func sendConsoleCommand(context *gin.Context, server *MCServer, command string) {
+	if strings.ContainsAny(command, "\r\n") {
+		httputils.RespondWithError(context, httputils.BadRequest("Commands must be a single line."))
+		return
+	}
+
+	if !IsServerRunning(server.ID) {
+		httputils.RespondWithError(context, httputils.Conflict("Server is not running."))
+		return
+	}
+
+	if err := SendConsoleCommand(server.ID, command); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	context.Status(http.StatusAccepted)
+}
+
+func PostServerStart(context *gin.Context) {
+	var options ServerActionOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, options.ServerID, permissions.ActionOperate)
+
+	if !ok {
+		return
+	}
+
+	startServer(context, server)
+}
+
+func PostServerStop(context *gin.Context) {
+	var options ServerActionOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, options.ServerID, permissions.ActionOperate)
+
+	if !ok {
+		return
+	}
+
+	stopServer(context, server)
+}
+
+func PostServerConsoleCommand(context *gin.Context) {
+	var options ConsoleCommandOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, options.ServerID, permissions.ActionConsole)
+
+	if !ok {
+		return
+	}
+
+	sendConsoleCommand(context, server, options.Command)
+}
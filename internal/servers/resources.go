@@ -0,0 +1,312 @@
+package servers
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/ports"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+)
+
+// The handlers in this file serve the /api/v1/servers resource tree, where the server is identified
+// by the `id` path param. They share their behaviour with the deprecated /api/mcsrv routes.
+
+// DEFAULT_LOG_LINES is how many lines of a server's log are returned when no count is requested
+const DEFAULT_LOG_LINES = 100
+
+// MAX_LOG_LINES is the most lines of a server's log that can be requested at once
+const MAX_LOG_LINES = 1000
+
+// EULA is whether the Minecraft EULA has been accepted for a server
+type EULA struct {
+	Accepted *bool `json:"accepted" binding:"required"`
+}
+
+// GetLogFilepath returns the location of the log a server is currently writing
+func GetLogFilepath(worldpath string) string {
+	return filepath.Join(worldpath, "logs", "latest.log")
+}
+
+// tailLines returns at most the last count lines of a file
+func tailLines(path string, count int) ([]string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lines := []string{}
+
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+
+		if len(lines) > count {
+			lines = lines[1:]
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// ListServers lists a page of the servers owned by the user given in the optional `userId` query
+// param, which defaults to the authenticated user
+func ListServers(context *gin.Context) {
+	userId := context.DefaultQuery("userId", token.GetAuthenticatedUserId(context))
+
+	if !canListServersOf(context, userId) {
+		return
+	}
+
+	query, err := parseServerQuery(context)
+
+	if err != nil {
+		httputils.RespondWithError(context, err)
+		return
+	}
+
+	query.UserID = userId
+
+	if key := token.GetAPIKey(context); key != nil && len(key.ServerIDs) > 0 {
+		query.IDs = key.ServerIDs
+	}
+
+	respondWithServerList(context, query)
+}
+
+func GetServer(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionView)
+
+	if !ok {
+		return
+	}
+
+	respondWithServerDetails(context, server)
+}
+
+func GetProperties(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionView)
+
+	if !ok {
+		return
+	}
+
+	if err := populateServerWithProperties(server); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, server.Properties)
+}
+
+// PatchProperties updates the properties given in the body and leaves the others untouched.
+// Properties set to null are removed.
+func PatchProperties(context *gin.Context) {
+	var properties map[string]interface{}
+
+	if err := context.ShouldBindJSON(&properties); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionConfigure)
+
+	if !ok {
+		return
+	}
+
+	result, err := updateServerWorld(store.FromContext(context), ports.FromContext(context), server, token.GetAuthenticatedUserId(context), properties)
+
+	if err != nil {
+		respondWithPortsError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, result)
+}
+
+func GetPropertiesHistory(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionView)
+
+	if !ok {
+		return
+	}
+
+	respondWithPropertiesHistory(context, server)
+}
+
+func PostPropertiesRevert(context *gin.Context) {
+	var options PropertiesRevert
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionConfigure)
+
+	if !ok {
+		return
+	}
+
+	revertServerProperties(context, server, options.ChangeID)
+}
+
+func GetEULA(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionView)
+
+	if !ok {
+		return
+	}
+
+	accepted := IsEulaAccepted(server.Path)
+	httputils.RespondWithStatusOk(context, EULA{Accepted: &accepted})
+}
+
+// PutEULA accepts or declines the Minecraft EULA for a server. It has to be accepted before the
+// server can be started.
+func PutEULA(context *gin.Context) {
+	var eula EULA
+
+	if err := context.ShouldBindJSON(&eula); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionConfigure)
+
+	if !ok {
+		return
+	}
+
+	if err := UpdateEULA(*eula.Accepted, server.Path); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	accepted := IsEulaAccepted(server.Path)
+	httputils.RespondWithStatusOk(context, EULA{Accepted: &accepted})
+}
+
+func PostStart(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionOperate)
+
+	if !ok {
+		return
+	}
+
+	startServer(context, server)
+}
+
+func PostStop(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionOperate)
+
+	if !ok {
+		return
+	}
+
+	stopServer(context, server)
+}
+
+func PostConsoleCommand(context *gin.Context) {
+	var command ConsoleCommand
+
+	if err := context.ShouldBindJSON(&command); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionConsole)
+
+	if !ok {
+		return
+	}
+
+	sendConsoleCommand(context, server, command.Command)
+}
+
+// GetLogs responds with the last lines of the log a server is writing. The number of lines is
+// given in the optional `lines` query param.
+func GetLogs(context *gin.Context) {
+	count, err := strconv.Atoi(context.DefaultQuery("lines", strconv.Itoa(DEFAULT_LOG_LINES)))
+
+	if err != nil || count < 1 || count > MAX_LOG_LINES {
+		httputils.RespondWithError(context, httputils.BadRequest("lines must be a number between 1 and "+strconv.Itoa(MAX_LOG_LINES)+"."))
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionConsole)
+
+	if !ok {
+		return
+	}
+
+	lines, err := tailLines(GetLogFilepath(server.Path), count)
+
+	if errors.Is(err, os.ErrNotExist) {
+		httputils.RespondWithError(context, httputils.NotFound("Server has not written any logs yet."))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	context.String(http.StatusOK, strings.Join(lines, "\n"))
+}
+
+func GetGrants(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionManage)
+
+	if !ok {
+		return
+	}
+
+	respondWithServerGrants(context, server)
+}
+
+// PostGrant gives another user operator or viewer access to a server
+func PostGrant(context *gin.Context) {
+	var options GrantOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	if !permissions.IsGrantable(options.Role) {
+		httputils.RespondWithError(context, httputils.BadRequest(permissions.ErrInvalidServerRole.Error()))
+		return
+	}
+
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionManage)
+
+	if !ok {
+		return
+	}
+
+	grantServerAccess(context, server, &permissions.ServerGrant{ServerID: server.ID, UserID: options.UserID, Role: options.Role})
+}
+
+// DeleteGrant revokes the access the user in the `userId` path param was granted to a server
+func DeleteGrant(context *gin.Context) {
+	server, ok := getAuthorizedServer(context, context.Param("id"), permissions.ActionManage)
+
+	if !ok {
+		return
+	}
+
+	revokeServerAccess(context, server, context.Param("userId"))
+}
@@ -0,0 +1,30 @@
+package servers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Test tailLines and assert that only the last lines of a file are returned
+func TestTailLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "latest.log")
+	assert.NilError(t, os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644))
+
+	lines, err := tailLines(path, 2)
+
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"two", "three"}, lines)
+
+	lines, err = tailLines(path, 10)
+
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"one", "two", "three"}, lines)
+
+	_, err = tailLines(filepath.Join(t.TempDir(), "missing.log"), 2)
+
+	assert.Assert(t, errors.Is(err, os.ErrNotExist))
+}
@@ -3,13 +3,21 @@ package servers
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os/exec"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
-
+	"github.com/ecuyle/gomine/internal/apikeys"
+	"github.com/ecuyle/gomine/internal/cgroups"
+	"github.com/ecuyle/gomine/internal/config"
 	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/java"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/ports"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/magiconair/properties"
@@ -27,12 +35,12 @@ func GetDefaults(context *gin.Context) {
 // a new server world and will contain all necessary server files (ie. eula.txt, server.properties,
 // server jarFile). After creating the new directory with the given uuid name, the appropriate
 // jarFile corresponding with the provided versionID will be copied into the world and the jarFile
-// will be run to instantiate required server files.
+// will be run with a Java installation to instantiate required server files.
 //
 // The path to this new directory is returned upon successful operation.
-func makeWorld(uuid string, jarFileName string) (string, error) {
-	worldPath := GetServerFilepath(uuid)
-	jarFilePath := GetJarFilepath(jarFileName)
+func makeWorld(dataPath string, uuid string, jarFileName string, installation *java.Installation, launch *LaunchSettings) (string, error) {
+	worldPath := GetServerFilepath(dataPath, uuid)
+	jarFilePath := GetJarFilepath(dataPath, jarFileName)
 
 	log.Printf("Creating world at `%v`", worldPath)
 	if err := exec.Command("mkdir", "-p", worldPath).Run(); err != nil {
@@ -44,9 +52,8 @@ func makeWorld(uuid string, jarFileName string) (string, error) {
 		return "", err
 	}
 
-	log.Printf("Initializing server jarFile at `%v`...", worldPath)
-	cmd := exec.Command("java", "-jar", jarFileName)
-	cmd.Dir = worldPath
+	log.Printf("Initializing server jarFile at `%v` with Java %v...", worldPath, installation.Version)
+	cmd := launchCommand(installation, launch, worldPath, jarFileName)
 	if err := cmd.Run(); err != nil {
 		log.Println(err)
 		return "", err
@@ -59,20 +66,21 @@ func makeWorld(uuid string, jarFileName string) (string, error) {
 
 type ServerOptions struct {
 	Name           string                 `json:"name"`
-	UserID         string                 `json:"userId"`
 	Runtime        string                 `json:"runtime"`
 	IsEulaAccepted bool                   `json:"isEulaAccepted"`
 	Config         map[string]interface{} `json:"config"`
+	Launch         *LaunchSettings        `json:"launch"`
 }
 
 type MCServerLite struct {
-	ID      string
-	Name    string
-	PID     int
-	Path    string
-	Runtime string
-	Status  bool
-	UserID  string
+	ID        string
+	Name      string
+	PID       int
+	Path      string
+	Runtime   string
+	Status    bool
+	UserID    string
+	CreatedAt time.Time
 }
 
 // MCServer struct
@@ -80,58 +88,147 @@ type MCServer struct {
 	ID             string
 	IsEulaAccepted bool
 	Name           string
+	PendingRestart bool
 	PID            int
 	Path           string
 	Properties     ServerProperties
 	Runtime        string
 	Status         bool
 	UserID         string
+	Launch         LaunchSettings
+	// JavaVersion is the major Java version the server needs, or 0 when it is not known
+	JavaVersion int
+	// Usage is read from the cgroup of running servers, and is nil when servers are not confined
+	Usage     *cgroups.Usage
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func newMCServerLite(record *store.Server) MCServerLite {
+	return MCServerLite{
+		ID:        record.ID,
+		Name:      record.Name,
+		PID:       record.PID,
+		Path:      record.Path,
+		Runtime:   record.Runtime,
+		Status:    record.Status,
+		UserID:    record.UserID,
+		CreatedAt: record.CreatedAt,
+	}
+}
+
+func newMCServer(record *store.Server) *MCServer {
+	return &MCServer{
+		ID:             record.ID,
+		Name:           record.Name,
+		PendingRestart: record.PendingRestart,
+		PID:            record.PID,
+		Path:           record.Path,
+		Runtime:        record.Runtime,
+		Status:         record.Status,
+		UserID:         record.UserID,
+		Launch:         newLaunchSettings(&record.Launch),
+		JavaVersion:    record.JavaVersion,
+		CreatedAt:      record.CreatedAt,
+		UpdatedAt:      record.UpdatedAt,
+	}
+}
+
+func (server *MCServer) record() *store.Server {
+	return &store.Server{
+		ID:             server.ID,
+		Name:           server.Name,
+		Runtime:        server.Runtime,
+		Path:           server.Path,
+		PID:            server.PID,
+		Status:         server.Status,
+		PendingRestart: server.PendingRestart,
+		UserID:         server.UserID,
+		Launch:         server.Launch.record(),
+		JavaVersion:    server.JavaVersion,
+		CreatedAt:      server.CreatedAt,
+		UpdatedAt:      server.UpdatedAt,
+	}
 }
 
-// makeServer creates a server world directory for a user to later manage
-func makeServer(options *ServerOptions) (*MCServer, error) {
+// makeServer creates a server world directory for a user to later manage. The server is assigned
+// its own ports, unless the options request some, and is started with the launch settings of the
+// options. The property changes applied on top of the generated server.properties are returned
+// so they can be recorded.
+func makeServer(dataPath string, dataStore *store.Store, allocator *ports.Allocator, registry *java.Registry, options *ServerOptions, userId string) (*MCServer, PropertyChanges, error) {
 	runtime := options.Runtime
+	requestedPorts, err := ports.FromProperties(options.Config)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := validateLaunchSettings(options.Launch); err != nil {
+		return nil, nil, err
+	}
 
 	// TODO: This can all probably be cached
 	version, err := GetVersionByID(runtime)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	versionDetails, err := GetVersionDetail(version.URL)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	jarFileName, err := DownloadJarFileIfNeeded(*versionDetails)
+	javaVersion := versionDetails.RequiredJavaVersion()
+	installation, err := registry.Resolve(options.Launch.JavaHome, javaVersion)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	jarFileName, err := DownloadJarFileIfNeeded(dataPath, *versionDetails)
+
+	if err != nil {
+		return nil, nil, err
 	}
 
 	id, err := uuid.NewRandom()
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	worldPath, err := makeWorld(id.String(), jarFileName)
+	worldPath, err := makeWorld(dataPath, id.String(), jarFileName, installation, options.Launch)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	isEulaAccepted := options.IsEulaAccepted
 
 	if err := UpdateEULA(isEulaAccepted, worldPath); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	updatedServerProperties, err := UpdateServerProperties(options.Config, worldPath)
+	assignment, err := allocator.Allocate(dataStore.DB, id.String(), requestedPorts)
+
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	properties := assignment.Properties()
+
+	for key, value := range options.Config {
+		if _, ok := properties[key]; !ok {
+			properties[key] = value
+		}
+	}
+
+	updatedServerProperties, changes, err := UpdateServerProperties(properties, worldPath)
+	if err != nil {
+		ports.Release(dataStore.DB, id.String())
+		return nil, nil, err
 	}
 
 	server := MCServer{
@@ -143,68 +240,56 @@ func makeServer(options *ServerOptions) (*MCServer, error) {
 		Properties:     *updatedServerProperties,
 		Runtime:        runtime,
 		Status:         false,
-		UserID:         options.UserID,
+		UserID:         userId,
+		Launch:         *options.Launch,
+		JavaVersion:    javaVersion,
 	}
 
-	return &server, nil
+	return &server, changes, nil
 }
 
-func insertServerRecord(server *MCServer) error {
-	db, err := sql.Open("sqlite3", "./gomine.db")
-
-	if err != nil {
-		return err
-	}
-
-	defer db.Close()
-	transaction, err := db.Begin()
-
-	if err != nil {
-		return err
-	}
-
-	statement, err := transaction.Prepare("insert into servers(id, name, runtime, path, pid, status, user_id) values(?, ?, ?, ?, ?, ?, ?)")
+func PostServer(context *gin.Context) {
+	var options ServerOptions
 
-	if err != nil {
-		return err
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
 	}
 
-	defer statement.Close()
+	settings := config.FromContext(context)
+	launch := defaultLaunchSettings(&settings.Java)
 
-	_, err = statement.Exec(server.ID, server.Name, server.Runtime, server.Path, server.PID, server.Status, server.UserID)
+	if options.Launch != nil {
+		options.Launch = mergeLaunchSettings(launch, options.Launch)
 
-	if err != nil {
-		return err
+		if !authorizeLaunchSettings(context, launch, options.Launch) {
+			return
+		}
+	} else {
+		options.Launch = launch
 	}
 
-	err = transaction.Commit()
+	dataStore := store.FromContext(context)
+	server, changes, err := makeServer(settings.Server.DataPath, dataStore, ports.FromContext(context), java.FromContext(context), &options, token.GetAuthenticatedUserId(context))
 
 	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func PostServer(context *gin.Context) {
-	var options ServerOptions
-
-	if err := context.BindJSON(&options); err != nil {
-		log.Println(err)
-		context.String(http.StatusBadRequest, err.Error())
+		respondWithLaunchError(context, err)
 		return
 	}
 
-	server, err := makeServer(&options)
+	record := server.record()
+	err = dataStore.Servers.Insert(record)
 
 	if err != nil {
+		ports.Release(dataStore.DB, server.ID)
 		httputils.RespondWithInternalServerError(context, err)
 		return
 	}
 
-	err = insertServerRecord(server)
+	server.CreatedAt = record.CreatedAt
+	server.UpdatedAt = record.UpdatedAt
 
-	if err != nil {
+	if err := recordPropertyChanges(dataStore.DB, server.ID, server.UserID, changes); err != nil {
 		httputils.RespondWithInternalServerError(context, err)
 		return
 	}
@@ -217,61 +302,77 @@ type UpdatedServerProperties struct {
 	ServerProperties map[string]interface{} `json:"serverProperties"`
 }
 
-func updateServerWorld(serverId string, properties map[string]interface{}) (*ServerProperties, error) {
-	filepath := GetServerFilepath(serverId)
-	updatedProperties, err := UpdateServerProperties(properties, filepath)
+// updateServerWorld changes the properties of a server and records the changes. Port changes are
+// rejected when the ports are assigned to other servers, and only assigned once server.properties
+// is written.
+func updateServerWorld(dataStore *store.Store, allocator *ports.Allocator, server *MCServer, userId string, properties map[string]interface{}) (*PropertiesUpdateResult, error) {
+	portChanges, err := ports.FromProperties(properties)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return updatedProperties, nil
-}
-
-func PutServerProperties(context *gin.Context) {
-	var options UpdatedServerProperties
+	var updatedProperties *ServerProperties
+	var changes PropertyChanges
+	write := func() error {
+		updatedProperties, changes, err = UpdateServerProperties(properties, server.Path)
 
-	if err := context.BindJSON(&options); err != nil {
-		log.Println(err)
-		context.String(http.StatusBadRequest, err.Error())
-		return
+		return err
 	}
 
-	updatedProperties, err := updateServerWorld(options.ServerID, options.ServerProperties)
+	if len(portChanges) > 0 {
+		err = allocator.Reassign(dataStore.DB, server.ID, portChanges, write)
+	} else {
+		err = write()
+	}
 
 	if err != nil {
-		httputils.RespondWithInternalServerError(context, err)
-		return
+		return nil, err
 	}
 
-	httputils.RespondWithStatusCreated(context, updatedProperties)
-}
+	if err := recordPropertyChanges(dataStore.DB, server.ID, userId, changes); err != nil {
+		return nil, err
+	}
 
-func selectServerRecordById(id string) (*MCServer, error) {
-	db, err := sql.Open("sqlite3", "./gomine.db")
+	appliedLive, restartRequired := applyPropertyChanges(server.ID, changes)
+	pendingRestart := len(restartRequired) > 0
 
-	if err != nil {
-		return nil, err
+	if pendingRestart {
+		if err := dataStore.Servers.SetPendingRestart(server.ID, true); err != nil {
+			return nil, err
+		}
 	}
 
-	defer db.Close()
+	return &PropertiesUpdateResult{
+		Properties:      *updatedProperties,
+		AppliedLive:     appliedLive,
+		RestartRequired: restartRequired,
+		PendingRestart:  pendingRestart,
+	}, nil
+}
 
-	statement, err := db.Prepare("select name, runtime, path, pid, status, user_id from servers where id=?")
+func PutServerProperties(context *gin.Context) {
+	var options UpdatedServerProperties
 
-	if err != nil {
-		return nil, err
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
 	}
 
-	defer statement.Close()
+	server, ok := getAuthorizedServer(context, options.ServerID, permissions.ActionConfigure)
 
-	server := MCServer{ID: id}
-	err = statement.QueryRow(id).Scan(&server.Name, &server.Runtime, &server.Path, &server.PID, &server.Status, &server.UserID)
+	if !ok {
+		return
+	}
+
+	result, err := updateServerWorld(store.FromContext(context), ports.FromContext(context), server, token.GetAuthenticatedUserId(context), options.ServerProperties)
 
 	if err != nil {
-		return nil, err
+		respondWithPortsError(context, err)
+		return
 	}
 
-	return &server, nil
+	httputils.RespondWithStatusCreated(context, result)
 }
 
 func populateServerWithProperties(server *MCServer) error {
@@ -296,90 +397,145 @@ func populateServerWithEulaAcceptanceStatus(server *MCServer) error {
 	return nil
 }
 
-func GetServerDetails(context *gin.Context) {
-	serverId := context.Query("s")
+// respondWithServerDetails responds with a server along with its properties and EULA status
+func respondWithServerDetails(context *gin.Context, server *MCServer) {
+	err := populateServerWithProperties(server)
 
-	if serverId == "" {
-		httputils.RespondWithNotFound(context, errors.New("GetServerDetails: No server id provided."))
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
 		return
 	}
 
-	server, err := selectServerRecordById(serverId)
+	err = populateServerWithEulaAcceptanceStatus(server)
 
 	if err != nil {
 		httputils.RespondWithInternalServerError(context, err)
 		return
 	}
 
-	err = populateServerWithProperties(server)
+	if IsServerRunning(server.ID) {
+		if usage, err := cgroups.FromContext(context).Usage(server.ID); err == nil {
+			server.Usage = usage
+		}
+	}
 
-	if err != nil {
-		httputils.RespondWithInternalServerError(context, err)
+	httputils.RespondWithStatusOk(context, server)
+}
+
+func GetServerDetails(context *gin.Context) {
+	serverId := context.Query("s")
+
+	if serverId == "" {
+		httputils.RespondWithNotFound(context, errors.New("GetServerDetails: No server id provided."))
 		return
 	}
 
-	err = populateServerWithEulaAcceptanceStatus(server)
+	server, ok := getAuthorizedServer(context, serverId, permissions.ActionView)
 
-	if err != nil {
-		httputils.RespondWithInternalServerError(context, err)
+	if !ok {
 		return
 	}
 
-	httputils.RespondWithStatusOk(context, server)
+	respondWithServerDetails(context, server)
 }
 
-func selectServerRecordsByUserId(id string) (*[]MCServerLite, error) {
-	db, err := sql.Open("sqlite3", "./gomine.db")
+// GetServersByUserId lists the servers owned by the user given in the optional `u` query param,
+// which defaults to the authenticated user
+func GetServersByUserId(context *gin.Context) {
+	userId := context.DefaultQuery("u", token.GetAuthenticatedUserId(context))
 
-	if err != nil {
-		return nil, err
+	if !canListServersOf(context, userId) {
+		return
 	}
 
-	defer db.Close()
-
-	statement, err := db.Prepare("select name, runtime, path, pid, status, user_id from servers where user_id=?")
+	records, err := store.FromContext(context).Servers.ListByUserId(userId)
 
 	if err != nil {
-		return nil, err
+		httputils.RespondWithInternalServerError(context, err)
+		return
 	}
 
-	defer statement.Close()
-
-	rows, err := statement.Query(id)
+	key := token.GetAPIKey(context)
+	servers := []MCServerLite{}
 
-	if err != nil {
-		return nil, err
+	for _, record := range records {
+		if key == nil || key.AppliesTo(record.ID) {
+			servers = append(servers, newMCServerLite(&record))
+		}
 	}
 
-	defer rows.Close()
-	servers := []MCServerLite{}
+	httputils.RespondWithStatusOk(context, servers)
+}
 
-	for rows.Next() {
-		server := MCServerLite{ID: id}
-		err = rows.Scan(&server.Name, &server.Runtime, &server.Path, &server.PID, &server.Status, &server.UserID)
+// canListServersOf checks that the authenticated user may list the servers owned by a user. Only
+// admins may list the servers of other users. The response has already been written when false is
+// returned.
+func canListServersOf(context *gin.Context, userId string) bool {
+	authenticatedUserId := token.GetAuthenticatedUserId(context)
 
-		if err == nil {
-			servers = append(servers, server)
+	if userId != authenticatedUserId {
+		role, err := permissions.GetUserRole(store.FromContext(context).DB, authenticatedUserId)
+
+		if err != nil {
+			httputils.RespondWithInternalServerError(context, err)
+			return false
+		}
+
+		if role != permissions.RoleAdmin {
+			httputils.RespondWithNotFound(context, errors.New("No servers found for user."))
+			return false
 		}
 	}
 
-	return &servers, nil
+	key := token.GetAPIKey(context)
+
+	if key != nil && !key.HasScope(apikeys.ScopeRead) {
+		httputils.RespondWithForbidden(context, fmt.Errorf("API key `%v` is not allowed to list servers", key.Prefix))
+		return false
+	}
+
+	return true
 }
 
-func GetServersByUserId(context *gin.Context) {
-	userId := context.Query("u")
+// getAuthorizedServer loads a server the authenticated user is allowed to perform an action on.
+// Servers that do not exist and servers the user has no access to are both reported as not found
+// so server ids cannot be probed. The response has already been written when false is returned.
+func getAuthorizedServer(context *gin.Context, serverId string, action permissions.Action) (*MCServer, bool) {
+	dataStore := store.FromContext(context)
+	record, err := dataStore.Servers.Get(serverId)
 
-	if userId == "" {
-		httputils.RespondWithNotFound(context, errors.New("GetServersByUserId: No user id provided."))
-		return
+	if errors.Is(err, sql.ErrNoRows) {
+		httputils.RespondWithNotFound(context, errors.New("Could not find server with id: "+serverId))
+		return nil, false
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return nil, false
 	}
 
-	servers, err := selectServerRecordsByUserId(userId)
+	server := newMCServer(record)
+	role, err := permissions.ResolveServerRole(dataStore.DB, token.GetAuthenticatedUserId(context), server.ID, server.UserID)
 
 	if err != nil {
 		httputils.RespondWithInternalServerError(context, err)
-		return
+		return nil, false
 	}
 
-	httputils.RespondWithStatusOk(context, servers)
+	if role == permissions.ServerRoleNone {
+		httputils.RespondWithNotFound(context, errors.New("Could not find server with id: "+serverId))
+		return nil, false
+	}
+
+	if !permissions.Can(role, action) {
+		httputils.RespondWithForbidden(context, fmt.Errorf("Role `%v` is not allowed to %v server `%v`", role, action, serverId))
+		return nil, false
+	}
+
+	if key := token.GetAPIKey(context); key != nil && !key.Permits(server.ID, action) {
+		httputils.RespondWithForbidden(context, fmt.Errorf("API key `%v` is not allowed to %v server `%v`", key.Prefix, action, serverId))
+		return nil, false
+	}
+
+	return server, true
 }
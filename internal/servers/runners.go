@@ -11,6 +11,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/magiconair/properties"
@@ -49,10 +50,30 @@ type VersionDownloads struct {
 	Server VersionDownload `json:"server"`
 }
 
+// LEGACY_JAVA_VERSION is the Java version of Minecraft versions whose details do not give one,
+// which are the versions before 1.17
+const LEGACY_JAVA_VERSION = 8
+
+// VersionJavaVersion is the Java runtime a version runs on
+type VersionJavaVersion struct {
+	Component    string `json:"component"`
+	MajorVersion int    `json:"majorVersion"`
+}
+
 // VersionDetail struct
 type VersionDetail struct {
-	Downloads VersionDownloads `json:"downloads"`
-	ID        string           `json:"id"`
+	Downloads   VersionDownloads   `json:"downloads"`
+	ID          string             `json:"id"`
+	JavaVersion VersionJavaVersion `json:"javaVersion"`
+}
+
+// RequiredJavaVersion returns the major Java version a version needs
+func (versionDetail *VersionDetail) RequiredJavaVersion() int {
+	if versionDetail.JavaVersion.MajorVersion == 0 {
+		return LEGACY_JAVA_VERSION
+	}
+
+	return versionDetail.JavaVersion.MajorVersion
 }
 
 // ServerProperties struct
@@ -61,7 +82,7 @@ type ServerProperties struct {
 	AllowNether                    bool   `alias:"allow-nether" json:"allow-nether" properties:"allow-nether,default=true"`                                                               // true
 	BroadcastConsoleToOps          bool   `alias:"broadcast-console-to-ops" json:"broadcast-console-to-ops" properties:"broadcast-console-to-ops,default=true"`                           // true
 	BroadcastRconToOps             bool   `alias:"broadcast-rcon-to-ops" json:"broadcast-rcon-to-ops" properties:"broadcast-rcon-to-ops,default=true"`                                    // true
-	ConPort                        uint16 `alias:"con.port" json:"con.port" properties:"con.port,default=25575"`                                                                          // 25575
+	RconPort                       uint16 `alias:"rcon.port" json:"rcon.port" properties:"rcon.port,default=25575"`                                                                       // 25575
 	Difficulty                     string `alias:"difficulty" json:"difficulty" properties:"difficulty,default=easy"`                                                                     // easy
 	EnableCommandBlock             bool   `alias:"enable-command-block" json:"enable-command-block" properties:"enable-command-block,default=false"`                                      // false
 	EnableJMXMonitoring            bool   `alias:"enable-jmx-monitoring" json:"enable-jmx-monitoring" properties:"enable-jmx-monitoring,default=false"`                                   // false
@@ -199,23 +220,22 @@ func DownloadFile(url string, filepath string) error {
 	return err
 }
 
-const DATA_PATH_PREFIX = "data/"
-
-// GetJarFilepath returns the standard location for downloaded server jarFiles
-func GetJarFilepath(jarFileName string) string {
-	return fmt.Sprintf("%vjarFiles/%v", DATA_PATH_PREFIX, jarFileName)
+// GetJarFilepath returns the standard location for downloaded server jarFiles in the data
+// directory
+func GetJarFilepath(dataPath string, jarFileName string) string {
+	return filepath.Join(dataPath, "jarFiles", jarFileName)
 }
 
-// GetServerFilePath gets the full path to a server from the worlds/
-// directory starting at the project root
-func GetServerFilepath(serverID string) string {
-	return fmt.Sprintf("%vworlds/%v", DATA_PATH_PREFIX, serverID)
+// GetServerFilePath gets the full path to a server from the worlds/ directory in the data
+// directory
+func GetServerFilepath(dataPath string, serverID string) string {
+	return filepath.Join(dataPath, "worlds", serverID)
 }
 
 // DownloadJarFileIfNeeded download a jarFile if the desired jarFile has not already been downloaded
-func DownloadJarFileIfNeeded(versionDetail VersionDetail) (string, error) {
+func DownloadJarFileIfNeeded(dataPath string, versionDetail VersionDetail) (string, error) {
 	jarFileName := fmt.Sprintf("%v.jar", versionDetail.ID)
-	jarFilePath := GetJarFilepath(jarFileName)
+	jarFilePath := GetJarFilepath(dataPath, jarFileName)
 
 	if _, err := os.Stat(jarFilePath); err == nil {
 		log.Printf("jarFile `%v` found. Skipping download.", jarFilePath)
@@ -315,30 +335,69 @@ func WriteServerProperties(worldpath string, serverProperties *properties.Proper
 	return nil
 }
 
-// UpdateServerProperties updates the server.properties file for a given server
-func UpdateServerProperties(customServerProperties map[string]interface{}, worldpath string) (*ServerProperties, error) {
+// PropertyChange describes a single server.properties key before and after an update.
+// A nil Old value means the key was not present in the file before the update, and a
+// nil New value means the key was removed.
+type PropertyChange struct {
+	Old *string `json:"old"`
+	New *string `json:"new"`
+}
+
+// PropertyChanges maps server.properties keys to the change applied to them
+type PropertyChanges map[string]PropertyChange
+
+// UpdateServerProperties updates the server.properties file for a given server. A nil value
+// removes the key from the file. The keys whose values actually changed are returned alongside
+// the updated properties.
+func UpdateServerProperties(customServerProperties map[string]interface{}, worldpath string) (*ServerProperties, PropertyChanges, error) {
 	currentServerProperties, err := GetServerProperties(worldpath)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	changes := PropertyChanges{}
+
 	for key, value := range customServerProperties {
-		if err := currentServerProperties.SetValue(key, value); err != nil {
-			return nil, err
+		change := PropertyChange{}
+
+		if oldValue, ok := currentServerProperties.Get(key); ok {
+			change.Old = &oldValue
+		}
+
+		if value == nil {
+			currentServerProperties.Delete(key)
+		} else if err := currentServerProperties.SetValue(key, value); err != nil {
+			return nil, nil, err
+		}
+
+		if newValue, ok := currentServerProperties.Get(key); ok {
+			change.New = &newValue
+		}
+
+		if !isSamePropertyValue(change.Old, change.New) {
+			changes[key] = change
 		}
 	}
 
 	if err := WriteServerProperties(worldpath, currentServerProperties); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	log.Printf("`%v` updated with new values: %v", GetServerPropertiesFilepath(worldpath), customServerProperties)
 
 	updatedServerProperties := ServerProperties{}
 	if err := currentServerProperties.Decode(&updatedServerProperties); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	return &updatedServerProperties, changes, nil
+}
+
+func isSamePropertyValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
 
-	return &updatedServerProperties, nil
+	return *a == *b
 }
@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
 	"github.com/gin-gonic/gin"
 	"github.com/magiconair/properties"
 	"gotest.tools/assert"
@@ -30,3 +34,40 @@ func TestGetDefaults(t *testing.T) {
 
 	assert.DeepEqual(t, expectedProperties, actual)
 }
+
+// Test the server routes with two users and assert that a user cannot tell another user's server
+// or servers exist, on the legacy and the v1 routes
+func TestServerRoutesHideOtherUsersServers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, dialect, err := store.Open(filepath.Join(t.TempDir(), "gomine.db"))
+	assert.NilError(t, err)
+	defer db.Close()
+	assert.NilError(t, store.Migrate(db, dialect))
+
+	dataStore := store.New(db, dialect)
+	assert.NilError(t, dataStore.Users.Insert(&store.User{ID: "u1", Username: "steve", Hash: "hash"}, &store.InsertUserOptions{}))
+	assert.NilError(t, dataStore.Users.Insert(&store.User{ID: "u2", Username: "alex", Hash: "hash"}, &store.InsertUserOptions{}))
+	assert.NilError(t, dataStore.Servers.Insert(&store.Server{ID: "s1", Name: "world", Runtime: "1.20.1", Path: t.TempDir(), PID: -1, UserID: "u1"}))
+
+	request := func(userId string, method string, path string, body string) int {
+		router := gin.New()
+		router.Use(store.Middleware(dataStore), func(c *gin.Context) {
+			c.Set(token.USER_ID_CONTEXT_KEY, userId)
+		})
+		router.GET("/api/mcsrv/", GetServersByUserId)
+		router.GET("/api/mcsrv/detail", GetServerDetails)
+		router.PUT("/api/mcsrv/properties", PutServerProperties)
+		router.GET("/api/v1/servers/:id/properties", GetProperties)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+		return recorder.Code
+	}
+
+	assert.Equal(t, request("u2", http.MethodGet, "/api/mcsrv/detail?s=s1", ""), http.StatusNotFound)
+	assert.Equal(t, request("u2", http.MethodPut, "/api/mcsrv/properties", `{"serverId": "s1", "serverProperties": {"motd": "mine"}}`), http.StatusNotFound)
+	assert.Equal(t, request("u2", http.MethodGet, "/api/v1/servers/s1/properties", ""), http.StatusNotFound)
+	assert.Equal(t, request("u2", http.MethodGet, "/api/mcsrv/?u=u1", ""), http.StatusNotFound)
+	assert.Equal(t, request("u1", http.MethodGet, "/api/mcsrv/?u=u1", ""), http.StatusOK)
+}
@@ -0,0 +1,250 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// ErrMigrationChanged is returned when a migration that was already applied no longer matches
+// its checksum
+var ErrMigrationChanged = errors.New("Migration was changed after it was applied")
+
+// MIGRATION_LOCK_KEY is the PostgreSQL advisory lock held while migrations are applied, so
+// instances starting at once do not apply the same migration twice
+const MIGRATION_LOCK_KEY = 7368027
+
+// Migration is a versioned change of the schema. Migrations are applied in order of their version,
+// which is the number their file name starts with. Each dialect has its own migrations, so their
+// versions do not line up.
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Checksum string
+}
+
+func parseMigration(name string, contents []byte) (*Migration, error) {
+	prefix, _, ok := strings.Cut(name, "_")
+	version, err := strconv.Atoi(prefix)
+
+	if !ok || err != nil {
+		return nil, fmt.Errorf("Migration `%v` must be named like `0001_description.sql`", name)
+	}
+
+	sum := sha256.Sum256(contents)
+
+	return &Migration{Version: version, Name: name, SQL: string(contents), Checksum: hex.EncodeToString(sum[:])}, nil
+}
+
+// LoadMigrations returns the migrations of a dialect embedded in gomine, ordered by version
+func LoadMigrations(dialect Dialect) ([]Migration, error) {
+	directory := "migrations/" + string(dialect) + "/"
+	names, err := fs.Glob(migrationFiles, directory+"*.sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []Migration{}
+
+	for _, name := range names {
+		contents, err := migrationFiles.ReadFile(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		migration, err := parseMigration(strings.TrimPrefix(name, directory), contents)
+
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("Migrations `%v` and `%v` have the same version", migrations[i-1].Name, migrations[i].Name)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Migrate brings the schema up to date with the embedded migrations of a dialect
+func Migrate(db *sql.DB, dialect Dialect) error {
+	migrations, err := LoadMigrations(dialect)
+
+	if err != nil {
+		return err
+	}
+
+	return applyMigrations(db, dialect, migrations)
+}
+
+// applyMigrations applies every migration that was not applied yet, each in its own transaction.
+// SQLite does not enforce foreign keys while a migration runs so tables can be rebuilt, and checks
+// them before it is committed instead.
+func applyMigrations(db *sql.DB, dialect Dialect, migrations []Migration) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	// The lock belongs to the connection rather than a transaction, so it is held from reading the
+	// applied migrations until the last one is committed
+	if dialect == DialectPostgres {
+		if _, err := conn.ExecContext(ctx, "select pg_advisory_lock(?)", MIGRATION_LOCK_KEY); err != nil {
+			return err
+		}
+
+		defer conn.ExecContext(ctx, "select pg_advisory_unlock(?)", MIGRATION_LOCK_KEY)
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY NOT NULL,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`)
+
+	if err != nil {
+		return err
+	}
+
+	applied := map[int]string{}
+	rows, err := conn.QueryContext(ctx, "select version, checksum from schema_migrations")
+
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var version int
+		var checksum string
+
+		if err := rows.Scan(&version, &checksum); err != nil {
+			rows.Close()
+			return err
+		}
+
+		applied[version] = checksum
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if dialect == DialectSQLite {
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys=off"); err != nil {
+			return err
+		}
+
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys=on")
+	}
+
+	for _, migration := range migrations {
+		if checksum, ok := applied[migration.Version]; ok {
+			if checksum != migration.Checksum {
+				return fmt.Errorf("%w: %v", ErrMigrationChanged, migration.Name)
+			}
+
+			continue
+		}
+
+		log.Printf("Applying migration `%v`...", migration.Name)
+
+		if err := applyMigration(ctx, conn, dialect, &migration); err != nil {
+			return fmt.Errorf("Could not apply migration `%v`: %w", migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// countForeignKeyViolations counts the rows whose foreign keys do not match. SQLite databases from
+// before foreign keys were enforced may already have some, while PostgreSQL always enforces them.
+func countForeignKeyViolations(transaction *sql.Tx, dialect Dialect) (int, error) {
+	if dialect == DialectPostgres {
+		return 0, nil
+	}
+
+	rows, err := transaction.Query("PRAGMA foreign_key_check")
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+	count := 0
+
+	for rows.Next() {
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, dialect Dialect, migration *Migration) error {
+	transaction, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	violations, err := countForeignKeyViolations(transaction, dialect)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := transaction.Exec(migration.SQL); err != nil {
+		return err
+	}
+
+	violationsAfter, err := countForeignKeyViolations(transaction, dialect)
+
+	if err != nil {
+		return err
+	}
+
+	if violationsAfter > violations {
+		return errors.New("Migration leaves rows that violate foreign keys")
+	}
+
+	_, err = transaction.Exec(
+		"insert into schema_migrations(version, name, checksum, applied_at) values(?, ?, ?, ?)",
+		migration.Version, migration.Name, migration.Checksum, time.Now().Unix(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
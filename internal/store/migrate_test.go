@@ -0,0 +1,69 @@
+package store
+
+import (
+	_ "embed"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// baselineSchema is the schema.sql gomine created its SQLite database with before migrations
+//
+//go:embed testdata/schema.sql
+var baselineSchema string
+
+// Test Migrate on a SQLite database created from the original schema.sql and assert that its rows
+// are kept and get the new columns, that migrating again does nothing and that changed migrations
+// are refused
+func TestMigrate(t *testing.T) {
+	db, dialect, err := Open(filepath.Join(t.TempDir(), "gomine.db"))
+	assert.NilError(t, err)
+	defer db.Close()
+
+	migrations, err := LoadMigrations(dialect)
+	assert.NilError(t, err)
+
+	_, err = db.Exec(baselineSchema)
+	assert.NilError(t, err)
+	_, err = db.Exec(`insert into users(id, username, hash) values('u1', 'steve', 'hash');
+		insert into users(id, username, hash) values('u2abcdef-0000', 'Steve', 'hash');
+		insert into servers(id, name, runtime, path, user_id) values('s1', 'world', '1.20.1', 'worlds/s1', 'u1')`)
+	assert.NilError(t, err)
+
+	assert.NilError(t, Migrate(db, dialect))
+	assert.NilError(t, Migrate(db, dialect))
+
+	var applied int
+	assert.NilError(t, db.QueryRow("select count(*) from schema_migrations").Scan(&applied))
+	assert.Equal(t, applied, len(migrations))
+
+	var userIdType string
+	assert.NilError(t, db.QueryRow("select type from pragma_table_info('servers') where name='user_id'").Scan(&userIdType))
+	assert.Equal(t, userIdType, "TEXT")
+
+	dataStore := New(db, dialect)
+	user, err := dataStore.Users.Get("u1")
+	assert.NilError(t, err)
+	assert.Equal(t, user.Username, "steve")
+	assert.Equal(t, user.Role, "user")
+	assert.Equal(t, user.Email, "")
+	assert.Assert(t, !user.Disabled && !user.PendingApproval)
+
+	// Usernames that only differed in case are made unique
+	user, err = dataStore.Users.Get("u2abcdef-0000")
+	assert.NilError(t, err)
+	assert.Equal(t, user.Username, "Steve-u2abcdef")
+
+	server, err := dataStore.Servers.Get("s1")
+	assert.NilError(t, err)
+	assert.Equal(t, server.UserID, "u1")
+	assert.Equal(t, server.PendingRestart, false)
+	assert.DeepEqual(t, server.Launch.JVMArgs, []string{})
+	assert.Assert(t, server.CreatedAt.Unix() > 0)
+
+	_, err = db.Exec("update schema_migrations set checksum='changed' where version=?", migrations[0].Version)
+	assert.NilError(t, err)
+	assert.Assert(t, errors.Is(Migrate(db, dialect), ErrMigrationChanged))
+}
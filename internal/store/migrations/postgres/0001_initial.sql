@@ -0,0 +1,161 @@
+-- PostgreSQL databases start from the schema SQLite databases were migrated to by 0016
+CREATE TABLE users (
+  id TEXT PRIMARY KEY NOT NULL,
+  username TEXT NOT NULL,
+  email TEXT DEFAULT '' NOT NULL,
+  hash TEXT NOT NULL,
+  role TEXT DEFAULT 'user' NOT NULL,
+  disabled BOOLEAN DEFAULT false NOT NULL,
+  pending_approval BOOLEAN DEFAULT false NOT NULL,
+  created_at BIGINT DEFAULT 0 NOT NULL,
+  updated_at BIGINT DEFAULT 0 NOT NULL
+);
+
+CREATE UNIQUE INDEX users_username ON users (lower(username));
+
+CREATE TABLE servers (
+  id TEXT PRIMARY KEY NOT NULL,
+  name TEXT NOT NULL,
+  runtime TEXT NOT NULL,
+  path TEXT NOT NULL,
+  pid INTEGER DEFAULT -1,
+  status BOOLEAN DEFAULT false NOT NULL,
+  pending_restart BOOLEAN DEFAULT false NOT NULL,
+  user_id TEXT NOT NULL,
+  created_at BIGINT DEFAULT 0 NOT NULL,
+  updated_at BIGINT DEFAULT 0 NOT NULL,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE INDEX servers_user_id ON servers (user_id);
+
+CREATE TABLE server_property_changes (
+  id TEXT PRIMARY KEY NOT NULL,
+  server_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  changes TEXT NOT NULL,
+  FOREIGN KEY (server_id)
+    REFERENCES servers (id)
+);
+
+CREATE TABLE server_grants (
+  server_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  role TEXT NOT NULL,
+  PRIMARY KEY (server_id, user_id),
+  FOREIGN KEY (server_id)
+    REFERENCES servers (id),
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE TABLE refresh_tokens (
+  id TEXT PRIMARY KEY NOT NULL,
+  family_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE,
+  created_at BIGINT NOT NULL,
+  expires_at BIGINT NOT NULL,
+  used_at BIGINT,
+  revoked_at BIGINT,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE INDEX refresh_tokens_family_id ON refresh_tokens (family_id);
+
+CREATE TABLE revoked_tokens (
+  jti TEXT PRIMARY KEY NOT NULL,
+  expires_at BIGINT NOT NULL
+);
+
+CREATE TABLE api_keys (
+  id TEXT PRIMARY KEY NOT NULL,
+  user_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  prefix TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE,
+  scopes TEXT NOT NULL,
+  server_ids TEXT NOT NULL,
+  created_at BIGINT NOT NULL,
+  last_used_at BIGINT,
+  revoked_at BIGINT,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE TABLE login_throttles (
+  key TEXT PRIMARY KEY NOT NULL,
+  failures INTEGER NOT NULL,
+  last_failure_at BIGINT NOT NULL,
+  locked_until BIGINT NOT NULL
+);
+
+CREATE TABLE login_audit (
+  id TEXT PRIMARY KEY NOT NULL,
+  username TEXT NOT NULL,
+  user_id TEXT,
+  ip TEXT NOT NULL,
+  outcome TEXT NOT NULL,
+  created_at BIGINT NOT NULL
+);
+
+CREATE TABLE invites (
+  id TEXT PRIMARY KEY NOT NULL,
+  created_by TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE,
+  max_uses INTEGER DEFAULT 1 NOT NULL,
+  uses INTEGER DEFAULT 0 NOT NULL,
+  created_at BIGINT NOT NULL,
+  expires_at BIGINT,
+  revoked_at BIGINT
+);
+
+CREATE TABLE user_mfa (
+  user_id TEXT PRIMARY KEY NOT NULL,
+  secret TEXT NOT NULL,
+  enabled BOOLEAN DEFAULT false NOT NULL,
+  last_used_step BIGINT DEFAULT 0 NOT NULL,
+  created_at BIGINT NOT NULL,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE TABLE mfa_recovery_codes (
+  id TEXT PRIMARY KEY NOT NULL,
+  user_id TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE,
+  used_at BIGINT,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE TABLE password_resets (
+  id TEXT PRIMARY KEY NOT NULL,
+  user_id TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE,
+  created_at BIGINT NOT NULL,
+  expires_at BIGINT NOT NULL,
+  used_at BIGINT,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE TABLE user_identities (
+  issuer TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  created_at BIGINT NOT NULL,
+  PRIMARY KEY (issuer, subject),
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE TABLE oidc_login_states (
+  state TEXT PRIMARY KEY NOT NULL,
+  nonce TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  expires_at BIGINT NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Server listings are paginated in order of creation or name, with the id breaking ties
+CREATE INDEX servers_created_at ON servers (created_at, id);
+CREATE INDEX servers_name ON servers (name, id);
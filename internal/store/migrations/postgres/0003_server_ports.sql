@@ -0,0 +1,7 @@
+-- Ports are assigned before the server they are for is inserted, so server_id is not a foreign key
+CREATE TABLE server_ports (
+  port INTEGER PRIMARY KEY NOT NULL,
+  server_id TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  UNIQUE (server_id, kind)
+);
@@ -0,0 +1,8 @@
+-- Servers that existed before launch settings were stored keep running on the JVM defaults, and
+-- a java_version of 0 means the Minecraft version's Java requirement is not known
+ALTER TABLE servers ADD COLUMN min_heap_mb INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE servers ADD COLUMN max_heap_mb INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE servers ADD COLUMN jvm_args TEXT DEFAULT '[]' NOT NULL;
+ALTER TABLE servers ADD COLUMN server_args TEXT DEFAULT '[]' NOT NULL;
+ALTER TABLE servers ADD COLUMN java_home TEXT DEFAULT '' NOT NULL;
+ALTER TABLE servers ADD COLUMN java_version INTEGER DEFAULT 0 NOT NULL;
@@ -0,0 +1,6 @@
+-- Resource limits of 0 are not enforced
+ALTER TABLE servers ADD COLUMN cpu_weight INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE servers ADD COLUMN cpu_quota_percent INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE servers ADD COLUMN memory_max_mb INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE servers ADD COLUMN pids_max INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE servers ADD COLUMN io_weight INTEGER DEFAULT 0 NOT NULL;
@@ -0,0 +1,4 @@
+-- Property changes are kept as an audit trail after their server is deleted, so server_id is no
+-- longer a foreign key
+ALTER TABLE server_property_changes DROP CONSTRAINT server_property_changes_server_id_fkey;
+CREATE INDEX server_property_changes_server_id ON server_property_changes (server_id);
@@ -0,0 +1,3 @@
+-- Only addresses gomine has verified are trusted to link accounts, which addresses set before
+-- verification existed are not
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN DEFAULT false NOT NULL;
@@ -0,0 +1,10 @@
+-- Changes of server.properties are recorded so they can be listed and reverted
+CREATE TABLE IF NOT EXISTS server_property_changes (
+  id TEXT PRIMARY KEY NOT NULL,
+  server_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  created_at DATETIME NOT NULL,
+  changes TEXT NOT NULL,
+  FOREIGN KEY (server_id)
+    REFERENCES servers (id)
+);
@@ -0,0 +1,2 @@
+-- Servers are flagged when a change only takes effect once they restart
+ALTER TABLE servers ADD COLUMN pending_restart BOOLEAN DEFAULT false NOT NULL;
@@ -0,0 +1,13 @@
+-- Existing users keep the user role
+ALTER TABLE users ADD COLUMN role TEXT DEFAULT 'user' NOT NULL;
+
+CREATE TABLE IF NOT EXISTS server_grants (
+  server_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  role TEXT NOT NULL,
+  PRIMARY KEY (server_id, user_id),
+  FOREIGN KEY (server_id)
+    REFERENCES servers (id),
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
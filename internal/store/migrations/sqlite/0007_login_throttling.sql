@@ -0,0 +1,16 @@
+-- Login attempts are audited by username, so user_id is empty for unknown usernames
+CREATE TABLE IF NOT EXISTS login_throttles (
+  key TEXT PRIMARY KEY NOT NULL,
+  failures INTEGER NOT NULL,
+  last_failure_at INTEGER NOT NULL,
+  locked_until INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS login_audit (
+  id TEXT PRIMARY KEY NOT NULL,
+  username TEXT NOT NULL,
+  user_id TEXT,
+  ip TEXT NOT NULL,
+  outcome TEXT NOT NULL,
+  created_at INTEGER NOT NULL
+);
@@ -0,0 +1,6 @@
+-- Usernames were not unique before, so every user but the first one of a username that differs
+-- only in case is renamed with the start of their id
+UPDATE users SET username = username || '-' || substr(id, 1, 8)
+  WHERE rowid NOT IN (SELECT min(rowid) FROM users GROUP BY username COLLATE NOCASE);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_username ON users (username COLLATE NOCASE);
@@ -0,0 +1,13 @@
+-- Users that registered before approvals were required are approved already
+ALTER TABLE users ADD COLUMN pending_approval BOOLEAN DEFAULT false NOT NULL;
+
+CREATE TABLE IF NOT EXISTS invites (
+  id TEXT PRIMARY KEY NOT NULL,
+  created_by TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE,
+  max_uses INTEGER DEFAULT 1 NOT NULL,
+  uses INTEGER DEFAULT 0 NOT NULL,
+  created_at INTEGER NOT NULL,
+  expires_at INTEGER,
+  revoked_at INTEGER
+);
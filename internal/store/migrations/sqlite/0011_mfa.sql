@@ -0,0 +1,19 @@
+-- Two-factor authentication is only required once a user enables it
+CREATE TABLE IF NOT EXISTS user_mfa (
+  user_id TEXT PRIMARY KEY NOT NULL,
+  secret TEXT NOT NULL,
+  enabled BOOLEAN DEFAULT false NOT NULL,
+  last_used_step INTEGER DEFAULT 0 NOT NULL,
+  created_at INTEGER NOT NULL,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+  id TEXT PRIMARY KEY NOT NULL,
+  user_id TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE,
+  used_at INTEGER,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
@@ -0,0 +1,13 @@
+-- Users without an email cannot reset their password until they set one
+ALTER TABLE users ADD COLUMN email TEXT DEFAULT '' NOT NULL;
+
+CREATE TABLE IF NOT EXISTS password_resets (
+  id TEXT PRIMARY KEY NOT NULL,
+  user_id TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE,
+  created_at INTEGER NOT NULL,
+  expires_at INTEGER NOT NULL,
+  used_at INTEGER,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
@@ -0,0 +1,17 @@
+-- Identities link the subject of an OIDC issuer to a user
+CREATE TABLE IF NOT EXISTS user_identities (
+  issuer TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  created_at INTEGER NOT NULL,
+  PRIMARY KEY (issuer, subject),
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+  state TEXT PRIMARY KEY NOT NULL,
+  nonce TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  expires_at INTEGER NOT NULL
+);
@@ -0,0 +1,20 @@
+-- servers.user_id was declared INTEGER although it references the TEXT users.id
+CREATE TABLE servers_new (
+  id TEXT PRIMARY KEY NOT NULL,
+  name TEXT NOT NULL,
+  runtime TEXT NOT NULL,
+  path TEXT NOT NULL,
+  pid INTEGER DEFAULT -1,
+  status BOOLEAN DEFAULT false NOT NULL,
+  pending_restart BOOLEAN DEFAULT false NOT NULL,
+  user_id TEXT NOT NULL,
+  FOREIGN KEY (user_id)
+    REFERENCES users (id)
+);
+
+INSERT INTO servers_new (id, name, runtime, path, pid, status, pending_restart, user_id)
+  SELECT id, name, runtime, path, pid, status, pending_restart, CAST(user_id AS TEXT) FROM servers;
+
+DROP TABLE servers;
+
+ALTER TABLE servers_new RENAME TO servers;
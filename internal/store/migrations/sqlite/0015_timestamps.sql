@@ -0,0 +1,8 @@
+-- Rows that existed before timestamps were recorded are dated to the migration
+ALTER TABLE users ADD COLUMN created_at INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE users ADD COLUMN updated_at INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE servers ADD COLUMN created_at INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE servers ADD COLUMN updated_at INTEGER DEFAULT 0 NOT NULL;
+
+UPDATE users SET created_at = strftime('%s', 'now'), updated_at = strftime('%s', 'now');
+UPDATE servers SET created_at = strftime('%s', 'now'), updated_at = strftime('%s', 'now');
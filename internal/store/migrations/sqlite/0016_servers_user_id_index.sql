@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS servers_user_id ON servers (user_id);
@@ -0,0 +1,17 @@
+-- Property changes are kept as an audit trail after their server is deleted, so server_id is no
+-- longer a foreign key
+CREATE TABLE server_property_changes_new (
+  id TEXT PRIMARY KEY NOT NULL,
+  server_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  created_at DATETIME NOT NULL,
+  changes TEXT NOT NULL
+);
+
+INSERT INTO server_property_changes_new (id, server_id, user_id, created_at, changes)
+  SELECT id, server_id, user_id, created_at, changes FROM server_property_changes;
+
+DROP TABLE server_property_changes;
+
+ALTER TABLE server_property_changes_new RENAME TO server_property_changes;
+CREATE INDEX IF NOT EXISTS server_property_changes_server_id ON server_property_changes (server_id);
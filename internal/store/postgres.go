@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// POSTGRES_DRIVER_NAME is the database/sql driver gomine talks to PostgreSQL with. It is the pq
+// driver, except that queries are written with ? placeholders like they are for SQLite.
+const POSTGRES_DRIVER_NAME = "gomine-postgres"
+
+func init() {
+	sql.Register(POSTGRES_DRIVER_NAME, &postgresDriver{})
+}
+
+// rebind numbers the ? placeholders of a query the way PostgreSQL expects them. Question marks
+// inside quoted strings and identifiers are left alone.
+func rebind(query string) string {
+	var builder strings.Builder
+	var quote rune
+	placeholders := 0
+
+	for _, character := range query {
+		switch {
+		case quote != 0:
+			if character == quote {
+				quote = 0
+			}
+		case character == '\'' || character == '"':
+			quote = character
+		case character == '?':
+			placeholders++
+			builder.WriteString("$" + strconv.Itoa(placeholders))
+			continue
+		}
+
+		builder.WriteRune(character)
+	}
+
+	return builder.String()
+}
+
+type postgresDriver struct {
+	pq.Driver
+}
+
+func (postgresDriver *postgresDriver) Open(name string) (driver.Conn, error) {
+	conn, err := postgresDriver.Driver.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &postgresConn{conn: conn}, nil
+}
+
+// postgresConn rebinds the queries of a pq connection and passes everything else through
+type postgresConn struct {
+	conn driver.Conn
+}
+
+func (postgresConn *postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return postgresConn.conn.Prepare(rebind(query))
+}
+
+func (postgresConn *postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return postgresConn.conn.(driver.ConnPrepareContext).PrepareContext(ctx, rebind(query))
+}
+
+func (postgresConn *postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return postgresConn.conn.(driver.ExecerContext).ExecContext(ctx, rebind(query), args)
+}
+
+func (postgresConn *postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return postgresConn.conn.(driver.QueryerContext).QueryContext(ctx, rebind(query), args)
+}
+
+func (postgresConn *postgresConn) Begin() (driver.Tx, error) {
+	return postgresConn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (postgresConn *postgresConn) BeginTx(ctx context.Context, options driver.TxOptions) (driver.Tx, error) {
+	return postgresConn.conn.(driver.ConnBeginTx).BeginTx(ctx, options)
+}
+
+func (postgresConn *postgresConn) Ping(ctx context.Context) error {
+	return postgresConn.conn.(driver.Pinger).Ping(ctx)
+}
+
+func (postgresConn *postgresConn) ResetSession(ctx context.Context) error {
+	return postgresConn.conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (postgresConn *postgresConn) IsValid() bool {
+	return postgresConn.conn.(driver.Validator).IsValid()
+}
+
+func (postgresConn *postgresConn) Close() error {
+	return postgresConn.conn.Close()
+}
+
+// isPostgresUniqueViolation reports whether a PostgreSQL statement failed on a unique constraint
+func isPostgresUniqueViolation(err error) bool {
+	var postgresErr *pq.Error
+
+	return errors.As(err, &postgresErr) && postgresErr.Code == "23505"
+}
@@ -0,0 +1,15 @@
+package store
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Test rebind and assert that placeholders are numbered while quoted question marks are kept
+func TestRebind(t *testing.T) {
+	assert.Equal(t,
+		rebind(`select id from users where username=? and email<>'?' and "role?"=? limit ?`),
+		`select id from users where username=$1 and email<>'?' and "role?"=$2 limit $3`,
+	)
+}
@@ -0,0 +1,230 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// forEachDialect runs a test against a migrated SQLite database and, when GOMINE_TEST_DATABASE_URL
+// points to a PostgreSQL database, against a migrated schema created in it for the test
+func forEachDialect(t *testing.T, test func(t *testing.T, dataStore *Store)) {
+	t.Run(string(DialectSQLite), func(t *testing.T) {
+		db, dialect, err := Open(filepath.Join(t.TempDir(), "gomine.db"))
+		assert.NilError(t, err)
+		defer db.Close()
+
+		assert.NilError(t, Migrate(db, dialect))
+		test(t, New(db, dialect))
+	})
+
+	t.Run(string(DialectPostgres), func(t *testing.T) {
+		databaseUrl := os.Getenv("GOMINE_TEST_DATABASE_URL")
+
+		if databaseUrl == "" {
+			t.Skip("GOMINE_TEST_DATABASE_URL is not set")
+		}
+
+		admin, _, err := Open(databaseUrl)
+		assert.NilError(t, err)
+		defer admin.Close()
+
+		schema := fmt.Sprintf("gomine_test_%v", time.Now().UnixNano())
+		_, err = admin.Exec("create schema " + schema)
+		assert.NilError(t, err)
+		defer admin.Exec("drop schema " + schema + " cascade")
+
+		separator := "?"
+
+		if strings.Contains(databaseUrl, "?") {
+			separator = "&"
+		}
+
+		db, dialect, err := Open(databaseUrl + separator + "search_path=" + schema)
+		assert.NilError(t, err)
+		defer db.Close()
+
+		assert.NilError(t, Migrate(db, dialect))
+		test(t, New(db, dialect))
+	})
+}
+
+// Test the user repository and assert that only a bootstrapped user becomes admin, only while there
+// is no admin, that usernames and verified emails are matched ignoring case and that unverified
+// emails are not matched
+func TestUserRepository(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dataStore *Store) {
+		users := dataStore.Users
+		steve := &User{ID: "u1", Username: "Steve", Email: "steve@example.com", Hash: "hash"}
+		alex := &User{ID: "u2", Username: "alex", Email: "shared@example.com", EmailVerified: true, Hash: "hash"}
+		sam := &User{ID: "u3", Username: "sam", Email: "shared@example.com", EmailVerified: true, Hash: "hash"}
+
+		assert.NilError(t, users.Insert(alex, &InsertUserOptions{RequireApproval: true}))
+		assert.NilError(t, users.Insert(steve, &InsertUserOptions{RequireApproval: true, BootstrapAdmin: true}))
+		assert.NilError(t, users.Insert(sam, &InsertUserOptions{BootstrapAdmin: true}))
+		assert.Equal(t, alex.Role, "user")
+		assert.Equal(t, alex.PendingApproval, true)
+		assert.Equal(t, steve.Role, "admin")
+		assert.Equal(t, steve.PendingApproval, false)
+		assert.Equal(t, sam.Role, "user")
+
+		err := users.Insert(&User{ID: "u4", Username: "STEVE", Hash: "hash"}, &InsertUserOptions{})
+		assert.Assert(t, errors.Is(err, ErrUsernameTaken))
+
+		user, err := users.GetByUsername("steve")
+		assert.NilError(t, err)
+		assert.Equal(t, user.ID, steve.ID)
+		assert.Equal(t, user.CreatedAt, steve.CreatedAt)
+
+		_, err = users.GetByVerifiedEmail("steve@example.com")
+		assert.Assert(t, errors.Is(err, sql.ErrNoRows))
+
+		steve.EmailVerified = true
+		assert.NilError(t, users.Update(steve))
+
+		user, err = users.GetByVerifiedEmail("STEVE@example.com")
+		assert.NilError(t, err)
+		assert.Equal(t, user.ID, steve.ID)
+
+		_, err = users.GetByVerifiedEmail("shared@example.com")
+		assert.Assert(t, errors.Is(err, sql.ErrNoRows))
+
+		sam.Username = "Alex"
+		assert.Assert(t, errors.Is(users.Update(sam), ErrUsernameTaken))
+
+		alex.Role = "admin"
+		alex.PendingApproval = false
+		assert.NilError(t, users.Update(alex))
+
+		count, err := users.CountOtherActiveAdmins(steve.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 1)
+
+		assert.NilError(t, users.Delete(alex.ID))
+
+		_, err = users.Get(alex.ID)
+		assert.Assert(t, errors.Is(err, sql.ErrNoRows))
+
+		list, err := users.List()
+		assert.NilError(t, err)
+		assert.Equal(t, len(list), 2)
+		assert.Equal(t, list[0].Username, "Steve")
+		assert.Equal(t, list[1].Username, "sam")
+	})
+}
+
+// Test the server repository and assert that starting a server clears its pending restart, that
+// launch settings round trip, that transferred servers change owner and that deleting a server keeps
+// its property history
+func TestServerRepository(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dataStore *Store) {
+		steve := &User{ID: "u1", Username: "steve", Hash: "hash"}
+		alex := &User{ID: "u2", Username: "alex", Hash: "hash"}
+		assert.NilError(t, dataStore.Users.Insert(steve, &InsertUserOptions{}))
+		assert.NilError(t, dataStore.Users.Insert(alex, &InsertUserOptions{}))
+
+		servers := dataStore.Servers
+		assert.NilError(t, servers.Insert(&Server{ID: "s1", Name: "world", Runtime: "1.20.1", Path: "worlds/s1", PID: -1, UserID: steve.ID}))
+		assert.NilError(t, servers.SetPendingRestart("s1", true))
+		assert.NilError(t, servers.SetProcess("s1", 42, true))
+
+		server, err := servers.Get("s1")
+		assert.NilError(t, err)
+		assert.Equal(t, server.PID, 42)
+		assert.Equal(t, server.Status, true)
+		assert.Equal(t, server.PendingRestart, false)
+		assert.Equal(t, len(server.Launch.JVMArgs), 0)
+
+		launch := &Launch{MinHeapMB: 512, MaxHeapMB: 2048, JVMArgs: []string{"-XX:+UseG1GC"}, ServerArgs: []string{"--nojline"}, JavaHome: "/usr/lib/jvm/java-17", MemoryMaxMB: 3072, PidsMax: 512}
+		assert.NilError(t, servers.SetLaunch("s1", launch))
+
+		server, err = servers.Get("s1")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, server.Launch, *launch)
+
+		assert.NilError(t, servers.Transfer(steve.ID, alex.ID))
+
+		owned, err := servers.ListByUserId(alex.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(owned), 1)
+		assert.Equal(t, owned[0].ID, "s1")
+
+		_, err = dataStore.DB.Exec("insert into server_property_changes(id, server_id, user_id, created_at, changes) values(?, ?, ?, ?, ?)", "c1", "s1", alex.ID, time.Now(), "{}")
+		assert.NilError(t, err)
+		assert.NilError(t, servers.Delete("s1"))
+
+		_, err = servers.Get("s1")
+		assert.Assert(t, errors.Is(err, sql.ErrNoRows))
+
+		var changes int
+		assert.NilError(t, dataStore.DB.QueryRow("select count(*) from server_property_changes where server_id=?", "s1").Scan(&changes))
+		assert.Equal(t, changes, 1)
+	})
+}
+
+// Test querying the server repository and assert that filters apply and that following the cursors
+// lists every matching server once, in order
+func TestServerRepositoryQuery(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, dataStore *Store) {
+		steve := &User{ID: "u1", Username: "steve", Hash: "hash"}
+		alex := &User{ID: "u2", Username: "alex", Hash: "hash"}
+		assert.NilError(t, dataStore.Users.Insert(steve, &InsertUserOptions{}))
+		assert.NilError(t, dataStore.Users.Insert(alex, &InsertUserOptions{}))
+
+		servers := dataStore.Servers
+
+		for _, server := range []Server{
+			{ID: "s1", Name: "Creative", Runtime: "1.20.1", UserID: steve.ID},
+			{ID: "s2", Name: "survival", Runtime: "1.20.1", UserID: steve.ID, Status: true},
+			{ID: "s3", Name: "Hardcore 100%", Runtime: "1.19.4", UserID: steve.ID},
+			{ID: "s4", Name: "Survival Island", Runtime: "1.20.1", UserID: alex.ID},
+		} {
+			server.Path = "worlds/" + server.ID
+			assert.NilError(t, servers.Insert(&server))
+		}
+
+		ids := func(query *ServerQuery) []string {
+			found := []string{}
+
+			for {
+				page, err := servers.Query(query)
+				assert.NilError(t, err)
+
+				for _, server := range page.Servers {
+					found = append(found, server.ID)
+				}
+
+				if page.NextCursor == "" {
+					return found
+				}
+
+				query.Cursor = page.NextCursor
+			}
+		}
+
+		running := true
+
+		assert.DeepEqual(t, ids(&ServerQuery{Limit: 1}), []string{"s1", "s2", "s3", "s4"})
+		assert.DeepEqual(t, ids(&ServerQuery{UserID: steve.ID, Sort: ServerSortName, Descending: true, Limit: 2}), []string{"s2", "s3", "s1"})
+		assert.DeepEqual(t, ids(&ServerQuery{Name: "SURVIVAL", Limit: 10}), []string{"s2", "s4"})
+		assert.DeepEqual(t, ids(&ServerQuery{Name: "%", Limit: 10}), []string{"s3"})
+		assert.DeepEqual(t, ids(&ServerQuery{Runtime: "1.20.1", Status: &running, Limit: 10}), []string{"s2"})
+		assert.DeepEqual(t, ids(&ServerQuery{IDs: []string{"s1", "s4"}, Limit: 10}), []string{"s1", "s4"})
+
+		page, err := servers.Query(&ServerQuery{Limit: 1})
+		assert.NilError(t, err)
+
+		_, err = servers.Query(&ServerQuery{Sort: ServerSortName, Cursor: page.NextCursor, Limit: 1})
+		assert.Assert(t, errors.Is(err, ErrInvalidCursor))
+
+		_, err = servers.Query(&ServerQuery{Cursor: "garbage", Limit: 1})
+		assert.Assert(t, errors.Is(err, ErrInvalidCursor))
+	})
+}
@@ -0,0 +1,450 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a cursor was not issued for the sort of the query it is used with
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// Server is a row of the servers table
+type Server struct {
+	ID             string
+	Name           string
+	Runtime        string
+	Path           string
+	PID            int
+	Status         bool
+	PendingRestart bool
+	UserID         string
+	Launch         Launch
+	// JavaVersion is the major Java version the server's Minecraft version needs, or 0 when unknown
+	JavaVersion int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Launch is how the JVM of a server is started. Heap sizes of 0 are left to the JVM, an empty
+// JavaHome selects an installation that meets the server's Java version and resource limits of 0
+// are not enforced.
+type Launch struct {
+	MinHeapMB       int
+	MaxHeapMB       int
+	JVMArgs         []string
+	ServerArgs      []string
+	JavaHome        string
+	CPUWeight       int
+	CPUQuotaPercent int
+	MemoryMaxMB     int
+	PidsMax         int
+	IOWeight        int
+}
+
+// ServerRepository stores servers. Lookups of servers that do not exist fail with sql.ErrNoRows.
+type ServerRepository interface {
+	Insert(server *Server) error
+	Get(id string) (*Server, error)
+	// ListByUserId returns the servers owned by a user
+	ListByUserId(userId string) ([]Server, error)
+	// Query returns a page of the servers matching a query
+	Query(query *ServerQuery) (*ServerPage, error)
+	SetPendingRestart(id string, pendingRestart bool) error
+	// SetLaunch changes how a server is started, which takes effect the next time it starts
+	SetLaunch(id string, launch *Launch) error
+	// SetProcess records the process of a server. Starting a server picks up every pending
+	// server.properties change.
+	SetProcess(id string, pid int, status bool) error
+	// Transfer hands every server of a user over to another user
+	Transfer(fromUserId string, toUserId string) error
+	// Delete removes a server along with its grants and ports, keeping its property history
+	Delete(id string) error
+}
+
+// ServerSort is the order servers are listed in. Servers sorted the same are ordered by id.
+type ServerSort string
+
+const (
+	// ServerSortCreatedAt lists the oldest servers first
+	ServerSortCreatedAt ServerSort = "createdAt"
+	// ServerSortName lists servers in alphabetical order
+	ServerSortName ServerSort = "name"
+)
+
+// serverSortColumns maps sorts to the column servers are ordered by
+var serverSortColumns = map[ServerSort]string{
+	ServerSortCreatedAt: "created_at",
+	ServerSortName:      "name",
+}
+
+// IsValidServerSort reports whether a sort is known
+func IsValidServerSort(sort ServerSort) bool {
+	_, ok := serverSortColumns[sort]
+
+	return ok
+}
+
+// ServerQuery selects and orders a page of servers. Filters that are left empty match every server.
+type ServerQuery struct {
+	// UserID matches the servers owned by a user
+	UserID string
+	// IDs matches the servers with one of the ids
+	IDs []string
+	// Status matches running or stopped servers
+	Status *bool
+	// Runtime matches the servers running a Minecraft version
+	Runtime string
+	// Name matches the servers whose name contains it, ignoring case
+	Name       string
+	Sort       ServerSort
+	Descending bool
+	// Cursor is the NextCursor of the previous page, or empty for the first page
+	Cursor string
+	Limit  int
+}
+
+// ServerPage is a page of servers. NextCursor is empty on the last page.
+type ServerPage struct {
+	Servers    []Server
+	NextCursor string
+}
+
+// serverCursor is the position of the last server of a page, encoded into an opaque string
+type serverCursor struct {
+	Sort       ServerSort `json:"s"`
+	Descending bool       `json:"d,omitempty"`
+	Value      string     `json:"v"`
+	ID         string     `json:"i"`
+}
+
+func (cursor *serverCursor) encode() string {
+	encoded, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeServerCursor(encoded string) (*serverCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor serverCursor
+
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// sortValue returns the value of the column a server is sorted by, as it is stored in a cursor
+func (server *Server) sortValue(sort ServerSort) string {
+	if sort == ServerSortName {
+		return server.Name
+	}
+
+	return strconv.FormatInt(server.CreatedAt.Unix(), 10)
+}
+
+const serverColumns = "id, name, runtime, path, pid, status, pending_restart, user_id, min_heap_mb, max_heap_mb, jvm_args, server_args, java_home, java_version, cpu_weight, cpu_quota_percent, memory_max_mb, pids_max, io_weight, created_at, updated_at"
+
+type sqlServerRepository struct {
+	db *sql.DB
+}
+
+func scanServer(row rowScanner) (*Server, error) {
+	server := Server{}
+	var jvmArgs, serverArgs string
+	var createdAt, updatedAt int64
+	err := row.Scan(
+		&server.ID, &server.Name, &server.Runtime, &server.Path, &server.PID, &server.Status, &server.PendingRestart, &server.UserID,
+		&server.Launch.MinHeapMB, &server.Launch.MaxHeapMB, &jvmArgs, &serverArgs, &server.Launch.JavaHome, &server.JavaVersion,
+		&server.Launch.CPUWeight, &server.Launch.CPUQuotaPercent, &server.Launch.MemoryMaxMB, &server.Launch.PidsMax, &server.Launch.IOWeight,
+		&createdAt, &updatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(jvmArgs), &server.Launch.JVMArgs); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(serverArgs), &server.Launch.ServerArgs); err != nil {
+		return nil, err
+	}
+
+	server.CreatedAt = time.Unix(createdAt, 0)
+	server.UpdatedAt = time.Unix(updatedAt, 0)
+
+	return &server, nil
+}
+
+func (repository *sqlServerRepository) Insert(server *Server) error {
+	server.CreatedAt = time.Unix(time.Now().Unix(), 0)
+	server.UpdatedAt = server.CreatedAt
+	jvmArgs, serverArgs, err := encodeArgs(&server.Launch)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = repository.db.Exec(
+		"insert into servers("+serverColumns+") values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		server.ID, server.Name, server.Runtime, server.Path, server.PID, server.Status, server.PendingRestart, server.UserID,
+		server.Launch.MinHeapMB, server.Launch.MaxHeapMB, jvmArgs, serverArgs, server.Launch.JavaHome, server.JavaVersion,
+		server.Launch.CPUWeight, server.Launch.CPUQuotaPercent, server.Launch.MemoryMaxMB, server.Launch.PidsMax, server.Launch.IOWeight,
+		server.CreatedAt.Unix(), server.UpdatedAt.Unix(),
+	)
+
+	return err
+}
+
+// encodeArgs returns the JVM and server args of a launch as they are stored. Missing args are
+// stored as empty lists.
+func encodeArgs(launch *Launch) (string, string, error) {
+	jvmArgs, serverArgs := launch.JVMArgs, launch.ServerArgs
+
+	if jvmArgs == nil {
+		jvmArgs = []string{}
+	}
+
+	if serverArgs == nil {
+		serverArgs = []string{}
+	}
+
+	encodedJvmArgs, err := json.Marshal(jvmArgs)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	encodedServerArgs, err := json.Marshal(serverArgs)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(encodedJvmArgs), string(encodedServerArgs), nil
+}
+
+func (repository *sqlServerRepository) Get(id string) (*Server, error) {
+	return scanServer(repository.db.QueryRow("select "+serverColumns+" from servers where id=?", id))
+}
+
+func (repository *sqlServerRepository) ListByUserId(userId string) ([]Server, error) {
+	return repository.query("select "+serverColumns+" from servers where user_id=?", userId)
+}
+
+func (repository *sqlServerRepository) query(query string, args ...any) ([]Server, error) {
+	rows, err := repository.db.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	servers := []Server{}
+
+	for rows.Next() {
+		server, err := scanServer(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		servers = append(servers, *server)
+	}
+
+	return servers, rows.Err()
+}
+
+func (repository *sqlServerRepository) Query(query *ServerQuery) (*ServerPage, error) {
+	if query.Limit < 1 {
+		return nil, errors.New("Server queries must be limited to at least one server")
+	}
+
+	sort := query.Sort
+
+	if sort == "" {
+		sort = ServerSortCreatedAt
+	}
+
+	column, ok := serverSortColumns[sort]
+
+	if !ok {
+		return nil, errors.New("Unknown server sort: " + string(sort))
+	}
+
+	conditions := []string{}
+	args := []any{}
+
+	if query.UserID != "" {
+		conditions = append(conditions, "user_id=?")
+		args = append(args, query.UserID)
+	}
+
+	if len(query.IDs) > 0 {
+		conditions = append(conditions, "id in (?"+strings.Repeat(", ?", len(query.IDs)-1)+")")
+
+		for _, id := range query.IDs {
+			args = append(args, id)
+		}
+	}
+
+	if query.Status != nil {
+		conditions = append(conditions, "status=?")
+		args = append(args, *query.Status)
+	}
+
+	if query.Runtime != "" {
+		conditions = append(conditions, "runtime=?")
+		args = append(args, query.Runtime)
+	}
+
+	if query.Name != "" {
+		escaped := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(strings.ToLower(query.Name))
+		conditions = append(conditions, "lower(name) like ? escape '\\'")
+		args = append(args, "%"+escaped+"%")
+	}
+
+	comparison, direction := ">", "asc"
+
+	if query.Descending {
+		comparison, direction = "<", "desc"
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeServerCursor(query.Cursor)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if cursor.Sort != sort || cursor.Descending != query.Descending {
+			return nil, ErrInvalidCursor
+		}
+
+		var value any = cursor.Value
+
+		if sort == ServerSortCreatedAt {
+			if value, err = strconv.ParseInt(cursor.Value, 10, 64); err != nil {
+				return nil, ErrInvalidCursor
+			}
+		}
+
+		conditions = append(conditions, "("+column+comparison+"? or ("+column+"=? and id"+comparison+"?))")
+		args = append(args, value, value, cursor.ID)
+	}
+
+	statement := "select " + serverColumns + " from servers"
+
+	if len(conditions) > 0 {
+		statement += " where " + strings.Join(conditions, " and ")
+	}
+
+	// One more server than asked for tells whether there is a next page
+	statement += " order by " + column + " " + direction + ", id " + direction + " limit ?"
+	args = append(args, query.Limit+1)
+	servers, err := repository.query(statement, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ServerPage{Servers: servers}
+
+	if len(servers) > query.Limit {
+		page.Servers = servers[:query.Limit]
+		last := page.Servers[query.Limit-1]
+		page.NextCursor = (&serverCursor{Sort: sort, Descending: query.Descending, Value: last.sortValue(sort), ID: last.ID}).encode()
+	}
+
+	return page, nil
+}
+
+func (repository *sqlServerRepository) SetPendingRestart(id string, pendingRestart bool) error {
+	_, err := repository.db.Exec("update servers set pending_restart=?, updated_at=? where id=?", pendingRestart, time.Now().Unix(), id)
+
+	return err
+}
+
+func (repository *sqlServerRepository) SetLaunch(id string, launch *Launch) error {
+	jvmArgs, serverArgs, err := encodeArgs(launch)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = repository.db.Exec(
+		`update servers set min_heap_mb=?, max_heap_mb=?, jvm_args=?, server_args=?, java_home=?,
+		cpu_weight=?, cpu_quota_percent=?, memory_max_mb=?, pids_max=?, io_weight=?, updated_at=? where id=?`,
+		launch.MinHeapMB, launch.MaxHeapMB, jvmArgs, serverArgs, launch.JavaHome,
+		launch.CPUWeight, launch.CPUQuotaPercent, launch.MemoryMaxMB, launch.PidsMax, launch.IOWeight, time.Now().Unix(), id,
+	)
+
+	return err
+}
+
+func (repository *sqlServerRepository) SetProcess(id string, pid int, status bool) error {
+	query := "update servers set pid=?, status=?, updated_at=? where id=?"
+
+	if status {
+		query = "update servers set pid=?, status=?, pending_restart=false, updated_at=? where id=?"
+	}
+
+	_, err := repository.db.Exec(query, pid, status, time.Now().Unix(), id)
+
+	return err
+}
+
+func (repository *sqlServerRepository) Transfer(fromUserId string, toUserId string) error {
+	transaction, err := repository.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	// The new owner has full access, so grants they had on the transferred servers are redundant
+	if _, err := transaction.Exec("delete from server_grants where user_id=? and server_id in (select id from servers where user_id=?)", toUserId, fromUserId); err != nil {
+		return err
+	}
+
+	if _, err := transaction.Exec("update servers set user_id=?, updated_at=? where user_id=?", toUserId, time.Now().Unix(), fromUserId); err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+func (repository *sqlServerRepository) Delete(id string) error {
+	transaction, err := repository.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	for _, statement := range []string{
+		"delete from server_grants where server_id=?",
+		"delete from server_ports where server_id=?",
+		"delete from servers where id=?",
+	} {
+		if _, err := transaction.Exec(statement, id); err != nil {
+			return err
+		}
+	}
+
+	return transaction.Commit()
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DEFAULT_DATABASE_PATH is the SQLite database gomine uses when no DATABASE_URL is set
+const DEFAULT_DATABASE_PATH = "./gomine.db"
+
+// Dialect is the kind of database a store is backed by
+type Dialect string
+
+const (
+	// DialectSQLite stores everything in a single SQLite file
+	DialectSQLite Dialect = "sqlite"
+	// DialectPostgres stores everything in a PostgreSQL database
+	DialectPostgres Dialect = "postgres"
+)
+
+// STORE_CONTEXT_KEY is the gin context key holding the store requests are served with
+const STORE_CONTEXT_KEY = "store"
+
+// Store gives access to the database. Users and servers are accessed through their repositories
+// so handlers can be tested with fakes. Every other table is queried through DB.
+type Store struct {
+	DB      *sql.DB
+	Dialect Dialect
+	Users   UserRepository
+	Servers ServerRepository
+}
+
+// ParseDatabaseURL returns the dialect of a DATABASE_URL and the data source to open with it.
+// postgres:// and postgresql:// URLs are PostgreSQL databases. Anything else is the path of a
+// SQLite database, optionally prefixed with sqlite://, and an empty URL is DEFAULT_DATABASE_PATH.
+func ParseDatabaseURL(databaseUrl string) (Dialect, string) {
+	if strings.HasPrefix(databaseUrl, "postgres://") || strings.HasPrefix(databaseUrl, "postgresql://") {
+		return DialectPostgres, databaseUrl
+	}
+
+	path := strings.TrimPrefix(databaseUrl, "sqlite://")
+
+	if path == "" {
+		path = DEFAULT_DATABASE_PATH
+	}
+
+	return DialectSQLite, path
+}
+
+// Open opens the database a DATABASE_URL points to. SQLite connections use WAL mode so reads do
+// not block writes, wait for locks instead of failing right away and enforce foreign keys. Their
+// transactions take the write lock when they begin, so two transactions cannot deadlock upgrading
+// their locks.
+func Open(databaseUrl string) (*sql.DB, Dialect, error) {
+	dialect, dataSource := ParseDatabaseURL(databaseUrl)
+	driverName := POSTGRES_DRIVER_NAME
+
+	if dialect == DialectSQLite {
+		driverName = "sqlite3"
+		dataSource = fmt.Sprintf("file:%v?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=on&_txlock=immediate", dataSource)
+	}
+
+	db, err := sql.Open(driverName, dataSource)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, "", err
+	}
+
+	return db, dialect, nil
+}
+
+// New creates a store backed by db
+func New(db *sql.DB, dialect Dialect) *Store {
+	return &Store{
+		DB:      db,
+		Dialect: dialect,
+		Users:   &sqlUserRepository{db: db, dialect: dialect},
+		Servers: &sqlServerRepository{db: db},
+	}
+}
+
+// Middleware makes a store available to every handler through FromContext
+func Middleware(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(STORE_CONTEXT_KEY, store)
+		c.Next()
+	}
+}
+
+// FromContext returns the store set by Middleware
+func FromContext(c *gin.Context) *Store {
+	return c.MustGet(STORE_CONTEXT_KEY).(*Store)
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrUsernameTaken is returned when another user has the same username, ignoring case
+var ErrUsernameTaken = errors.New("Username is already taken")
+
+// User is a row of the users table
+type User struct {
+	ID              string
+	Username        string
+	Email           string
+	EmailVerified   bool
+	Hash            string
+	Role            string
+	Disabled        bool
+	PendingApproval bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// InsertUserOptions controls how a user signs up
+type InsertUserOptions struct {
+	// BootstrapAdmin creates the user as admin while there is no admin yet, in which case they are
+	// neither pending approval nor asked to redeem anything
+	BootstrapAdmin bool
+	// RequireApproval creates the user pending approval of an admin
+	RequireApproval bool
+	// Redeem runs in the inserting transaction. An error aborts the insert.
+	Redeem func(transaction *sql.Tx) error
+}
+
+// UserRepository stores users. Lookups of users that do not exist fail with sql.ErrNoRows.
+type UserRepository interface {
+	// Insert creates a user and sets the role and approval state it was given
+	Insert(user *User, options *InsertUserOptions) error
+	Get(id string) (*User, error)
+	// GetByUsername looks a user up by username, ignoring case
+	GetByUsername(username string) (*User, error)
+	// GetByVerifiedEmail returns the only user who verified an email address. Addresses verified
+	// by several users are ambiguous and match nobody.
+	GetByVerifiedEmail(email string) (*User, error)
+	// List returns every user ordered by username
+	List() ([]User, error)
+	// Update saves the username, email, hash, role and account state of a user
+	Update(user *User) error
+	// CountOtherActiveAdmins counts the admins that would remain if the given user lost admin
+	// rights
+	CountOtherActiveAdmins(id string) (int, error)
+	// Delete removes a user along with their sessions, API keys, grants and credentials
+	Delete(id string) error
+}
+
+const userColumns = "id, username, email, email_verified, hash, role, disabled, pending_approval, created_at, updated_at"
+
+type sqlUserRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (*User, error) {
+	user := User{}
+	var createdAt, updatedAt int64
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.EmailVerified, &user.Hash, &user.Role, &user.Disabled, &user.PendingApproval, &createdAt, &updatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user.CreatedAt = time.Unix(createdAt, 0)
+	user.UpdatedAt = time.Unix(updatedAt, 0)
+
+	return &user, nil
+}
+
+// wrapUniqueUsernameError turns violations of the unique username index into ErrUsernameTaken
+func wrapUniqueUsernameError(err error) error {
+	var sqliteErr sqlite3.Error
+
+	if (errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique) || isPostgresUniqueViolation(err) {
+		return ErrUsernameTaken
+	}
+
+	return err
+}
+
+func (repository *sqlUserRepository) Insert(user *User, options *InsertUserOptions) error {
+	transaction, err := repository.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	// SQLite transactions take the write lock when they begin. PostgreSQL ones have to lock the
+	// table, or two users signing up at once could both be bootstrapped as admin.
+	if repository.dialect == DialectPostgres {
+		if _, err := transaction.Exec("lock table users in share row exclusive mode"); err != nil {
+			return err
+		}
+	}
+
+	var hasAdmin bool
+
+	if err := transaction.QueryRow("select exists(select 1 from users where role='admin' and not disabled)").Scan(&hasAdmin); err != nil {
+		return err
+	}
+
+	isAdmin := options.BootstrapAdmin && !hasAdmin
+
+	if !isAdmin && options.Redeem != nil {
+		if err := options.Redeem(transaction); err != nil {
+			return err
+		}
+	}
+
+	user.Role = "user"
+	user.PendingApproval = !isAdmin && options.RequireApproval
+	user.CreatedAt = time.Unix(time.Now().Unix(), 0)
+	user.UpdatedAt = user.CreatedAt
+
+	if isAdmin {
+		user.Role = "admin"
+	}
+
+	_, err = transaction.Exec(
+		"insert into users(id, username, email, email_verified, hash, role, pending_approval, created_at, updated_at) values(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		user.ID, user.Username, user.Email, user.EmailVerified, user.Hash, user.Role, user.PendingApproval, user.CreatedAt.Unix(), user.UpdatedAt.Unix(),
+	)
+
+	if err != nil {
+		return wrapUniqueUsernameError(err)
+	}
+
+	return transaction.Commit()
+}
+
+func (repository *sqlUserRepository) Get(id string) (*User, error) {
+	return scanUser(repository.db.QueryRow("select "+userColumns+" from users where id=?", id))
+}
+
+// caseInsensitiveEquals compares a column to a parameter ignoring case. SQLite columns are
+// compared with the NOCASE collation so the unique username index can be used, while PostgreSQL
+// indexes lower(username).
+func (repository *sqlUserRepository) caseInsensitiveEquals(column string) string {
+	if repository.dialect == DialectPostgres {
+		return "lower(" + column + ")=lower(?)"
+	}
+
+	return column + "=? collate nocase"
+}
+
+func (repository *sqlUserRepository) GetByUsername(username string) (*User, error) {
+	return scanUser(repository.db.QueryRow("select "+userColumns+" from users where "+repository.caseInsensitiveEquals("username"), username))
+}
+
+func (repository *sqlUserRepository) GetByVerifiedEmail(email string) (*User, error) {
+	users, err := repository.query("select "+userColumns+" from users where email_verified and "+repository.caseInsensitiveEquals("email")+" limit 2", email)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) != 1 {
+		return nil, sql.ErrNoRows
+	}
+
+	return &users[0], nil
+}
+
+func (repository *sqlUserRepository) List() ([]User, error) {
+	return repository.query("select " + userColumns + " from users order by username")
+}
+
+func (repository *sqlUserRepository) query(query string, args ...any) ([]User, error) {
+	rows, err := repository.db.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	users := []User{}
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, *user)
+	}
+
+	return users, rows.Err()
+}
+
+func (repository *sqlUserRepository) Update(user *User) error {
+	user.UpdatedAt = time.Unix(time.Now().Unix(), 0)
+	_, err := repository.db.Exec(
+		"update users set username=?, email=?, email_verified=?, hash=?, role=?, disabled=?, pending_approval=?, updated_at=? where id=?",
+		user.Username, user.Email, user.EmailVerified, user.Hash, user.Role, user.Disabled, user.PendingApproval, user.UpdatedAt.Unix(), user.ID,
+	)
+
+	return wrapUniqueUsernameError(err)
+}
+
+func (repository *sqlUserRepository) CountOtherActiveAdmins(id string) (int, error) {
+	var count int
+	err := repository.db.QueryRow("select count(*) from users where role='admin' and not disabled and id<>?", id).Scan(&count)
+
+	return count, err
+}
+
+func (repository *sqlUserRepository) Delete(id string) error {
+	transaction, err := repository.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	for _, statement := range []string{
+		"delete from server_grants where user_id=?",
+		"delete from api_keys where user_id=?",
+		"delete from refresh_tokens where user_id=?",
+		"delete from mfa_recovery_codes where user_id=?",
+		"delete from user_mfa where user_id=?",
+		"delete from password_resets where user_id=?",
+		"delete from user_identities where user_id=?",
+		"delete from users where id=?",
+	} {
+		if _, err := transaction.Exec(statement, id); err != nil {
+			return err
+		}
+	}
+
+	return transaction.Commit()
+}
@@ -0,0 +1,218 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ecuyle/gomine/internal/config"
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a key tokens are signed or verified with. SignKey is only set for the key new
+// tokens are signed with.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// LEGACY_KEY_ID is the kid access tokens issued before tokens had a kid are checked against. They
+// were signed with API_SECRET, which is the key of this kid until JWT_KEY_ID is first changed.
+const LEGACY_KEY_ID = "default"
+
+// KeySet holds the key new tokens are signed with and every key tokens are still accepted from,
+// indexed by kid. Rotating API_SECRET takes a new JWT_KEY_ID and keeps the old secret under the
+// old kid in JWT_PREVIOUS_SECRETS, so tokens that were already issued stay valid until they expire.
+type KeySet struct {
+	Current *SigningKey
+	byID    map[string]*SigningKey
+}
+
+// NewKeySet creates a key set signing with current and verifying with current and previous
+func NewKeySet(current *SigningKey, previous ...*SigningKey) (*KeySet, error) {
+	keys := KeySet{Current: current, byID: map[string]*SigningKey{}}
+
+	for _, key := range append([]*SigningKey{current}, previous...) {
+		if _, ok := keys.byID[key.ID]; ok {
+			return nil, fmt.Errorf("Duplicate JWT key id `%v`", key.ID)
+		}
+
+		keys.byID[key.ID] = key
+	}
+
+	return &keys, nil
+}
+
+// Get returns the verification key with the given kid
+func (keys *KeySet) Get(id string) (*SigningKey, bool) {
+	key, ok := keys.byID[id]
+
+	return key, ok
+}
+
+// Methods returns the names of the algorithms tokens may be signed with
+func (keys *KeySet) Methods() []string {
+	methods := []string{}
+	seen := map[string]bool{}
+
+	for _, key := range keys.byID {
+		if name := key.Method.Alg(); !seen[name] {
+			seen[name] = true
+			methods = append(methods, name)
+		}
+	}
+
+	return methods
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(id string, secret string) (*SigningKey, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("JWT key `%v` has an empty secret", id)
+	}
+
+	return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, SignKey: []byte(secret), VerifyKey: []byte(secret)}, nil
+}
+
+// parsePrivateKeyFile reads an RS256 or EdDSA private key from a PEM file
+func parsePrivateKeyFile(id string, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, SignKey: key, VerifyKey: &key.PublicKey}, nil
+	}
+
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		privateKey := key.(ed25519.PrivateKey)
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, SignKey: privateKey, VerifyKey: privateKey.Public()}, nil
+	}
+
+	return nil, fmt.Errorf("`%v` is not an RSA or Ed25519 private key", path)
+}
+
+// parsePublicKeyFile reads an RS256 or EdDSA public key from a PEM file
+func parsePublicKeyFile(id string, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, VerifyKey: key}, nil
+	}
+
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, VerifyKey: key}, nil
+	}
+
+	return nil, fmt.Errorf("`%v` is not an RSA or Ed25519 public key", path)
+}
+
+// parseKeyList splits a comma separated list of `kid:value` pairs
+func parseKeyList(name string, rawValue string) ([][2]string, error) {
+	pairs := [][2]string{}
+
+	for _, entry := range strings.Split(rawValue, ",") {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "" {
+			continue
+		}
+
+		id, value, ok := strings.Cut(entry, ":")
+
+		if !ok || id == "" || value == "" {
+			return nil, fmt.Errorf("%v entries must look like `kid:value`, got `%v`", name, entry)
+		}
+
+		pairs = append(pairs, [2]string{id, value})
+	}
+
+	return pairs, nil
+}
+
+// LoadKeySet builds the key set of a token configuration. Tokens are signed with the private key
+// file when there is one and with the secret otherwise.
+func LoadKeySet(config *config.TokenConfig) (*KeySet, error) {
+	var current *SigningKey
+	var err error
+
+	if config.PrivateKeyFile != "" {
+		current, err = parsePrivateKeyFile(config.KeyID, config.PrivateKeyFile)
+	} else {
+		current, err = NewHMACKey(config.KeyID, config.Secret)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	previous := []*SigningKey{}
+	secrets, err := parseKeyList("JWT_PREVIOUS_SECRETS", config.PreviousSecrets)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pair := range secrets {
+		key, err := NewHMACKey(pair[0], pair[1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		previous = append(previous, key)
+	}
+
+	publicKeyFiles, err := parseKeyList("JWT_PUBLIC_KEY_FILES", config.PublicKeyFiles)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pair := range publicKeyFiles {
+		key, err := parsePublicKeyFile(pair[0], pair[1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		previous = append(previous, key)
+	}
+
+	return NewKeySet(current, previous...)
+}
+
+// verificationKey picks the key a token has to be verified with based on its kid header
+func (keys *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+
+	return keys.verificationKeyByID(token, id)
+}
+
+// legacyVerificationKey picks the key of LEGACY_KEY_ID for a token issued before tokens had a kid
+func (keys *KeySet) legacyVerificationKey(token *jwt.Token) (interface{}, error) {
+	return keys.verificationKeyByID(token, LEGACY_KEY_ID)
+}
+
+func (keys *KeySet) verificationKeyByID(token *jwt.Token, id string) (interface{}, error) {
+	key, ok := keys.Get(id)
+
+	if !ok {
+		return nil, fmt.Errorf("%w: `%v`", ErrUnknownKey, id)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("%w: %v", ErrUnexpectedSigningMethod, token.Method.Alg())
+	}
+
+	return key.VerifyKey, nil
+}
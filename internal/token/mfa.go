@@ -0,0 +1,94 @@
+package token
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// MFA_CHALLENGE_AUDIENCE is the aud claim of MFA challenge tokens. It keeps them from being
+// accepted as access tokens.
+const MFA_CHALLENGE_AUDIENCE = "gomine-mfa"
+
+// MFA_CHALLENGE_LIFESPAN is how long a user has to enter their second factor after their password
+const MFA_CHALLENGE_LIFESPAN = 5 * time.Minute
+
+// ErrMFAChallengeUsed is returned when an MFA challenge token that was already completed is
+// presented again
+var ErrMFAChallengeUsed = errors.New("MFA challenge was already completed")
+
+// MFAChallenge proves that a user entered the right password and still has to pass 2FA
+type MFAChallenge struct {
+	ID        string
+	UserID    string
+	Username  string
+	ExpiresAt time.Time
+}
+
+type mfaChallengeJWTClaims struct {
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
+// GenerateMFAChallengeToken issues a challenge token for a user whose password was verified
+func (authority *Authority) GenerateMFAChallengeToken(userId string, username string) (string, error) {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := mfaChallengeJWTClaims{
+		Username: username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id.String(),
+			Issuer:    authority.Issuer,
+			Subject:   userId,
+			Audience:  jwt.ClaimStrings{MFA_CHALLENGE_AUDIENCE},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFA_CHALLENGE_LIFESPAN)),
+		},
+	}
+
+	return signClaims(authority.Keys, claims)
+}
+
+// ParseMFAChallengeToken validates an MFA challenge token
+func (authority *Authority) ParseMFAChallengeToken(rawToken string) (*MFAChallenge, error) {
+	claims := mfaChallengeJWTClaims{}
+
+	if err := authority.parseClaims(rawToken, &claims, MFA_CHALLENGE_AUDIENCE); err != nil {
+		return nil, err
+	}
+
+	if claims.ID == "" || claims.Subject == "" {
+		return nil, ErrTokenMissingClaims
+	}
+
+	return &MFAChallenge{ID: claims.ID, UserID: claims.Subject, Username: claims.Username, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// CompleteMFAChallenge marks a challenge as used so it cannot be exchanged for a second session
+func CompleteMFAChallenge(db *sql.DB, challenge *MFAChallenge) error {
+	result, err := db.Exec(
+		"insert into revoked_tokens(jti, expires_at) values(?, ?) on conflict(jti) do nothing",
+		challenge.ID, challenge.ExpiresAt.Unix(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return ErrMFAChallengeUsed
+	}
+
+	return nil
+}
@@ -1,19 +1,130 @@
 package token
 
 import (
-	"net/http"
+	"errors"
 
+	"github.com/ecuyle/gomine/internal/apikeys"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/store"
 	"github.com/gin-gonic/gin"
 )
 
+// AUTHORITY_CONTEXT_KEY is the gin context key holding the authority tokens are issued and
+// verified with
+const AUTHORITY_CONTEXT_KEY = "tokenAuthority"
+
+// USER_ID_CONTEXT_KEY is the gin context key holding the id of the authenticated user
+const USER_ID_CONTEXT_KEY = "userId"
+
+// ACCESS_TOKEN_CLAIMS_CONTEXT_KEY is the gin context key holding the claims of the access token
+// the request was authenticated with
+const ACCESS_TOKEN_CLAIMS_CONTEXT_KEY = "accessTokenClaims"
+
+// API_KEY_CONTEXT_KEY is the gin context key holding the API key the request was authenticated with
+const API_KEY_CONTEXT_KEY = "apiKey"
+
+// Middleware makes an authority available to every handler through FromContext
+func Middleware(authority *Authority) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(AUTHORITY_CONTEXT_KEY, authority)
+		c.Next()
+	}
+}
+
+// FromContext returns the authority set by Middleware
+func FromContext(c *gin.Context) *Authority {
+	return c.MustGet(AUTHORITY_CONTEXT_KEY).(*Authority)
+}
+
+// JwtAuthMiddleware authenticates requests with either a Bearer access token or an API key. API
+// keys are accepted as Bearer credentials or in the X-API-Key header.
 func JwtAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !IsTokenValid(c) {
-			c.String(http.StatusUnauthorized, "Unauthorized")
-			c.Abort()
+		credential := ExtractToken(c)
+
+		if rawKey := c.Request.Header.Get("X-API-Key"); rawKey != "" {
+			credential = rawKey
+		}
+
+		if apikeys.IsAPIKey(credential) {
+			authenticateAPIKey(c, credential)
+			return
+		}
+
+		claims, err := FromContext(c).ParseAccessToken(credential)
+
+		if err != nil {
+			httputils.RespondWithError(c, httputils.Unauthorized("Unauthorized"))
+			return
+		}
+
+		revoked, err := IsAccessTokenRevoked(store.FromContext(c).DB, claims)
+
+		if err != nil {
+			httputils.RespondWithInternalServerError(c, err)
+			return
+		}
+
+		if revoked {
+			httputils.RespondWithError(c, httputils.Unauthorized("Unauthorized"))
+			return
+		}
+
+		c.Set(USER_ID_CONTEXT_KEY, claims.UserID)
+		c.Set(ACCESS_TOKEN_CLAIMS_CONTEXT_KEY, claims)
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, rawKey string) {
+	key, err := apikeys.Authenticate(store.FromContext(c).DB, rawKey)
+
+	if errors.Is(err, apikeys.ErrInvalidAPIKey) {
+		httputils.RespondWithError(c, httputils.Unauthorized("Unauthorized"))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(c, err)
+		return
+	}
+
+	c.Set(USER_ID_CONTEXT_KEY, key.UserID)
+	c.Set(API_KEY_CONTEXT_KEY, key)
+	c.Next()
+}
+
+// RequireAccessToken rejects requests authenticated with an API key. It guards routes that manage
+// the account itself, such as logging out or creating more API keys, and routes no scope covers,
+// such as creating servers.
+func RequireAccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetAccessTokenClaims(c) == nil {
+			httputils.RespondWithError(c, httputils.Forbidden("This operation requires logging in with a password"))
 			return
 		}
 
 		c.Next()
 	}
 }
+
+// GetAuthenticatedUserId returns the id of the user authenticated by JwtAuthMiddleware
+func GetAuthenticatedUserId(c *gin.Context) string {
+	return c.GetString(USER_ID_CONTEXT_KEY)
+}
+
+// GetAccessTokenClaims returns the claims of the access token validated by JwtAuthMiddleware, or
+// nil if the request was authenticated with an API key
+func GetAccessTokenClaims(c *gin.Context) *AccessTokenClaims {
+	claims, _ := c.Value(ACCESS_TOKEN_CLAIMS_CONTEXT_KEY).(*AccessTokenClaims)
+
+	return claims
+}
+
+// GetAPIKey returns the API key the request was authenticated with, or nil if it was
+// authenticated with an access token
+func GetAPIKey(c *gin.Context) *apikeys.APIKey {
+	key, _ := c.Value(API_KEY_CONTEXT_KEY).(*apikeys.APIKey)
+
+	return key
+}
@@ -0,0 +1,303 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidRefreshToken is returned for refresh tokens that are unknown, expired or revoked
+var ErrInvalidRefreshToken = errors.New("Invalid refresh token")
+
+// ErrRefreshTokenReused is returned when a refresh token that was already rotated is presented
+// again. This means the token leaked, so its whole family is revoked.
+var ErrRefreshTokenReused = errors.New("Refresh token was already used. The session has been revoked.")
+
+// TokenPair is what a client receives when logging in or refreshing a session
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type refreshTokenRecord struct {
+	ID        string
+	FamilyID  string
+	UserID    string
+	ExpiresAt int64
+	UsedAt    sql.NullInt64
+	RevokedAt sql.NullInt64
+}
+
+// hashRefreshToken hashes a refresh token for storage. Refresh tokens are random and long, so a
+// fast unsalted hash is enough to make a leaked database useless.
+func hashRefreshToken(rawToken string) string {
+	sum := sha256.Sum256([]byte(rawToken))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func generateRefreshToken() (string, error) {
+	bytes := make([]byte, 32)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// insertRefreshToken creates a new refresh token in a family and returns its raw value
+func insertRefreshToken(transaction *sql.Tx, familyId string, userId string, lifespan time.Duration) (string, error) {
+	rawToken, err := generateRefreshToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = transaction.Exec(
+		"insert into refresh_tokens(id, family_id, user_id, hash, created_at, expires_at) values(?, ?, ?, ?, ?, ?)",
+		id.String(), familyId, userId, hashRefreshToken(rawToken), now.Unix(), now.Add(lifespan).Unix(),
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return rawToken, nil
+}
+
+func selectRefreshTokenByHash(transaction *sql.Tx, hash string) (*refreshTokenRecord, error) {
+	record := refreshTokenRecord{}
+	err := transaction.QueryRow(
+		"select id, family_id, user_id, expires_at, used_at, revoked_at from refresh_tokens where hash=?", hash,
+	).Scan(&record.ID, &record.FamilyID, &record.UserID, &record.ExpiresAt, &record.UsedAt, &record.RevokedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// IssueTokenPair starts a new session for a user
+func (authority *Authority) IssueTokenPair(db *sql.DB, userId string) (*TokenPair, error) {
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer transaction.Rollback()
+
+	familyId, err := uuid.NewRandom()
+
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := insertRefreshToken(transaction, familyId.String(), userId, authority.RefreshTokenLifespan)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := authority.GenerateToken(userId, familyId.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// RefreshTokenPair rotates a refresh token. The presented token is marked as used and a new token
+// of the same family is issued with a fresh access token. Presenting a used token again revokes
+// the family.
+func (authority *Authority) RefreshTokenPair(db *sql.DB, rawRefreshToken string) (*TokenPair, error) {
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer transaction.Rollback()
+
+	record, err := selectRefreshTokenByHash(transaction, hashRefreshToken(rawRefreshToken))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if record.RevokedAt.Valid || record.ExpiresAt <= now.Unix() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if record.UsedAt.Valid {
+		return nil, revokeReusedRefreshToken(transaction, record.FamilyID)
+	}
+
+	// A concurrent refresh with the same token may have used it since it was selected. PostgreSQL
+	// waits for that refresh to commit and then updates nothing, which is reuse as well.
+	result, err := transaction.Exec("update refresh_tokens set used_at=? where id=? and used_at is null", now.Unix(), record.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := result.RowsAffected()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if updated == 0 {
+		return nil, revokeReusedRefreshToken(transaction, record.FamilyID)
+	}
+
+	refreshToken, err := insertRefreshToken(transaction, record.FamilyID, record.UserID, authority.RefreshTokenLifespan)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := authority.GenerateToken(record.UserID, record.FamilyID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// revokeReusedRefreshToken revokes the family of a refresh token that was presented again and
+// returns ErrRefreshTokenReused
+func revokeReusedRefreshToken(transaction *sql.Tx, familyId string) error {
+	if err := revokeRefreshTokenFamily(transaction, familyId); err != nil {
+		return err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+func revokeRefreshTokenFamily(transaction *sql.Tx, familyId string) error {
+	_, err := transaction.Exec("update refresh_tokens set revoked_at=? where family_id=? and revoked_at is null", time.Now().Unix(), familyId)
+
+	return err
+}
+
+// RevokeSession ends a session by revoking its refresh token family and the access token used to
+// make the request
+func RevokeSession(db *sql.DB, claims *AccessTokenClaims) error {
+	transaction, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer transaction.Rollback()
+
+	if claims.SessionID != "" {
+		if err := revokeRefreshTokenFamily(transaction, claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().Unix()
+
+	// Revoked access tokens only need to be remembered until they expire
+	if _, err := transaction.Exec("delete from revoked_tokens where expires_at<=?", now); err != nil {
+		return err
+	}
+
+	_, err = transaction.Exec(
+		"insert into revoked_tokens(jti, expires_at) values(?, ?) on conflict(jti) do nothing",
+		claims.ID, claims.ExpiresAt.Unix(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+// SessionStartedAt returns when a session started, which is when its user logged in. Sessions that
+// do not exist fail with sql.ErrNoRows.
+func SessionStartedAt(db *sql.DB, sessionId string) (time.Time, error) {
+	var createdAt sql.NullInt64
+
+	if err := db.QueryRow("select min(created_at) from refresh_tokens where family_id=?", sessionId).Scan(&createdAt); err != nil {
+		return time.Time{}, err
+	}
+
+	if !createdAt.Valid {
+		return time.Time{}, sql.ErrNoRows
+	}
+
+	return time.Unix(createdAt.Int64, 0), nil
+}
+
+// IsAccessTokenRevoked reports whether an access token was revoked, either directly or through
+// its session. Sessions without a refresh token that is still valid have ended, which covers
+// deleted users.
+func IsAccessTokenRevoked(db *sql.DB, claims *AccessTokenClaims) (bool, error) {
+	var revoked bool
+
+	// Legacy access tokens from before refresh tokens have no session, and end with their user
+	if claims.SessionID == "" {
+		err := db.QueryRow(
+			`select exists(select 1 from revoked_tokens where jti=?)
+				or not exists(select 1 from users where id=? and not disabled)`,
+			claims.ID, claims.UserID,
+		).Scan(&revoked)
+
+		return revoked, err
+	}
+
+	err := db.QueryRow(
+		`select exists(select 1 from revoked_tokens where jti=?)
+			or not exists(select 1 from refresh_tokens where family_id=? and revoked_at is null)`,
+		claims.ID, claims.SessionID,
+	).Scan(&revoked)
+
+	return revoked, err
+}
+
+// RevokeUserSessions revokes every session of a user, and with them every access token issued
+// for those sessions
+func RevokeUserSessions(db *sql.DB, userId string) error {
+	_, err := db.Exec("update refresh_tokens set revoked_at=? where user_id=? and revoked_at is null", time.Now().Unix(), userId)
+
+	return err
+}
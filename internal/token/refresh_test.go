@@ -0,0 +1,106 @@
+package token
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/gin-gonic/gin"
+	"gotest.tools/assert"
+)
+
+func openStore(t *testing.T) *store.Store {
+	db, dialect, err := store.Open(filepath.Join(t.TempDir(), "gomine.db"))
+	assert.NilError(t, err)
+	t.Cleanup(func() { db.Close() })
+	assert.NilError(t, store.Migrate(db, dialect))
+
+	dataStore := store.New(db, dialect)
+	assert.NilError(t, dataStore.Users.Insert(&store.User{ID: "u1", Username: "steve", Hash: "hash"}, &store.InsertUserOptions{}))
+
+	return dataStore
+}
+
+func newTestAuthority(t *testing.T) *Authority {
+	keys, err := NewKeySet(mustHMACKey(t, "default", "secret"))
+	assert.NilError(t, err)
+
+	return &Authority{Keys: keys, Issuer: "gomine", AccessTokenLifespan: time.Minute, RefreshTokenLifespan: time.Hour}
+}
+
+func isRevoked(t *testing.T, dataStore *store.Store, authority *Authority, accessToken string) bool {
+	claims, err := authority.ParseAccessToken(accessToken)
+	assert.NilError(t, err)
+	revoked, err := IsAccessTokenRevoked(dataStore.DB, claims)
+	assert.NilError(t, err)
+
+	return revoked
+}
+
+// Test RefreshTokenPair and assert that refresh tokens rotate within their session, and that
+// presenting a rotated token again revokes the session with every access token issued for it
+func TestRefreshTokenPairReuse(t *testing.T) {
+	dataStore := openStore(t)
+	authority := newTestAuthority(t)
+
+	first, err := authority.IssueTokenPair(dataStore.DB, "u1")
+	assert.NilError(t, err)
+
+	second, err := authority.RefreshTokenPair(dataStore.DB, first.RefreshToken)
+	assert.NilError(t, err)
+	assert.Assert(t, second.RefreshToken != first.RefreshToken)
+	assert.Assert(t, !isRevoked(t, dataStore, authority, second.AccessToken))
+
+	_, err = authority.RefreshTokenPair(dataStore.DB, first.RefreshToken)
+	assertErrorIs(t, err, ErrRefreshTokenReused)
+	assert.Assert(t, isRevoked(t, dataStore, authority, first.AccessToken))
+	assert.Assert(t, isRevoked(t, dataStore, authority, second.AccessToken))
+
+	_, err = authority.RefreshTokenPair(dataStore.DB, second.RefreshToken)
+	assertErrorIs(t, err, ErrInvalidRefreshToken)
+
+	_, err = authority.RefreshTokenPair(dataStore.DB, "unknown")
+	assertErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+// Test JwtAuthMiddleware and assert that an access token stops authenticating requests once its
+// session is revoked by logging out, while other sessions of the user keep working
+func TestJwtAuthMiddlewareAfterLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataStore := openStore(t)
+	authority := newTestAuthority(t)
+
+	router := gin.New()
+	router.Use(store.Middleware(dataStore), Middleware(authority))
+	router.GET("/me", JwtAuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, GetAuthenticatedUserId(c))
+	})
+
+	get := func(accessToken string) int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/me", nil)
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+		router.ServeHTTP(recorder, request)
+
+		return recorder.Code
+	}
+
+	session, err := authority.IssueTokenPair(dataStore.DB, "u1")
+	assert.NilError(t, err)
+	other, err := authority.IssueTokenPair(dataStore.DB, "u1")
+	assert.NilError(t, err)
+	assert.Equal(t, get(session.AccessToken), http.StatusOK)
+
+	claims, err := authority.ParseAccessToken(session.AccessToken)
+	assert.NilError(t, err)
+	assert.NilError(t, RevokeSession(dataStore.DB, claims))
+
+	assert.Equal(t, get(session.AccessToken), http.StatusUnauthorized)
+	assert.Equal(t, get(other.AccessToken), http.StatusOK)
+
+	_, err = authority.RefreshTokenPair(dataStore.DB, session.RefreshToken)
+	assertErrorIs(t, err, ErrInvalidRefreshToken)
+}
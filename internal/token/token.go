@@ -1,43 +1,259 @@
 package token
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ecuyle/gomine/internal/config"
 	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-func GenerateToken(userId string) (string, error) {
-	token_lifespan, err := strconv.Atoi(os.Getenv("JWT_AUTH_LIFESPAN_HOURS"))
+// ACCESS_TOKEN_AUDIENCE is the aud claim access tokens for the gomine API are issued for
+const ACCESS_TOKEN_AUDIENCE = "gomine-api"
+
+var (
+	ErrTokenMissing            = errors.New("Token is missing")
+	ErrTokenMalformed          = errors.New("Token is malformed")
+	ErrTokenExpired            = errors.New("Token is expired")
+	ErrTokenNotValidYet        = errors.New("Token is not valid yet")
+	ErrTokenInvalidIssuer      = errors.New("Token has an invalid issuer")
+	ErrTokenInvalidAudience    = errors.New("Token has an invalid audience")
+	ErrTokenInvalidSignature   = errors.New("Token signature is invalid")
+	ErrTokenMissingClaims      = errors.New("Token is missing required claims")
+	ErrUnknownKey              = errors.New("Token is signed with an unknown key")
+	ErrUnexpectedSigningMethod = errors.New("Token is signed with an unexpected signing method")
+)
+
+// AccessTokenClaims are the claims gomine puts in an access token. SessionID is the refresh token
+// family the access token was issued from, so revoking a family also revokes its access tokens.
+type AccessTokenClaims struct {
+	ID        string
+	UserID    string
+	SessionID string
+	ExpiresAt time.Time
+}
+
+type accessTokenJWTClaims struct {
+	UserID    string `json:"userId"`
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Authority issues and verifies tokens with the keys, issuer and lifespans of a token
+// configuration
+type Authority struct {
+	Keys                 *KeySet
+	Issuer               string
+	AccessTokenLifespan  time.Duration
+	RefreshTokenLifespan time.Duration
+	ClockSkew            time.Duration
+	AcceptLegacyTokens   bool
+}
+
+// NewAuthority loads the keys of a token configuration
+func NewAuthority(config *config.TokenConfig) (*Authority, error) {
+	keys, err := LoadKeySet(config)
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	claims := jwt.MapClaims{}
-	claims["authorized"] = true
-	claims["userId"] = userId
-	claims["exp"] = time.Now().Add(time.Hour * time.Duration(token_lifespan)).Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return &Authority{
+		Keys:                 keys,
+		Issuer:               config.Issuer,
+		AccessTokenLifespan:  time.Duration(config.AccessTokenLifespanMinutes) * time.Minute,
+		RefreshTokenLifespan: time.Duration(config.RefreshTokenLifespanHours) * time.Hour,
+		ClockSkew:            time.Duration(config.ClockSkewSeconds) * time.Second,
+		AcceptLegacyTokens:   config.AcceptLegacyTokens,
+	}, nil
+}
+
+// signClaims signs claims with the current key of a key set and advertises the key as kid
+func signClaims(keys *KeySet, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(keys.Current.Method, claims)
+	token.Header["kid"] = keys.Current.ID
 
-	return token.SignedString([]byte(os.Getenv("API_SECRET")))
+	return token.SignedString(keys.Current.SignKey)
 }
 
-func parseRawToken(rawToken string) (*jwt.Token, error) {
-	return jwt.Parse(rawToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+// translateParseError maps errors of the jwt library to the errors of this package
+func translateParseError(err error) error {
+	translations := []struct {
+		from error
+		to   error
+	}{
+		{ErrUnknownKey, ErrUnknownKey},
+		{ErrUnexpectedSigningMethod, ErrUnexpectedSigningMethod},
+		{jwt.ErrTokenMalformed, ErrTokenMalformed},
+		{jwt.ErrTokenSignatureInvalid, ErrTokenInvalidSignature},
+		{jwt.ErrTokenExpired, ErrTokenExpired},
+		{jwt.ErrTokenNotValidYet, ErrTokenNotValidYet},
+		{jwt.ErrTokenUsedBeforeIssued, ErrTokenNotValidYet},
+		{jwt.ErrTokenInvalidIssuer, ErrTokenInvalidIssuer},
+		{jwt.ErrTokenInvalidAudience, ErrTokenInvalidAudience},
+		{jwt.ErrTokenRequiredClaimMissing, ErrTokenMissingClaims},
+	}
+
+	for _, translation := range translations {
+		if errors.Is(err, translation.from) {
+			return fmt.Errorf("%w: %v", translation.to, err)
 		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+	}
+
+	return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
 }
 
-func CheckTokenValidity(rawToken string) bool {
-	_, err := parseRawToken(rawToken)
+// parseClaims verifies a token against a key set and validates its registered claims. exp and iat
+// are required, nbf is checked when present, and iss and aud have to match.
+func parseClaims(keys *KeySet, issuer string, rawToken string, claims jwt.Claims, audience string, clockSkew time.Duration) error {
+	if rawToken == "" {
+		return ErrTokenMissing
+	}
+
+	_, err := jwt.ParseWithClaims(
+		rawToken,
+		claims,
+		keys.verificationKey,
+		jwt.WithValidMethods(keys.Methods()),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+
+	if err != nil {
+		return translateParseError(err)
+	}
+
+	return nil
+}
+
+// parseClaims parses a token issued by the authority
+func (authority *Authority) parseClaims(rawToken string, claims jwt.Claims, audience string) error {
+	return parseClaims(authority.Keys, authority.Issuer, rawToken, claims, audience, authority.ClockSkew)
+}
+
+func newAccessTokenClaims(issuer string, userId string, sessionId string, lifespan time.Duration) (*accessTokenJWTClaims, error) {
+	id, err := uuid.NewRandom()
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	return &accessTokenJWTClaims{
+		UserID:    userId,
+		SessionID: sessionId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id.String(),
+			Issuer:    issuer,
+			Subject:   userId,
+			Audience:  jwt.ClaimStrings{ACCESS_TOKEN_AUDIENCE},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifespan)),
+		},
+	}, nil
+}
+
+// GenerateToken issues a short-lived access token for a user's session
+func (authority *Authority) GenerateToken(userId string, sessionId string) (string, error) {
+	claims, err := newAccessTokenClaims(authority.Issuer, userId, sessionId, authority.AccessTokenLifespan)
+
+	if err != nil {
+		return "", err
+	}
+
+	return signClaims(authority.Keys, claims)
+}
+
+func toAccessTokenClaims(claims *accessTokenJWTClaims) (*AccessTokenClaims, error) {
+	if claims.ID == "" || claims.UserID == "" || claims.UserID != claims.Subject {
+		return nil, ErrTokenMissingClaims
+	}
+
+	return &AccessTokenClaims{
+		ID:        claims.ID,
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// isLegacyToken reports whether a token was issued before tokens had a kid
+func isLegacyToken(rawToken string) bool {
+	token, _, err := jwt.NewParser().ParseUnverified(rawToken, jwt.MapClaims{})
+
+	if err != nil {
+		return false
+	}
+
+	_, ok := token.Header["kid"]
+
+	return !ok
+}
+
+// parseLegacyAccessToken validates an access token issued before tokens had a kid. Those only
+// have the userId and exp claims, and the jti and sid claims once refresh tokens were added.
+// Tokens without a jti are identified by their hash so they can still be revoked.
+func (authority *Authority) parseLegacyAccessToken(rawToken string) (*AccessTokenClaims, error) {
+	claims := accessTokenJWTClaims{}
+
+	_, err := jwt.ParseWithClaims(
+		rawToken,
+		&claims,
+		authority.Keys.legacyVerificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithLeeway(authority.ClockSkew),
+		jwt.WithExpirationRequired(),
+	)
+
+	if err != nil {
+		return nil, translateParseError(err)
+	}
+
+	if claims.UserID == "" {
+		return nil, ErrTokenMissingClaims
+	}
+
+	if claims.ID == "" {
+		sum := sha256.Sum256([]byte(rawToken))
+		claims.ID = hex.EncodeToString(sum[:])
+	}
+
+	return &AccessTokenClaims{
+		ID:        claims.ID,
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// ParseAccessToken validates an access token and returns its claims
+func (authority *Authority) ParseAccessToken(rawToken string) (*AccessTokenClaims, error) {
+	if authority.AcceptLegacyTokens && isLegacyToken(rawToken) {
+		return authority.parseLegacyAccessToken(rawToken)
+	}
+
+	claims := accessTokenJWTClaims{}
+
+	if err := authority.parseClaims(rawToken, &claims, ACCESS_TOKEN_AUDIENCE); err != nil {
+		return nil, err
+	}
+
+	return toAccessTokenClaims(&claims)
+}
+
+func (authority *Authority) CheckTokenValidity(rawToken string) bool {
+	_, err := authority.ParseAccessToken(rawToken)
 
 	return err == nil
 }
@@ -50,28 +266,20 @@ func ExtractTokenFromBearerToken(bearerToken string) string {
 	return ""
 }
 
-func ExtractUserIdFromToken(rawToken string) (string, error) {
-	token, err := parseRawToken(rawToken)
+func (authority *Authority) ExtractUserIdFromToken(rawToken string) (string, error) {
+	claims, err := authority.ParseAccessToken(rawToken)
 
 	if err != nil {
 		return "", err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-
-	if ok && token.Valid {
-		userId := claims["userId"].(string)
-
-		return userId, nil
-	}
-
-	return "", nil
+	return claims.UserID, nil
 }
 
 func IsTokenValid(c *gin.Context) bool {
 	rawToken := ExtractToken(c)
 
-	return CheckTokenValidity(rawToken)
+	return FromContext(c).CheckTokenValidity(rawToken)
 }
 
 func ExtractToken(c *gin.Context) string {
@@ -81,5 +289,5 @@ func ExtractToken(c *gin.Context) string {
 func ExtractTokenID(c *gin.Context) (string, error) {
 	rawToken := ExtractToken(c)
 
-	return ExtractUserIdFromToken(rawToken)
+	return FromContext(c).ExtractUserIdFromToken(rawToken)
 }
@@ -0,0 +1,132 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"gotest.tools/assert"
+)
+
+func assertErrorIs(t *testing.T, err error, target error) {
+	t.Helper()
+	assert.Assert(t, errors.Is(err, target), "expected %v, got %v", target, err)
+}
+
+func mustHMACKey(t *testing.T, id string, secret string) *SigningKey {
+	key, err := NewHMACKey(id, secret)
+	assert.NilError(t, err)
+
+	return key
+}
+
+func mustSign(t *testing.T, keys *KeySet, claims *accessTokenJWTClaims) string {
+	rawToken, err := signClaims(keys, claims)
+	assert.NilError(t, err)
+
+	return rawToken
+}
+
+func mustClaims(t *testing.T, lifespan time.Duration) *accessTokenJWTClaims {
+	claims, err := newAccessTokenClaims("gomine", "user", "session", lifespan)
+	assert.NilError(t, err)
+
+	return claims
+}
+
+// Test parseClaims and assert that tokens signed with a rotated out key are still accepted while
+// tokens signed with unknown keys are rejected
+func TestParseClaimsKeyRotation(t *testing.T) {
+	oldKeys, err := NewKeySet(mustHMACKey(t, "old", "old-secret"))
+	assert.NilError(t, err)
+	rotatedKeys, err := NewKeySet(mustHMACKey(t, "new", "new-secret"), mustHMACKey(t, "old", "old-secret"))
+	assert.NilError(t, err)
+	newKeys, err := NewKeySet(mustHMACKey(t, "new", "new-secret"))
+	assert.NilError(t, err)
+
+	rawToken := mustSign(t, oldKeys, mustClaims(t, time.Minute))
+
+	assert.NilError(t, parseClaims(rotatedKeys, "gomine", rawToken, &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 0))
+	assertErrorIs(t, parseClaims(newKeys, "gomine", rawToken, &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 0), ErrUnknownKey)
+}
+
+// Test parseClaims and assert that invalid registered claims are reported with typed errors
+func TestParseClaimsValidation(t *testing.T) {
+	keys, err := NewKeySet(mustHMACKey(t, "default", "secret"))
+	assert.NilError(t, err)
+
+	expired := mustClaims(t, -time.Minute)
+	assertErrorIs(t, parseClaims(keys, "gomine", mustSign(t, keys, expired), &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 0), ErrTokenExpired)
+	assert.NilError(t, parseClaims(keys, "gomine", mustSign(t, keys, expired), &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 2*time.Minute))
+
+	notYetValid := mustClaims(t, time.Hour)
+	notYetValid.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	assertErrorIs(t, parseClaims(keys, "gomine", mustSign(t, keys, notYetValid), &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 0), ErrTokenNotValidYet)
+
+	wrongIssuer := mustClaims(t, time.Minute)
+	wrongIssuer.Issuer = "someone-else"
+	assertErrorIs(t, parseClaims(keys, "gomine", mustSign(t, keys, wrongIssuer), &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 0), ErrTokenInvalidIssuer)
+
+	validToken := mustSign(t, keys, mustClaims(t, time.Minute))
+	assertErrorIs(t, parseClaims(keys, "gomine", validToken, &accessTokenJWTClaims{}, "another-audience", 0), ErrTokenInvalidAudience)
+	assertErrorIs(t, parseClaims(keys, "gomine", validToken+"x", &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 0), ErrTokenInvalidSignature)
+	assertErrorIs(t, parseClaims(keys, "gomine", "not-a-token", &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 0), ErrTokenMalformed)
+	assertErrorIs(t, parseClaims(keys, "gomine", "", &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 0), ErrTokenMissing)
+}
+
+// Test parseClaims and assert that EdDSA signed tokens can be verified and cannot be passed off as
+// HS256 tokens
+func TestParseClaimsEdDSA(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+
+	edKey := &SigningKey{ID: "ed", Method: jwt.SigningMethodEdDSA, SignKey: privateKey, VerifyKey: publicKey}
+	keys, err := NewKeySet(edKey)
+	assert.NilError(t, err)
+
+	claims := accessTokenJWTClaims{}
+	assert.NilError(t, parseClaims(keys, "gomine", mustSign(t, keys, mustClaims(t, time.Minute)), &claims, ACCESS_TOKEN_AUDIENCE, 0))
+	assert.Equal(t, "user", claims.UserID)
+
+	forgedKeys, err := NewKeySet(mustHMACKey(t, "ed", "guessed"))
+	assert.NilError(t, err)
+	forged := mustSign(t, forgedKeys, mustClaims(t, time.Minute))
+
+	assertErrorIs(t, parseClaims(keys, "gomine", forged, &accessTokenJWTClaims{}, ACCESS_TOKEN_AUDIENCE, 0), ErrTokenInvalidSignature)
+}
+
+// Test ParseAccessToken with tokens issued before tokens had a kid and assert that they are checked
+// against the default key until legacy tokens are refused
+func TestParseAccessTokenLegacy(t *testing.T) {
+	keys, err := NewKeySet(mustHMACKey(t, "new", "new-secret"), mustHMACKey(t, LEGACY_KEY_ID, "secret"))
+	assert.NilError(t, err)
+	authority := &Authority{Keys: keys, Issuer: "gomine", AcceptLegacyTokens: true}
+
+	sign := func(secret string, claims jwt.MapClaims) string {
+		rawToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		assert.NilError(t, err)
+
+		return rawToken
+	}
+
+	legacy := sign("secret", jwt.MapClaims{"authorized": true, "userId": "u1", "exp": time.Now().Add(time.Hour).Unix()})
+	claims, err := authority.ParseAccessToken(legacy)
+	assert.NilError(t, err)
+	assert.Equal(t, claims.UserID, "u1")
+	assert.Assert(t, claims.ID != "")
+
+	expired := sign("secret", jwt.MapClaims{"userId": "u1", "exp": time.Now().Add(-time.Hour).Unix()})
+	_, err = authority.ParseAccessToken(expired)
+	assertErrorIs(t, err, ErrTokenExpired)
+
+	forged := sign("new-secret", jwt.MapClaims{"userId": "u1", "exp": time.Now().Add(time.Hour).Unix()})
+	_, err = authority.ParseAccessToken(forged)
+	assertErrorIs(t, err, ErrTokenInvalidSignature)
+
+	authority.AcceptLegacyTokens = false
+	_, err = authority.ParseAccessToken(legacy)
+	assert.Assert(t, err != nil)
+}
@@ -0,0 +1,523 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/config"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/mfa"
+	"github.com/ecuyle/gomine/internal/passwordreset"
+	"github.com/ecuyle/gomine/internal/passwords"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/servers"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+)
+
+// REAUTHENTICATION_WINDOW is how recently users with a linked identity must have logged in to
+// confirm a sensitive change without their password
+const REAUTHENTICATION_WINDOW = 5 * time.Minute
+
+// UserProfile is the public view of a user account
+type UserProfile struct {
+	ID              string    `json:"id"`
+	Username        string    `json:"username"`
+	Email           string    `json:"email"`
+	EmailVerified   bool      `json:"emailVerified"`
+	Role            string    `json:"role"`
+	Disabled        bool      `json:"disabled"`
+	PendingApproval bool      `json:"pendingApproval"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// UpdateProfileOptions changes the fields that are set. An empty email removes the address.
+// Changing the email is confirmed with Password or Code, see confirmIdentity.
+type UpdateProfileOptions struct {
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+	Password string  `json:"password"`
+	Code     string  `json:"code"`
+}
+
+// ChangePasswordOptions sets a new password. Users with a linked identity may confirm with Code
+// or a recent login instead of CurrentPassword, see confirmIdentity.
+type ChangePasswordOptions struct {
+	CurrentPassword string `json:"currentPassword"`
+	Code            string `json:"code"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+// DeleteAccountOptions decides what happens to the servers of a deleted account. Password, or Code
+// for users with a linked identity, is only used when users delete their own account.
+type DeleteAccountOptions struct {
+	Password   string              `json:"password"`
+	Code       string              `json:"code"`
+	Servers    servers.Disposition `json:"servers" binding:"required"`
+	TransferTo string              `json:"transferTo"`
+}
+
+// AdminUpdateUserOptions are the account settings only admins can change
+type AdminUpdateUserOptions struct {
+	Role     *permissions.Role `json:"role"`
+	Disabled *bool             `json:"disabled"`
+}
+
+// ErrLastAdmin is returned when a change would leave the installation without an active admin
+var ErrLastAdmin = errors.New("The last admin cannot be removed, demoted or disabled")
+
+func newUserProfile(user *User) *UserProfile {
+	return &UserProfile{
+		ID:              user.ID,
+		Username:        user.Username,
+		Email:           user.Email,
+		EmailVerified:   user.EmailVerified,
+		Role:            user.Role,
+		Disabled:        user.Disabled,
+		PendingApproval: user.PendingApproval,
+		CreatedAt:       user.CreatedAt,
+	}
+}
+
+// checkNotLastAdmin fails with ErrLastAdmin if the user is the only active admin
+func checkNotLastAdmin(users store.UserRepository, user *User) error {
+	if permissions.Role(user.Role) != permissions.RoleAdmin || user.Disabled {
+		return nil
+	}
+
+	count, err := users.CountOtherActiveAdmins(user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrLastAdmin
+	}
+
+	return nil
+}
+
+// deleteAccount disposes of a user's servers and removes the user. The response has been written
+// when false is returned.
+func deleteAccount(context *gin.Context, user *User, options *DeleteAccountOptions) bool {
+	dataStore := store.FromContext(context)
+
+	if !servers.IsValidDisposition(options.Servers) {
+		httputils.RespondWithError(context, httputils.BadRequest("servers must be one of `transfer`, `archive` or `delete`"))
+		return false
+	}
+
+	if options.Servers == servers.DispositionTransfer {
+		if options.TransferTo == "" || options.TransferTo == user.ID {
+			httputils.RespondWithError(context, httputils.BadRequest("transferTo must be the id of another user"))
+			return false
+		}
+
+		if _, err := dataStore.Users.Get(options.TransferTo); errors.Is(err, sql.ErrNoRows) {
+			httputils.RespondWithNotFound(context, errors.New("Could not find user with id: "+options.TransferTo))
+			return false
+		} else if err != nil {
+			httputils.RespondWithInternalServerError(context, err)
+			return false
+		}
+	}
+
+	if err := checkNotLastAdmin(dataStore.Users, user); err != nil {
+		respondWithAccountError(context, err)
+		return false
+	}
+
+	if err := servers.DisposeOfUserServers(dataStore, config.FromContext(context).Server.DataPath, user.ID, options.Servers, options.TransferTo); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return false
+	}
+
+	// Deleting the refresh tokens ends every session, so access tokens of the deleted user stop
+	// working right away
+	if err := dataStore.Users.Delete(user.ID); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return false
+	}
+
+	return true
+}
+
+func respondWithAccountError(context *gin.Context, err error) {
+	if errors.Is(err, ErrLastAdmin) {
+		log.Println(err)
+		httputils.RespondWithError(context, httputils.Conflict(err.Error()))
+		return
+	}
+
+	httputils.RespondWithInternalServerError(context, err)
+}
+
+// getUser loads a user by id. The response has been written when false is returned.
+func getUser(context *gin.Context, id string) (*User, bool) {
+	user, err := store.FromContext(context).Users.Get(id)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		httputils.RespondWithNotFound(context, errors.New("Could not find user with id: "+id))
+		return nil, false
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// RequireAdmin rejects requests of users that are not admins
+func RequireAdmin() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		role, err := permissions.GetUserRole(store.FromContext(context).DB, token.GetAuthenticatedUserId(context))
+
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			httputils.RespondWithInternalServerError(context, err)
+			return
+		}
+
+		if role != permissions.RoleAdmin {
+			httputils.RespondWithError(context, httputils.Forbidden("Forbidden"))
+			return
+		}
+
+		context.Next()
+	}
+}
+
+func GetMe(context *gin.Context) {
+	user, ok := getUser(context, token.GetAuthenticatedUserId(context))
+
+	if !ok {
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, newUserProfile(user))
+}
+
+// PatchMe changes the username or email address of the authenticated user. Password resets are
+// sent to the address, so changing it takes the same confirmation as changing the password. A new
+// address is unverified and reset tokens sent to the previous one stop working.
+func PatchMe(context *gin.Context) {
+	var options UpdateProfileOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	user, ok := getUser(context, token.GetAuthenticatedUserId(context))
+
+	if !ok {
+		return
+	}
+
+	if options.Username != nil {
+		username, err := normalizeUsername(*options.Username)
+
+		if err != nil {
+			httputils.RespondWithError(context, httputils.Invalid(err.Error()))
+			return
+		}
+
+		user.Username = username
+	}
+
+	if options.Email != nil {
+		email, err := normalizeEmail(*options.Email)
+
+		if err != nil {
+			httputils.RespondWithError(context, httputils.Invalid(err.Error()))
+			return
+		}
+
+		if email != user.Email {
+			if !confirmIdentity(context, user, "password", options.Password, options.Code) {
+				return
+			}
+
+			if err := passwordreset.RevokeResetTokens(store.FromContext(context).DB, user.ID); err != nil {
+				httputils.RespondWithInternalServerError(context, err)
+				return
+			}
+
+			user.Email = email
+			user.EmailVerified = false
+		}
+	}
+
+	err := store.FromContext(context).Users.Update(user)
+
+	if errors.Is(err, ErrUsernameTaken) {
+		httputils.RespondWithError(context, httputils.Conflict(err.Error()))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, newUserProfile(user))
+}
+
+// confirmIdentity checks that the authenticated user confirmed a sensitive change with their
+// password. Users with a linked identity may never have known their random password, and confirm
+// with a 2FA code or by having logged in within REAUTHENTICATION_WINDOW instead. The response has
+// already been written when false is returned.
+func confirmIdentity(context *gin.Context, user *User, passwordField string, password string, code string) bool {
+	db := store.FromContext(context).DB
+	linked := false
+
+	if password == "" {
+		var err error
+		linked, err = hasLinkedIdentity(db, user.ID)
+
+		if err != nil {
+			httputils.RespondWithInternalServerError(context, err)
+			return false
+		}
+	}
+
+	if !linked {
+		if err := passwords.ComparePasswordWithHash(password, user.Hash); err != nil {
+			httputils.RespondWithError(context, httputils.Forbidden(passwordField+" is incorrect"))
+			return false
+		}
+
+		return true
+	}
+
+	if code != "" {
+		err := mfa.Verify(db, user.ID, code)
+
+		if errors.Is(err, mfa.ErrInvalidCode) || errors.Is(err, mfa.ErrNotEnabled) {
+			httputils.RespondWithError(context, httputils.Forbidden("code is incorrect"))
+			return false
+		}
+
+		if err != nil {
+			httputils.RespondWithInternalServerError(context, err)
+			return false
+		}
+
+		return true
+	}
+
+	startedAt, err := token.SessionStartedAt(db, token.GetAccessTokenClaims(context).SessionID)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		httputils.RespondWithInternalServerError(context, err)
+		return false
+	}
+
+	if err != nil || time.Since(startedAt) > REAUTHENTICATION_WINDOW {
+		httputils.RespondWithError(context, httputils.Forbidden("Confirm with a two-factor code or by logging in again"))
+		return false
+	}
+
+	return true
+}
+
+// PutMyPassword changes the password of the authenticated user. Every existing session is revoked
+// and the caller gets a new one.
+func PutMyPassword(context *gin.Context) {
+	var options ChangePasswordOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	user, ok := getUser(context, token.GetAuthenticatedUserId(context))
+
+	if !ok {
+		return
+	}
+
+	if !confirmIdentity(context, user, "currentPassword", options.CurrentPassword, options.Code) {
+		return
+	}
+
+	if err := passwords.FromContext(context).Validate(options.NewPassword); err != nil {
+		httputils.RespondWithError(context, httputils.Invalid(err.Error()))
+		return
+	}
+
+	hash, err := passwords.GenerateHashFromPassword(options.NewPassword)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	dataStore := store.FromContext(context)
+	user.Hash = hash
+
+	if err := dataStore.Users.Update(user); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	if err := token.RevokeUserSessions(dataStore.DB, user.ID); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	tokens, err := token.FromContext(context).IssueTokenPair(dataStore.DB, user.ID)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, tokens)
+}
+
+// DeleteMe deletes the account of the authenticated user after confirming their password
+func DeleteMe(context *gin.Context) {
+	var options DeleteAccountOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	user, ok := getUser(context, token.GetAuthenticatedUserId(context))
+
+	if !ok {
+		return
+	}
+
+	if !confirmIdentity(context, user, "password", options.Password, options.Code) {
+		return
+	}
+
+	if deleteAccount(context, user, &options) {
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func GetUsers(context *gin.Context) {
+	users, err := store.FromContext(context).Users.List()
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	profiles := []UserProfile{}
+
+	for i := range users {
+		profiles = append(profiles, *newUserProfile(&users[i]))
+	}
+
+	httputils.RespondWithStatusOk(context, profiles)
+}
+
+// PatchUser lets admins change the role of a user or disable their account. Disabling an account
+// revokes its sessions.
+func PatchUser(context *gin.Context) {
+	var options AdminUpdateUserOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	user, ok := getUser(context, context.Query("u"))
+
+	if !ok {
+		return
+	}
+
+	if options.Role != nil && *options.Role != permissions.RoleAdmin && *options.Role != permissions.RoleUser {
+		httputils.RespondWithError(context, httputils.BadRequest("role must be `admin` or `user`"))
+		return
+	}
+
+	dataStore := store.FromContext(context)
+	demoted := options.Role != nil && *options.Role != permissions.RoleAdmin
+	disabled := options.Disabled != nil && *options.Disabled
+
+	if demoted || disabled {
+		if err := checkNotLastAdmin(dataStore.Users, user); err != nil {
+			respondWithAccountError(context, err)
+			return
+		}
+	}
+
+	if options.Role != nil {
+		user.Role = string(*options.Role)
+	}
+
+	if options.Disabled != nil {
+		user.Disabled = *options.Disabled
+	}
+
+	if err := dataStore.Users.Update(user); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	if disabled {
+		if err := token.RevokeUserSessions(dataStore.DB, user.ID); err != nil {
+			httputils.RespondWithInternalServerError(context, err)
+			return
+		}
+	}
+
+	httputils.RespondWithStatusOk(context, newUserProfile(user))
+}
+
+// DeleteUser lets admins delete another user's account
+func DeleteUser(context *gin.Context) {
+	var options DeleteAccountOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	user, ok := getUser(context, context.Query("u"))
+
+	if !ok {
+		return
+	}
+
+	if deleteAccount(context, user, &options) {
+		context.Status(http.StatusNoContent)
+	}
+}
+
+// PostUserApproval lets admins approve an account that signed up while registration required
+// approval
+func PostUserApproval(context *gin.Context) {
+	user, ok := getUser(context, context.Query("u"))
+
+	if !ok {
+		return
+	}
+
+	if !user.PendingApproval {
+		httputils.RespondWithError(context, httputils.Conflict("user is not awaiting approval"))
+		return
+	}
+
+	user.PendingApproval = false
+
+	if err := store.FromContext(context).Users.Update(user); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, newUserProfile(user))
+}
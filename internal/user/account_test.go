@@ -0,0 +1,106 @@
+package user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecuyle/gomine/internal/passwords"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+	"gotest.tools/assert"
+)
+
+type fakeUserRepository struct {
+	store.UserRepository
+	users map[string]*User
+}
+
+func (repository *fakeUserRepository) Get(id string) (*User, error) {
+	user := *repository.users[id]
+
+	return &user, nil
+}
+
+func (repository *fakeUserRepository) Update(user *User) error {
+	repository.users[user.ID] = user
+
+	return nil
+}
+
+func (repository *fakeUserRepository) CountOtherActiveAdmins(id string) (int, error) {
+	count := 0
+
+	for _, user := range repository.users {
+		if user.ID != id && user.Role == "admin" && !user.Disabled {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Test PatchUser and assert that the last active admin cannot be demoted while others can
+func TestPatchUserKeepsLastAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users := &fakeUserRepository{users: map[string]*User{
+		"a": {ID: "a", Username: "alex", Role: "admin"},
+		"b": {ID: "b", Username: "sam", Role: "admin", Disabled: true},
+	}}
+
+	patch := func(id string) int {
+		recorder := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(recorder)
+		context.Request = httptest.NewRequest(http.MethodPatch, "/api/mcusr/users?u="+id, strings.NewReader(`{"role": "user"}`))
+		context.Set(store.STORE_CONTEXT_KEY, &store.Store{Users: users})
+
+		PatchUser(context)
+
+		return recorder.Code
+	}
+
+	assert.Equal(t, patch("a"), http.StatusConflict)
+	assert.Equal(t, users.users["a"].Role, "admin")
+	assert.Equal(t, patch("b"), http.StatusOK)
+	assert.Equal(t, users.users["b"].Role, "user")
+}
+
+// Test PatchMe and assert that the username changes without confirmation while the email address
+// only changes with the password
+func TestPatchMeConfirmsEmailChanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dataStore := openStore(t)
+	hash, err := passwords.GenerateHashFromPassword("Correct-Horse-42!")
+	assert.NilError(t, err)
+	assert.NilError(t, dataStore.Users.Insert(&User{ID: "u1", Username: "steve", Email: "steve@example.com", EmailVerified: true, Hash: hash}, &store.InsertUserOptions{}))
+
+	patch := func(body string) int {
+		recorder := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(recorder)
+		context.Request = httptest.NewRequest(http.MethodPatch, "/api/mcusr/me", strings.NewReader(body))
+		context.Set(store.STORE_CONTEXT_KEY, dataStore)
+		context.Set(token.USER_ID_CONTEXT_KEY, "u1")
+
+		PatchMe(context)
+
+		return recorder.Code
+	}
+
+	assert.Equal(t, patch(`{"username": "steve2"}`), http.StatusOK)
+	assert.Equal(t, patch(`{"email": "mallory@example.com"}`), http.StatusForbidden)
+	assert.Equal(t, patch(`{"email": "mallory@example.com", "password": "wrong"}`), http.StatusForbidden)
+
+	user, err := dataStore.Users.Get("u1")
+	assert.NilError(t, err)
+	assert.Equal(t, user.Username, "steve2")
+	assert.Equal(t, user.Email, "steve@example.com")
+
+	assert.Equal(t, patch(`{"email": "steve@example.org", "password": "Correct-Horse-42!"}`), http.StatusOK)
+
+	user, err = dataStore.Users.Get("u1")
+	assert.NilError(t, err)
+	assert.Equal(t, user.Email, "steve@example.org")
+	assert.Equal(t, user.EmailVerified, false)
+}
@@ -0,0 +1,69 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/ecuyle/gomine/internal/apikeys"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+)
+
+// CreatedAPIKey is returned when an API key is created. Key is the only time the raw key is shown.
+type CreatedAPIKey struct {
+	apikeys.APIKey
+	Key string `json:"key"`
+}
+
+func PostAPIKey(context *gin.Context) {
+	var options apikeys.APIKeyOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	key, rawKey, err := apikeys.CreateAPIKey(store.FromContext(context).DB, token.GetAuthenticatedUserId(context), &options)
+
+	if errors.Is(err, apikeys.ErrInvalidScopes) {
+		httputils.RespondWithError(context, httputils.Invalid(err.Error()))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusCreated(context, CreatedAPIKey{APIKey: *key, Key: rawKey})
+}
+
+func GetAPIKeys(context *gin.Context) {
+	keys, err := apikeys.ListAPIKeys(store.FromContext(context).DB, token.GetAuthenticatedUserId(context))
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, keys)
+}
+
+func DeleteAPIKey(context *gin.Context) {
+	err := apikeys.RevokeAPIKey(store.FromContext(context).DB, token.GetAuthenticatedUserId(context), context.Query("k"))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		httputils.RespondWithError(context, httputils.NotFound("Could not find API key with id: "+context.Query("k")))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
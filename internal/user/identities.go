@@ -0,0 +1,208 @@
+package user
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/oidc"
+	"github.com/ecuyle/gomine/internal/passwords"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/registration"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/google/uuid"
+)
+
+// ErrNoLinkedAccount is returned when an external identity is not linked to a gomine account and
+// cannot be linked or provisioned
+var ErrNoLinkedAccount = errors.New("No gomine account is linked to this identity")
+
+// IdentityLinkOptions controls how external identities without a linked account are handled. Role
+// is the role mapped from the identity's groups, or empty when roles are managed in gomine.
+type IdentityLinkOptions struct {
+	AutoProvision bool
+	LinkByEmail   bool
+	Role          permissions.Role
+}
+
+var invalidUsernameCharacters = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func selectUserIdByIdentity(db *sql.DB, issuer string, subject string) (string, error) {
+	var userId string
+	err := db.QueryRow("select user_id from user_identities where issuer=? and subject=?", issuer, subject).Scan(&userId)
+
+	return userId, err
+}
+
+// hasLinkedIdentity reports whether a user logs in through an identity provider
+func hasLinkedIdentity(db *sql.DB, userId string) (bool, error) {
+	var linked bool
+	err := db.QueryRow("select exists(select 1 from user_identities where user_id=?)", userId).Scan(&linked)
+
+	return linked, err
+}
+
+func insertIdentity(db *sql.DB, identity *oidc.Identity, userId string) error {
+	_, err := db.Exec(
+		"insert into user_identities(issuer, subject, user_id, created_at) values(?, ?, ?, ?)",
+		identity.Issuer, identity.Subject, userId, time.Now().Unix(),
+	)
+
+	return err
+}
+
+// usernameCandidate derives a valid username from an identity
+func usernameCandidate(identity *oidc.Identity) string {
+	candidate := identity.Username
+
+	if candidate == "" {
+		candidate = strings.Split(identity.Email, "@")[0]
+	}
+
+	candidate = strings.Trim(invalidUsernameCharacters.ReplaceAllString(candidate, "_"), "_.-")
+
+	if len(candidate) > USERNAME_MAX_LENGTH-5 {
+		candidate = candidate[:USERNAME_MAX_LENGTH-5]
+	}
+
+	if len(candidate) < USERNAME_MIN_LENGTH {
+		candidate = "user"
+	}
+
+	return candidate
+}
+
+// provisionUser creates an account for an identity. Provisioned accounts get a random password
+// nobody knows, which the user can replace with a password reset.
+func provisionUser(users store.UserRepository, identity *oidc.Identity) (*User, error) {
+	password := make([]byte, 32)
+
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+
+	hash, err := passwords.GenerateHashFromPassword(base64.RawURLEncoding.EncodeToString(password))
+
+	if err != nil {
+		return nil, err
+	}
+
+	base := usernameCandidate(identity)
+
+	for attempt := 0; attempt < 10; attempt++ {
+		id, err := uuid.NewRandom()
+
+		if err != nil {
+			return nil, err
+		}
+
+		username := base
+
+		if attempt > 0 {
+			username = fmt.Sprintf("%v-%v", base, id.String()[:4])
+		}
+
+		email := ""
+
+		if identity.EmailVerified {
+			email, _ = normalizeEmail(identity.Email)
+		}
+
+		user := User{ID: id.String(), Username: username, Email: email, EmailVerified: email != "", Hash: hash}
+
+		// The identity provider decides who may sign in, so the registration mode does not apply.
+		// Admins come from the admin groups instead of ADMIN_USERNAME, which a provider's usernames
+		// could claim.
+		err = insertUser(users, &user, registration.ModeOpen, "", "")
+
+		if errors.Is(err, ErrUsernameTaken) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &user, nil
+	}
+
+	return nil, fmt.Errorf("Could not find a free username for `%v`", base)
+}
+
+// syncRole applies the role mapped from the identity provider. The last admin is never demoted.
+func syncRole(users store.UserRepository, user *User, role permissions.Role) error {
+	if role == "" || permissions.Role(user.Role) == role {
+		return nil
+	}
+
+	if role != permissions.RoleAdmin {
+		err := checkNotLastAdmin(users, user)
+
+		if errors.Is(err, ErrLastAdmin) {
+			log.Printf("Not demoting `%v` as the identity provider asks: %v", user.Username, err)
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	user.Role = string(role)
+
+	return users.Update(user)
+}
+
+// ResolveExternalIdentity returns the account linked to an external identity. When the options
+// allow it, unlinked identities are linked to the account that verified the email address the
+// provider verified, or get a new account. Addresses users set themselves are never trusted, as
+// anyone could claim someone else's.
+func ResolveExternalIdentity(dataStore *store.Store, identity *oidc.Identity, options *IdentityLinkOptions) (*User, error) {
+	userId, err := selectUserIdByIdentity(dataStore.DB, identity.Issuer, identity.Subject)
+
+	if errors.Is(err, sql.ErrNoRows) && options.LinkByEmail && identity.EmailVerified && identity.Email != "" {
+		var user *User
+		user, err = dataStore.Users.GetByVerifiedEmail(identity.Email)
+
+		if err == nil {
+			userId = user.ID
+			err = insertIdentity(dataStore.DB, identity, userId)
+		}
+	}
+
+	if errors.Is(err, sql.ErrNoRows) && options.AutoProvision {
+		var user *User
+		user, err = provisionUser(dataStore.Users, identity)
+
+		if err == nil {
+			userId = user.ID
+			err = insertIdentity(dataStore.DB, identity, userId)
+		}
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoLinkedAccount
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := dataStore.Users.Get(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syncRole(dataStore.Users, user, options.Role); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
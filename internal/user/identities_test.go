@@ -0,0 +1,42 @@
+package user
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ecuyle/gomine/internal/oidc"
+	"github.com/ecuyle/gomine/internal/store"
+	"gotest.tools/assert"
+)
+
+func openStore(t *testing.T) *store.Store {
+	db, dialect, err := store.Open(filepath.Join(t.TempDir(), "gomine.db"))
+	assert.NilError(t, err)
+	t.Cleanup(func() { db.Close() })
+	assert.NilError(t, store.Migrate(db, dialect))
+
+	return store.New(db, dialect)
+}
+
+// Test ResolveExternalIdentity and assert that identities are only linked by email to an account
+// that verified the address, so nobody can claim an identity by setting its address on their own
+// account
+func TestResolveExternalIdentityByEmail(t *testing.T) {
+	dataStore := openStore(t)
+	mallory := &User{ID: "u1", Username: "mallory", Email: "steve@example.com", Hash: "hash"}
+	assert.NilError(t, dataStore.Users.Insert(mallory, &store.InsertUserOptions{}))
+
+	identity := &oidc.Identity{Issuer: "https://sso.example.com", Subject: "steve", Email: "steve@example.com", EmailVerified: true}
+	options := &IdentityLinkOptions{LinkByEmail: true}
+
+	_, err := ResolveExternalIdentity(dataStore, identity, options)
+	assert.Assert(t, errors.Is(err, ErrNoLinkedAccount))
+
+	steve := &User{ID: "u2", Username: "steve", Email: "steve@example.com", EmailVerified: true, Hash: "hash"}
+	assert.NilError(t, dataStore.Users.Insert(steve, &store.InsertUserOptions{}))
+
+	user, err := ResolveExternalIdentity(dataStore, identity, options)
+	assert.NilError(t, err)
+	assert.Equal(t, user.ID, steve.ID)
+}
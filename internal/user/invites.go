@@ -0,0 +1,69 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/registration"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+)
+
+// CreatedInvite is returned when an invite is created. Code is the only time the raw code is shown.
+type CreatedInvite struct {
+	registration.Invite
+	Code string `json:"code"`
+}
+
+func PostInvite(context *gin.Context) {
+	var options registration.InviteOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	invite, code, err := registration.CreateInvite(store.FromContext(context).DB, token.GetAuthenticatedUserId(context), &options)
+
+	if errors.Is(err, registration.ErrInvalidInviteOptions) {
+		httputils.RespondWithError(context, httputils.Invalid(err.Error()))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusCreated(context, CreatedInvite{Invite: *invite, Code: code})
+}
+
+func GetInvites(context *gin.Context) {
+	invites, err := registration.ListInvites(store.FromContext(context).DB)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, invites)
+}
+
+func DeleteInvite(context *gin.Context) {
+	err := registration.RevokeInvite(store.FromContext(context).DB, context.Query("i"))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		httputils.RespondWithError(context, httputils.NotFound("Could not find invite with id: "+context.Query("i")))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
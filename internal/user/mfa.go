@@ -0,0 +1,108 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ecuyle/gomine/internal/config"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/mfa"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+)
+
+type MFAVerificationOptions struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableMFAOptions confirms turning 2FA off like ChangePasswordOptions does
+type DisableMFAOptions struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// ActivatedMFA is returned when 2FA is activated. RecoveryCodes is the only time the codes are
+// shown.
+type ActivatedMFA struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// PostMFAEnrollment starts enrolling the authenticated user in 2FA
+func PostMFAEnrollment(context *gin.Context) {
+	user, ok := getUser(context, token.GetAuthenticatedUserId(context))
+
+	if !ok {
+		return
+	}
+
+	enrollment, err := mfa.BeginEnrollment(store.FromContext(context).DB, config.FromContext(context).MFA.TOTPIssuer, user.ID, user.Username)
+
+	if errors.Is(err, mfa.ErrAlreadyEnabled) {
+		httputils.RespondWithError(context, httputils.Conflict(err.Error()))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusCreated(context, enrollment)
+}
+
+// PostMFAVerification activates 2FA with a code from the user's authenticator app
+func PostMFAVerification(context *gin.Context) {
+	var options MFAVerificationOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	codes, err := mfa.ActivateEnrollment(store.FromContext(context).DB, token.GetAuthenticatedUserId(context), options.Code)
+
+	if errors.Is(err, mfa.ErrNotEnrolling) {
+		httputils.RespondWithError(context, httputils.Conflict(err.Error()))
+		return
+	}
+
+	if errors.Is(err, mfa.ErrInvalidCode) {
+		httputils.RespondWithError(context, httputils.BadRequest(err.Error()))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	httputils.RespondWithStatusOk(context, ActivatedMFA{RecoveryCodes: codes})
+}
+
+// DeleteMFA turns 2FA off after confirming the user's identity
+func DeleteMFA(context *gin.Context) {
+	var options DisableMFAOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	user, ok := getUser(context, token.GetAuthenticatedUserId(context))
+
+	if !ok {
+		return
+	}
+
+	if !confirmIdentity(context, user, "password", options.Password, options.Code) {
+		return
+	}
+
+	if err := mfa.Disable(store.FromContext(context).DB, user.ID); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
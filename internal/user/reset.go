@@ -0,0 +1,159 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ecuyle/gomine/internal/config"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/notifications"
+	"github.com/ecuyle/gomine/internal/passwordreset"
+	"github.com/ecuyle/gomine/internal/passwords"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/ecuyle/gomine/internal/token"
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordResetRequestOptions struct {
+	Username string `json:"username" binding:"required"`
+}
+
+type PasswordResetOptions struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// makeResetMessage builds the message a reset token is delivered in. The token is added to
+// resetUrl when a frontend to enter it is configured.
+func makeResetMessage(user *User, rawToken string, expiresAt time.Time, resetUrl string) *notifications.Message {
+	body := fmt.Sprintf(
+		"Someone asked to reset the password of the gomine account `%v`.\n\nUse this token to choose a new password before %v:\n%v\n",
+		user.Username, expiresAt.UTC().Format(time.RFC1123), rawToken,
+	)
+
+	if resetUrl != "" {
+		body += fmt.Sprintf("\nOr open %v?token=%v\n", resetUrl, url.QueryEscape(rawToken))
+	}
+
+	body += "\nIf you did not ask for this, you can ignore this message.\n"
+
+	return &notifications.Message{To: user.Email, Subject: "Reset your gomine password", Body: body}
+}
+
+// sendPasswordReset issues and delivers a reset token for a username, if it belongs to an account
+// that can log in
+func sendPasswordReset(dataStore *store.Store, resetConfig *config.PasswordResetConfig, username string, sender notifications.Sender) error {
+	user, err := dataStore.Users.GetByUsername(username)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if user.Disabled {
+		return nil
+	}
+
+	rawToken, expiresAt, err := passwordreset.CreateResetToken(dataStore.DB, user.ID, time.Duration(resetConfig.LifespanMinutes)*time.Minute)
+
+	if errors.Is(err, passwordreset.ErrTooManyRequests) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return sender.Send(makeResetMessage(user, rawToken, expiresAt, resetConfig.URL))
+}
+
+// PostPasswordResetRequest sends a reset token to the owner of an account. It responds the same
+// way whether or not the account exists, and does the work in the background so response times do
+// not tell either.
+func PostPasswordResetRequest(context *gin.Context) {
+	var options PasswordResetRequestOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	dataStore := store.FromContext(context)
+	settings := config.FromContext(context)
+
+	go func() {
+		if err := sendPasswordReset(dataStore, &settings.PasswordReset, options.Username, notifications.NewSender(&settings.SMTP)); err != nil {
+			log.Println("Error sending password reset")
+			log.Println(err)
+		}
+	}()
+
+	context.Status(http.StatusAccepted)
+}
+
+// PostPasswordReset sets a new password with a reset token and ends every session of the user.
+// Receiving the token verifies the user's email address, as changing it revokes the tokens sent
+// to the previous one.
+func PostPasswordReset(context *gin.Context) {
+	var options PasswordResetOptions
+
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
+		return
+	}
+
+	if err := passwords.FromContext(context).Validate(options.NewPassword); err != nil {
+		httputils.RespondWithError(context, httputils.Invalid(err.Error()))
+		return
+	}
+
+	hash, err := passwords.GenerateHashFromPassword(options.NewPassword)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	dataStore := store.FromContext(context)
+	userId, err := passwordreset.ConsumeResetToken(dataStore.DB, options.Token)
+
+	if errors.Is(err, passwordreset.ErrInvalidResetToken) {
+		httputils.RespondWithError(context, httputils.BadRequest(err.Error()))
+		return
+	}
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	user, err := dataStore.Users.Get(userId)
+
+	if err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	user.Hash = hash
+	user.EmailVerified = user.Email != ""
+
+	if err := dataStore.Users.Update(user); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	if err := token.RevokeUserSessions(dataStore.DB, userId); err != nil {
+		httputils.RespondWithInternalServerError(context, err)
+		return
+	}
+
+	context.Status(http.StatusNoContent)
+}
@@ -2,67 +2,146 @@ package user
 
 import (
 	"database/sql"
-	"html"
+	"errors"
+	"fmt"
 	"log"
-	"net/http"
+	"net/mail"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/ecuyle/gomine/internal/config"
 	httputils "github.com/ecuyle/gomine/internal/http"
 	"github.com/ecuyle/gomine/internal/passwords"
+	"github.com/ecuyle/gomine/internal/permissions"
+	"github.com/ecuyle/gomine/internal/registration"
+	"github.com/ecuyle/gomine/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+const (
+	USERNAME_MIN_LENGTH = 3
+	USERNAME_MAX_LENGTH = 32
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+var ErrInvalidUsername = fmt.Errorf(
+	"Usernames must be %v to %v characters long, start with a letter or digit and only contain letters, digits, `_`, `.` and `-`",
+	USERNAME_MIN_LENGTH, USERNAME_MAX_LENGTH,
+)
+
+var ErrInvalidEmail = errors.New("Email must be a valid address such as `steve@example.com`")
+
+// ErrUsernameTaken is returned when another user has the same username, ignoring case
+var ErrUsernameTaken = store.ErrUsernameTaken
+
 type UserOptions struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	InviteCode string `json:"inviteCode"`
 }
 
-type User struct {
-	ID       string
-	Username string
-	Hash     string
+type User = store.User
+
+// normalizeUsername trims a username and checks it against the username policy
+func normalizeUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	length := utf8.RuneCountInString(username)
+
+	if length < USERNAME_MIN_LENGTH || length > USERNAME_MAX_LENGTH || !usernamePattern.MatchString(username) {
+		return "", ErrInvalidUsername
+	}
+
+	return username, nil
 }
 
-func insertUser(user *User) error {
-	db, err := sql.Open("sqlite3", "./gomine.db")
+// normalizeEmail trims an email address and checks that it is a bare address. An empty address
+// is allowed and means the user has none.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
 
-	if err != nil {
-		return err
+	if email == "" {
+		return "", nil
 	}
 
-	defer db.Close()
-	transaction, err := db.Begin()
+	address, err := mail.ParseAddress(email)
 
-	if err != nil {
-		return err
+	if err != nil || address.Address != email {
+		return "", ErrInvalidEmail
 	}
 
-	statement, err := transaction.Prepare("insert into users(id, username, hash) values(?, ?, ?)")
+	return email, nil
+}
 
-	if err != nil {
+// insertUser creates a user as allowed by the registration mode. The user is bootstrapped as admin
+// when they have the ADMIN_USERNAME and there is no admin yet, and then needs neither an invite nor
+// approval.
+func insertUser(users store.UserRepository, user *User, mode registration.Mode, inviteCode string, adminUsername string) error {
+	options := store.InsertUserOptions{
+		BootstrapAdmin:  adminUsername != "" && strings.EqualFold(user.Username, adminUsername),
+		RequireApproval: mode == registration.ModeApproval,
+	}
+
+	if mode == registration.ModeInvite {
+		options.Redeem = func(transaction *sql.Tx) error {
+			return registration.RedeemInvite(transaction, inviteCode)
+		}
+	}
+
+	return users.Insert(user, &options)
+}
+
+// BootstrapAdmin makes the existing user with the ADMIN_USERNAME admin while there is no admin, as
+// is the case for installations whose users signed up before there were roles
+func BootstrapAdmin(users store.UserRepository, adminUsername string) error {
+	admins, err := users.CountOtherActiveAdmins("")
+
+	if err != nil || admins > 0 {
 		return err
 	}
 
-	defer statement.Close()
+	if adminUsername == "" {
+		log.Println("No admin exists yet. Set ADMIN_USERNAME to the username that becomes admin.")
+		return nil
+	}
+
+	user, err := users.GetByUsername(adminUsername)
 
-	_, err = statement.Exec(user.ID, user.Username, user.Hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("No admin exists yet. `%v` becomes admin when signing up.", adminUsername)
+		return nil
+	}
 
 	if err != nil {
 		return err
 	}
 
-	err = transaction.Commit()
+	user.Role = string(permissions.RoleAdmin)
+	user.Disabled = false
+	user.PendingApproval = false
 
-	if err != nil {
+	if err := users.Update(user); err != nil {
 		return err
 	}
 
+	log.Printf("Made `%v` admin", user.Username)
+
 	return nil
 }
 
-func makeUser(username, password string) (*User, error) {
-	// hash, err := password.GenerateHashFromPassword(password)
+func makeUser(policy *passwords.Policy, username, password string) (*User, error) {
+	username, err := normalizeUsername(username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := policy.Validate(password); err != nil {
+		return nil, err
+	}
+
 	hash, err := passwords.GenerateHashFromPassword(password)
 
 	if err != nil {
@@ -75,40 +154,51 @@ func makeUser(username, password string) (*User, error) {
 		return nil, err
 	}
 
-	escapedUsername := html.EscapeString(strings.TrimSpace(username))
-
-	return &User{ID: id.String(), Username: escapedUsername, Hash: hash}, nil
+	return &User{ID: id.String(), Username: username, Hash: hash}, nil
 }
 
 func PostUser(context *gin.Context) {
 	var options UserOptions
 
-	if err := context.BindJSON(&options); err != nil {
-		log.Println(err)
-		context.String(http.StatusBadRequest, err.Error())
+	if err := context.ShouldBindJSON(&options); err != nil {
+		httputils.RespondWithInvalidBody(context, err)
 		return
 	}
 
-	user, err := makeUser(options.Username, options.Password)
+	registrationConfig := config.FromContext(context).Registration
+	mode := registration.Mode(registrationConfig.Mode)
+	user, err := makeUser(passwords.FromContext(context), options.Username, options.Password)
+
+	if errors.Is(err, ErrInvalidUsername) || passwords.IsPolicyViolation(err) {
+		httputils.RespondWithError(context, httputils.Invalid(err.Error()))
+		return
+	}
 
 	if err != nil {
-		log.Println("Error making user")
-		log.Println(err)
-		context.String(http.StatusInternalServerError, err.Error())
+		httputils.RespondWithInternalServerError(context, err)
 		return
 	}
 
-	err = insertUser(user)
+	err = insertUser(store.FromContext(context).Users, user, mode, options.InviteCode, registrationConfig.AdminUsername)
+
+	if errors.Is(err, ErrUsernameTaken) {
+		httputils.RespondWithError(context, httputils.Conflict(err.Error()))
+		return
+	}
+
+	if errors.Is(err, registration.ErrInvalidInvite) {
+		httputils.RespondWithForbidden(context, err)
+		return
+	}
 
 	if err != nil {
-		log.Println("Error inserting user")
-		log.Println(err)
-		context.String(http.StatusInternalServerError, err.Error())
+		httputils.RespondWithInternalServerError(context, err)
 		return
 	}
 
-	httputils.RespondWithStatusCreated(context, map[string]string{
-		"id":       user.ID,
-		"username": user.Username,
+	httputils.RespondWithStatusCreated(context, map[string]any{
+		"id":              user.ID,
+		"username":        user.Username,
+		"pendingApproval": user.PendingApproval,
 	})
 }
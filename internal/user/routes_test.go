@@ -0,0 +1,21 @@
+package user
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Test normalizeUsername and assert that usernames are trimmed and checked against the policy
+func TestNormalizeUsername(t *testing.T) {
+	username, err := normalizeUsername("  Steve_01 ")
+	assert.NilError(t, err)
+	assert.Equal(t, "Steve_01", username)
+
+	for _, invalid := range []string{"", "ab", strings.Repeat("a", USERNAME_MAX_LENGTH+1), "<b>steve</b>", "-steve", "st eve"} {
+		_, err := normalizeUsername(invalid)
+		assert.Assert(t, errors.Is(err, ErrInvalidUsername), invalid)
+	}
+}
@@ -1,47 +1,216 @@
 package main
 
 import (
+	"errors"
 	"log"
-	"os/exec"
+	"os"
+	"time"
 
 	"github.com/ecuyle/gomine/internal/authentication"
+	"github.com/ecuyle/gomine/internal/cgroups"
+	"github.com/ecuyle/gomine/internal/config"
+	httputils "github.com/ecuyle/gomine/internal/http"
+	"github.com/ecuyle/gomine/internal/java"
+	"github.com/ecuyle/gomine/internal/oidc"
+	"github.com/ecuyle/gomine/internal/openapi"
+	"github.com/ecuyle/gomine/internal/passwords"
+	"github.com/ecuyle/gomine/internal/ports"
 	"github.com/ecuyle/gomine/internal/servers"
+	"github.com/ecuyle/gomine/internal/store"
 	"github.com/ecuyle/gomine/internal/token"
 	"github.com/ecuyle/gomine/internal/user"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
+// LEGACY_SERVER_ROUTES_DEPRECATED_AT is when the /api/mcsrv routes were replaced by /api/v1/servers
+var LEGACY_SERVER_ROUTES_DEPRECATED_AT = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
 func main() {
-	err := godotenv.Load(".env")
+	settings, err := config.Load(os.Args[1:])
+
+	if err != nil {
+		log.Fatalf("main.go: %v", err)
+	}
+
+	dataPath := settings.Server.DataPath
+
+	for _, directory := range []string{servers.GetJarFilepath(dataPath, ""), servers.GetServerFilepath(dataPath, "")} {
+		if err := os.MkdirAll(directory, 0755); err != nil {
+			log.Fatalln("main.go: Could not initialize required directories")
+		}
+	}
+
+	db, dialect, err := store.Open(settings.Database.URL)
+
+	if err != nil {
+		log.Fatalf("main.go: Could not open database: %v", err)
+	}
+
+	defer db.Close()
+
+	if err := store.Migrate(db, dialect); err != nil {
+		log.Fatalf("main.go: Could not migrate database: %v", err)
+	}
+
+	authority, err := token.NewAuthority(&settings.Tokens)
 
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		log.Fatalf("main.go: Could not load JWT keys: %v", err)
+	}
+
+	passwordPolicy, err := passwords.NewPolicy(&settings.Passwords)
+
+	if err != nil {
+		log.Fatalf("main.go: Could not load password policy: %v", err)
+	}
+
+	// Single sign-on is only offered when an identity provider is configured
+	var provider *oidc.Provider
+
+	if oidcConfig, err := oidc.LoadConfig(&settings.OIDC); err == nil {
+		provider = oidc.NewProvider(oidcConfig)
 	}
 
-	cmd := exec.Command("mkdir", "-p", servers.GetJarFilepath(""), servers.GetServerFilepath(""))
+	dataStore := store.New(db, dialect)
 
-	if err := cmd.Run(); err != nil {
-		log.Fatalln("main.go: Could not initialize required directories")
+	if err := user.BootstrapAdmin(dataStore.Users, settings.Registration.AdminUsername); err != nil {
+		log.Fatalf("main.go: Could not bootstrap the admin: %v", err)
 	}
 
-	router := gin.Default()
+	allocator := ports.NewAllocator(&settings.Ports)
 
+	if err := servers.AssignExistingServerPorts(dataStore, allocator); err != nil {
+		log.Fatalf("main.go: Could not assign the ports of existing servers: %v", err)
+	}
+
+	registry := java.Detect(&settings.Java)
+
+	for _, installation := range registry.Installations {
+		log.Printf("Found Java %v at `%v`", installation.Version, installation.Home)
+	}
+
+	if len(registry.Installations) == 0 {
+		log.Println("main.go: No Java installation was found, servers cannot be created or started until JAVA_HOMES points to one")
+	}
+
+	// Servers run without resource limits on hosts without cgroup v2
+	manager, err := cgroups.New(&settings.Cgroups)
+
+	if err != nil && !errors.Is(err, cgroups.ErrDisabled) {
+		log.Printf("main.go: Resource limits are not enforced: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(
+		gin.Logger(),
+		httputils.RequestID,
+		gin.CustomRecovery(httputils.Recover),
+		config.Middleware(settings),
+		store.Middleware(dataStore),
+		token.Middleware(authority),
+		passwords.Middleware(passwordPolicy),
+		ports.Middleware(allocator),
+		java.Middleware(registry),
+		cgroups.Middleware(manager),
+		oidc.Middleware(provider),
+	)
+
+	registerRoutes(router)
+
+	router.Run(settings.Server.ListenAddress)
+}
+
+// registerRoutes adds every route of the API to a router. Routes are described in the OpenAPI
+// document served at /api/openapi.json, which must be updated along with them.
+func registerRoutes(router *gin.Engine) {
+	v1ServerRoutes := router.Group("/api/v1/servers")
+	v1ServerRoutes.Use(token.JwtAuthMiddleware())
+	v1ServerRoutes.GET("", servers.ListServers)
+	v1ServerRoutes.POST("", token.RequireAccessToken(), servers.PostServer)
+	v1ServerRoutes.GET("/defaults", servers.GetDefaults)
+	v1ServerRoutes.GET("/:id", servers.GetServer)
+	v1ServerRoutes.GET("/:id/properties", servers.GetProperties)
+	v1ServerRoutes.PATCH("/:id/properties", servers.PatchProperties)
+	v1ServerRoutes.GET("/:id/properties/history", servers.GetPropertiesHistory)
+	v1ServerRoutes.POST("/:id/properties/revert", servers.PostPropertiesRevert)
+	v1ServerRoutes.GET("/:id/launch", servers.GetLaunch)
+	v1ServerRoutes.PUT("/:id/launch", servers.PutLaunch)
+	v1ServerRoutes.GET("/:id/eula", servers.GetEULA)
+	v1ServerRoutes.PUT("/:id/eula", servers.PutEULA)
+	v1ServerRoutes.POST("/:id/start", servers.PostStart)
+	v1ServerRoutes.POST("/:id/stop", servers.PostStop)
+	v1ServerRoutes.POST("/:id/console", servers.PostConsoleCommand)
+	v1ServerRoutes.GET("/:id/logs", servers.GetLogs)
+	v1ServerRoutes.GET("/:id/grants", servers.GetGrants)
+	v1ServerRoutes.POST("/:id/grants", servers.PostGrant)
+	v1ServerRoutes.DELETE("/:id/grants/:userId", servers.DeleteGrant)
+
+	router.GET("/api/v1/java", token.JwtAuthMiddleware(), java.GetInstallations)
+	router.GET("/api/v1/admin/servers", token.JwtAuthMiddleware(), token.RequireAccessToken(), user.RequireAdmin(), servers.ListAllServers)
+
+	// The original server routes are kept for clients that have not moved to /api/v1/servers yet
 	serverRoutes := router.Group("/api/mcsrv")
-	serverRoutes.Use(token.JwtAuthMiddleware())
+	serverRoutes.Use(httputils.Deprecated(LEGACY_SERVER_ROUTES_DEPRECATED_AT, "/api/v1/servers"), token.JwtAuthMiddleware())
 	serverRoutes.GET("/", servers.GetServersByUserId)
 	serverRoutes.GET("/detail", servers.GetServerDetails)
 	serverRoutes.GET("/defaults", servers.GetDefaults)
-	serverRoutes.POST("/", servers.PostServer)
+	serverRoutes.POST("/", token.RequireAccessToken(), servers.PostServer)
 	serverRoutes.PUT("/properties", servers.PutServerProperties)
+	serverRoutes.GET("/properties/history", servers.GetServerPropertiesHistory)
+	serverRoutes.POST("/properties/revert", servers.PostServerPropertiesRevert)
+	serverRoutes.POST("/start", servers.PostServerStart)
+	serverRoutes.POST("/stop", servers.PostServerStop)
+	serverRoutes.POST("/console", servers.PostServerConsoleCommand)
+	serverRoutes.GET("/grants", servers.GetServerGrants)
+	serverRoutes.POST("/grants", servers.PostServerGrant)
+	serverRoutes.DELETE("/grants", servers.DeleteServerGrant)
 
 	router.POST("/api/mcusr", user.PostUser)
 
+	apiKeyRoutes := router.Group("/api/mcusr/apikeys")
+	apiKeyRoutes.Use(token.JwtAuthMiddleware(), token.RequireAccessToken())
+	apiKeyRoutes.GET("/", user.GetAPIKeys)
+	apiKeyRoutes.POST("/", user.PostAPIKey)
+	apiKeyRoutes.DELETE("/", user.DeleteAPIKey)
+
+	meRoutes := router.Group("/api/mcusr/me")
+	meRoutes.Use(token.JwtAuthMiddleware(), token.RequireAccessToken())
+	meRoutes.GET("", user.GetMe)
+	meRoutes.PATCH("", user.PatchMe)
+	meRoutes.DELETE("", user.DeleteMe)
+	meRoutes.PUT("/password", user.PutMyPassword)
+	meRoutes.POST("/mfa", user.PostMFAEnrollment)
+	meRoutes.POST("/mfa/verify", user.PostMFAVerification)
+	meRoutes.DELETE("/mfa", user.DeleteMFA)
+
+	adminUserRoutes := router.Group("/api/mcusr/users")
+	adminUserRoutes.Use(token.JwtAuthMiddleware(), token.RequireAccessToken(), user.RequireAdmin())
+	adminUserRoutes.GET("/", user.GetUsers)
+	adminUserRoutes.PATCH("/", user.PatchUser)
+	adminUserRoutes.DELETE("/", user.DeleteUser)
+	adminUserRoutes.POST("/approve", user.PostUserApproval)
+
+	inviteRoutes := router.Group("/api/mcusr/invites")
+	inviteRoutes.Use(token.JwtAuthMiddleware(), token.RequireAccessToken(), user.RequireAdmin())
+	inviteRoutes.GET("/", user.GetInvites)
+	inviteRoutes.POST("/", user.PostInvite)
+	inviteRoutes.DELETE("/", user.DeleteInvite)
+
 	router.POST("/api/login", authentication.AuthenticateUser)
+	router.POST("/api/login/mfa", authentication.AuthenticateMFAChallenge)
+	router.GET("/api/oidc/login", authentication.StartOIDCLogin)
+	router.GET("/api/oidc/callback", authentication.CompleteOIDCLogin)
+	router.POST("/api/password-reset", user.PostPasswordResetRequest)
+	router.POST("/api/password-reset/confirm", user.PostPasswordReset)
+	router.POST("/api/refresh", authentication.RefreshAccessToken)
+	router.POST("/api/logout", token.JwtAuthMiddleware(), token.RequireAccessToken(), authentication.Logout)
 
 	router.GET("/ping", func(context *gin.Context) {
 		context.String(200, "pong")
 	})
 
-	router.Run("localhost:8080")
+	router.GET("/api/config", token.JwtAuthMiddleware(), token.RequireAccessToken(), user.RequireAdmin(), config.GetConfig)
+	router.GET("/api/openapi.json", openapi.GetSpec)
+
+	router.NoRoute(httputils.RespondWithNoRoute)
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ecuyle/gomine/internal/apikeys"
+	"github.com/ecuyle/gomine/internal/openapi"
+	"github.com/ecuyle/gomine/internal/store"
+	"github.com/gin-gonic/gin"
+	"gotest.tools/assert"
+)
+
+// ginParameter matches the path parameters of gin routes, like :id
+var ginParameter = regexp.MustCompile(`:(\w+)`)
+
+// Test registerRoutes and assert that the OpenAPI document describes every route and nothing else
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerRoutes(router)
+
+	document, err := openapi.Load()
+	assert.NilError(t, err)
+
+	documented := map[string]bool{}
+
+	for path, item := range document.Paths {
+		for method := range item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for _, route := range router.Routes() {
+		key := route.Method + " " + ginParameter.ReplaceAllString(route.Path, "{$1}")
+
+		if !documented[key] {
+			t.Errorf("%v is not described in internal/openapi/openapi.json", key)
+		}
+
+		delete(documented, key)
+	}
+
+	for key := range documented {
+		t.Errorf("%v is described in internal/openapi/openapi.json but not registered", key)
+	}
+}
+
+// Test creating a server with an API key and assert that it is forbidden, since no scope covers it
+func TestAPIKeysCannotCreateServers(t *testing.T) {
+	db, dialect, err := store.Open(filepath.Join(t.TempDir(), "gomine.db"))
+	assert.NilError(t, err)
+	defer db.Close()
+	assert.NilError(t, store.Migrate(db, dialect))
+
+	_, err = db.Exec("insert into users(id, username, hash) values('u1', 'steve', 'hash')")
+	assert.NilError(t, err)
+	_, rawKey, err := apikeys.CreateAPIKey(db, "u1", &apikeys.APIKeyOptions{Name: "ci", Scopes: []apikeys.Scope{apikeys.ScopeRead}})
+	assert.NilError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(store.Middleware(store.New(db, dialect)))
+	registerRoutes(router)
+
+	for _, path := range []string{"/api/v1/servers", "/api/mcsrv/"} {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"name":"world","runtime":"1.20.1"}`))
+		request.Header.Set("X-API-Key", rawKey)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, recorder.Code, http.StatusForbidden, path)
+	}
+}